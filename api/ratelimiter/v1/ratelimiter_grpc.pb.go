@@ -0,0 +1,263 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: ratelimiter/v1/ratelimiter.proto
+
+package ratelimiterv1
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	RateLimiter_Reserve_FullMethodName       = "/ratelimiter.v1.RateLimiter/Reserve"
+	RateLimiter_Commit_FullMethodName        = "/ratelimiter.v1.RateLimiter/Commit"
+	RateLimiter_Cancel_FullMethodName        = "/ratelimiter.v1.RateLimiter/Cancel"
+	RateLimiter_ReserveStream_FullMethodName = "/ratelimiter.v1.RateLimiter/ReserveStream"
+)
+
+// RateLimiterClient is the client API for RateLimiter service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type RateLimiterClient interface {
+	// Reserve reserves capacity, queueing for up to max_wait_ms if set
+	Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*Reservation, error)
+	// Commit settles a pending reservation with the tokens actually used
+	Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*Settlement, error)
+	// Cancel releases a pending reservation
+	Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*Settlement, error)
+	// ReserveStream answers many reservations over one stream. Responses
+	// carry the id of their request and may arrive out of order.
+	ReserveStream(ctx context.Context, opts ...grpc.CallOption) (RateLimiter_ReserveStreamClient, error)
+}
+
+type rateLimiterClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewRateLimiterClient(cc grpc.ClientConnInterface) RateLimiterClient {
+	return &rateLimiterClient{cc}
+}
+
+func (c *rateLimiterClient) Reserve(ctx context.Context, in *ReserveRequest, opts ...grpc.CallOption) (*Reservation, error) {
+	out := new(Reservation)
+	err := c.cc.Invoke(ctx, RateLimiter_Reserve_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rateLimiterClient) Commit(ctx context.Context, in *CommitRequest, opts ...grpc.CallOption) (*Settlement, error) {
+	out := new(Settlement)
+	err := c.cc.Invoke(ctx, RateLimiter_Commit_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rateLimiterClient) Cancel(ctx context.Context, in *CancelRequest, opts ...grpc.CallOption) (*Settlement, error) {
+	out := new(Settlement)
+	err := c.cc.Invoke(ctx, RateLimiter_Cancel_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *rateLimiterClient) ReserveStream(ctx context.Context, opts ...grpc.CallOption) (RateLimiter_ReserveStreamClient, error) {
+	stream, err := c.cc.NewStream(ctx, &RateLimiter_ServiceDesc.Streams[0], RateLimiter_ReserveStream_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &rateLimiterReserveStreamClient{stream}
+	return x, nil
+}
+
+type RateLimiter_ReserveStreamClient interface {
+	Send(*ReserveStreamRequest) error
+	Recv() (*ReserveStreamResponse, error)
+	grpc.ClientStream
+}
+
+type rateLimiterReserveStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *rateLimiterReserveStreamClient) Send(m *ReserveStreamRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *rateLimiterReserveStreamClient) Recv() (*ReserveStreamResponse, error) {
+	m := new(ReserveStreamResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RateLimiterServer is the server API for RateLimiter service.
+// All implementations must embed UnimplementedRateLimiterServer
+// for forward compatibility
+type RateLimiterServer interface {
+	// Reserve reserves capacity, queueing for up to max_wait_ms if set
+	Reserve(context.Context, *ReserveRequest) (*Reservation, error)
+	// Commit settles a pending reservation with the tokens actually used
+	Commit(context.Context, *CommitRequest) (*Settlement, error)
+	// Cancel releases a pending reservation
+	Cancel(context.Context, *CancelRequest) (*Settlement, error)
+	// ReserveStream answers many reservations over one stream. Responses
+	// carry the id of their request and may arrive out of order.
+	ReserveStream(RateLimiter_ReserveStreamServer) error
+	mustEmbedUnimplementedRateLimiterServer()
+}
+
+// UnimplementedRateLimiterServer must be embedded to have forward compatible implementations.
+type UnimplementedRateLimiterServer struct {
+}
+
+func (UnimplementedRateLimiterServer) Reserve(context.Context, *ReserveRequest) (*Reservation, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Reserve not implemented")
+}
+func (UnimplementedRateLimiterServer) Commit(context.Context, *CommitRequest) (*Settlement, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Commit not implemented")
+}
+func (UnimplementedRateLimiterServer) Cancel(context.Context, *CancelRequest) (*Settlement, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Cancel not implemented")
+}
+func (UnimplementedRateLimiterServer) ReserveStream(RateLimiter_ReserveStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method ReserveStream not implemented")
+}
+func (UnimplementedRateLimiterServer) mustEmbedUnimplementedRateLimiterServer() {}
+
+// UnsafeRateLimiterServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to RateLimiterServer will
+// result in compilation errors.
+type UnsafeRateLimiterServer interface {
+	mustEmbedUnimplementedRateLimiterServer()
+}
+
+func RegisterRateLimiterServer(s grpc.ServiceRegistrar, srv RateLimiterServer) {
+	s.RegisterService(&RateLimiter_ServiceDesc, srv)
+}
+
+func _RateLimiter_Reserve_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ReserveRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateLimiterServer).Reserve(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RateLimiter_Reserve_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateLimiterServer).Reserve(ctx, req.(*ReserveRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RateLimiter_Commit_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CommitRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateLimiterServer).Commit(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RateLimiter_Commit_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateLimiterServer).Commit(ctx, req.(*CommitRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RateLimiter_Cancel_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CancelRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(RateLimiterServer).Cancel(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: RateLimiter_Cancel_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(RateLimiterServer).Cancel(ctx, req.(*CancelRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _RateLimiter_ReserveStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(RateLimiterServer).ReserveStream(&rateLimiterReserveStreamServer{stream})
+}
+
+type RateLimiter_ReserveStreamServer interface {
+	Send(*ReserveStreamResponse) error
+	Recv() (*ReserveStreamRequest, error)
+	grpc.ServerStream
+}
+
+type rateLimiterReserveStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *rateLimiterReserveStreamServer) Send(m *ReserveStreamResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *rateLimiterReserveStreamServer) Recv() (*ReserveStreamRequest, error) {
+	m := new(ReserveStreamRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// RateLimiter_ServiceDesc is the grpc.ServiceDesc for RateLimiter service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var RateLimiter_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ratelimiter.v1.RateLimiter",
+	HandlerType: (*RateLimiterServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Reserve",
+			Handler:    _RateLimiter_Reserve_Handler,
+		},
+		{
+			MethodName: "Commit",
+			Handler:    _RateLimiter_Commit_Handler,
+		},
+		{
+			MethodName: "Cancel",
+			Handler:    _RateLimiter_Cancel_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "ReserveStream",
+			Handler:       _RateLimiter_ReserveStream_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "ratelimiter/v1/ratelimiter.proto",
+}
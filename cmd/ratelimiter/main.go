@@ -1,38 +1,255 @@
 package main
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"net"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/cluster"
 	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/internal/envoy"
+	"github.com/yourusername/ratelimiter/internal/grpcserver"
 	"github.com/yourusername/ratelimiter/internal/handlers"
+	"github.com/yourusername/ratelimiter/internal/metrics"
 	"github.com/yourusername/ratelimiter/internal/ratelimiter"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"google.golang.org/grpc"
 )
 
+// configPath is the configuration file loaded at startup and watched for
+// changes
+const configPath = "configs/config.yaml"
+
+// defaultShutdownTimeout is how long shutdown drains in-flight work if no
+// timeout is configured
+const defaultShutdownTimeout = 30 * time.Second
+
 func main() {
 	// Load configuration
-	cfg, err := config.Load("configs/config.yaml")
+	cfg, err := config.Load(configPath)
 	if err != nil {
 		log.Fatalf("Error loading configuration: %v", err)
 	}
 
 	// Initialize rate limiter
-	limiter := ratelimiter.New(cfg.RateLimits)
+	m := metrics.New()
+	limiter, err := ratelimiter.New(cfg, m)
+	if err != nil {
+		log.Fatalf("Error creating rate limiter: %v", err)
+	}
+
+	// Restore counters from the last snapshot, and keep saving them until
+	// shutdown
+	if cfg.Snapshot.Path != "" {
+		if err := limiter.RestoreSnapshot(cfg.Snapshot.Path); err != nil && !errors.Is(err, os.ErrNotExist) {
+			log.Printf("Error restoring snapshot from %s, starting with empty counters: %v", cfg.Snapshot.Path, err)
+		}
+		snapshotter := ratelimit.NewSnapshotter(limiter.Limiter, cfg.Snapshot.Path, cfg.Snapshot.Interval)
+		snapshotter.Start()
+		defer func() {
+			if err := snapshotter.Stop(); err != nil {
+				log.Printf("Error saving snapshot to %s: %v", cfg.Snapshot.Path, err)
+			}
+		}()
+	}
 
 	// Initialize Fiber app
 	app := fiber.New()
 
+	// Join the cluster if configured, routing reservations to key owners
+	var service handlers.Limiter = limiter
+	var node *cluster.Cluster
+	if cfg.Cluster.Self != "" {
+		if cfg.Cluster.Secret == "" {
+			log.Printf("No cluster secret set, accepting any connection to %s as a peer", cfg.Cluster.Self)
+		}
+		node = cluster.New(cfg.Cluster, limiter.Limiter)
+		go func() {
+			if err := node.ListenAndServe(); err != nil {
+				log.Fatalf("Error starting cluster listener: %v", err)
+			}
+		}()
+		node.Start()
+		service = node
+	}
+
 	// Initialize handlers
-	handler := handlers.NewReserveHandler(limiter)
+	handler := handlers.NewReserveHandler(service)
+	handler.SetMetrics(m)
+	handler.SetMaxWait(cfg.Reservations.MaxWait)
+	batchHandler := handlers.NewBatchHandler(service)
+	batchHandler.SetMetrics(m)
+	reservationHandler := handlers.NewReservationHandler(service)
+	quotaHandler := handlers.NewQuotaHandler(service)
+	leaseHandler := handlers.NewLeaseHandler(service)
+
+	// Serve the gRPC API, and the Envoy rate limit service if descriptors
+	// are mapped, alongside the HTTP API if configured
+	var envoyService *envoy.Server
+	var grpcServer *grpc.Server
+	var grpcService *grpcserver.Server
+	if cfg.GRPC.Address != "" {
+		listener, err := net.Listen("tcp", cfg.GRPC.Address)
+		if err != nil {
+			log.Fatalf("Error starting gRPC listener: %v", err)
+		}
+		grpcService = grpcserver.New(service)
+		grpcService.SetMetrics(m)
+		grpcService.SetMaxWait(cfg.Reservations.MaxWait)
+		grpcServer = grpcService.Register()
+		if len(cfg.Envoy.Descriptors) > 0 {
+			envoyService = envoy.New(service, cfg.Envoy.Descriptors)
+			envoyService.Register(grpcServer)
+		}
+		go func() {
+			if err := grpcServer.Serve(listener); err != nil {
+				log.Fatalf("Error serving gRPC: %v", err)
+			}
+		}()
+	}
+
+	// In gateway mode, requests to target endpoints are proxied upstream
+	var gateway *handlers.GatewayHandler
+	if cfg.Gateway.Enabled {
+		gateway = handlers.NewGatewayHandler(service, cfg.Gateway, cfg.TargetEndpoints)
+	}
+
+	// Manage API keys and endpoint limits at runtime if an admin token is set
+	var admin *handlers.AdminHandler
+	if cfg.Admin.Token != "" {
+		admin = handlers.NewAdminHandler(limiter, cfg)
+		if cfg.Admin.Persist {
+			admin.SetPersistPath(configPath)
+		}
+	}
+
+	// Apply limit changes from the config file without restarting
+	watcher := config.NewWatcher(configPath, 0, func(cfg *config.Configuration) {
+		limiter.Reload(cfg)
+		handler.SetMaxWait(cfg.Reservations.MaxWait)
+		if grpcService != nil {
+			grpcService.SetMaxWait(cfg.Reservations.MaxWait)
+		}
+		if gateway != nil {
+			gateway.Update(cfg.Gateway, cfg.TargetEndpoints)
+		}
+		if envoyService != nil {
+			envoyService.Update(cfg.Envoy.Descriptors)
+		}
+		if admin != nil {
+			admin.Update(cfg)
+		}
+	})
+	watcher.Start()
+	defer watcher.Stop()
 
 	// Setup routes
 	app.Post("/reserve", handler.Handle)
+	app.Post("/reserve/batch", batchHandler.Handle)
+	app.Post("/reservations/:id/commit", reservationHandler.Commit)
+	app.Delete("/reservations/:id", reservationHandler.Cancel)
+	app.Get("/quota/:apiKey/*", quotaHandler.Handle)
+	app.Post("/leases", leaseHandler.Lease)
+	app.Post("/leases/:id/return", leaseHandler.Return)
+	app.Get("/metrics", handlers.NewMetricsHandler(m.Registry).Handle)
+	if admin != nil {
+		admin.Register(app.Group("/admin"))
+	}
+	if gateway != nil {
+		app.Use(gateway.Handle)
+	}
+
+	// Watch for shutdown signals before serving, so none are missed
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
 
 	// Start server
 	port := ":8086"
 	fmt.Printf("Server starting on port %s\n", port)
-	if err := app.Listen(port); err != nil {
+	listenErr := make(chan error, 1)
+	go func() {
+		listenErr <- app.Listen(port)
+	}()
+
+	// On SIGINT or SIGTERM, stop accepting and drain in-flight work before
+	// the deferred cleanup saves state. A second signal exits immediately.
+	var sig os.Signal
+	select {
+	case err := <-listenErr:
 		log.Fatalf("Error starting server: %v", err)
+	case sig = <-signals:
+	}
+	go func() {
+		<-signals
+		log.Fatalf("Received second signal, exiting without draining")
+	}()
+
+	timeout := cfg.Shutdown.Timeout
+	if timeout == 0 {
+		timeout = defaultShutdownTimeout
+	}
+	log.Printf("Received %v, draining for up to %v", sig, timeout)
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	drain(ctx, app, grpcServer, node, limiter.Limiter)
+}
+
+// drain stops the servers from accepting requests, then waits until ctx is
+// done for in-flight requests and allowed reservations to finish, logging
+// whatever was dropped
+func drain(ctx context.Context, app *fiber.App, grpcServer *grpc.Server, node *cluster.Cluster, limiter *ratelimit.Limiter) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := app.ShutdownWithContext(ctx); err != nil {
+			log.Printf("Dropped %d HTTP connections still open at the deadline", app.Server().GetOpenConnectionsCount())
+		}
+	}()
+	if grpcServer != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			stopped := make(chan struct{})
+			go func() {
+				grpcServer.GracefulStop()
+				close(stopped)
+			}()
+			select {
+			case <-stopped:
+			case <-ctx.Done():
+				grpcServer.Stop()
+				log.Printf("Cancelled gRPC calls still running at the deadline")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Leave the cluster only once the servers are drained, since requests
+	// in flight may still be forwarded to peers
+	if node != nil {
+		if err := node.Close(); err != nil {
+			log.Printf("Error leaving the cluster: %v", err)
+		}
+	}
+
+	report := limiter.Shutdown(ctx)
+	if report.Unprocessed > 0 {
+		log.Printf("Dropped %d allowed reservations not processed by the deadline", report.Unprocessed)
+	}
+	if report.Pending > 0 {
+		log.Printf("Dropped %d pending reservations that were never committed or cancelled, their usage stays charged", report.Pending)
+	}
+	if report.Leases > 0 {
+		log.Printf("Dropped %d leases that were never returned, their usage stays charged", report.Leases)
 	}
+	log.Printf("Shutdown complete")
 }
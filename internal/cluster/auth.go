@@ -0,0 +1,66 @@
+package cluster
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// errUnauthorized is returned when a peer does not prove it knows the
+// cluster secret
+var errUnauthorized = errors.New("cluster: peer failed authentication")
+
+// challenge checks that a peer connecting to this node knows the cluster
+// secret: it must answer a random nonce with the nonce's HMAC under the
+// secret, so the secret never crosses the network. Without a secret every
+// peer is accepted.
+func (c *Cluster) challenge(conn net.Conn) error {
+	if c.secret == "" {
+		return nil
+	}
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, sha256.Size)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	if _, err := conn.Write(nonce); err != nil {
+		return err
+	}
+	answer := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(conn, answer); err != nil {
+		return err
+	}
+	if !hmac.Equal(answer, c.sign(nonce)) {
+		return errUnauthorized
+	}
+	return nil
+}
+
+// answer answers the challenge of the peer conn was dialed to
+func (c *Cluster) answer(conn net.Conn) error {
+	if c.secret == "" {
+		return nil
+	}
+	conn.SetDeadline(time.Now().Add(c.timeout))
+	defer conn.SetDeadline(time.Time{})
+
+	nonce := make([]byte, sha256.Size)
+	if _, err := io.ReadFull(conn, nonce); err != nil {
+		return err
+	}
+	_, err := conn.Write(c.sign(nonce))
+	return err
+}
+
+// sign returns the HMAC of nonce under the cluster secret
+func (c *Cluster) sign(nonce []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(c.secret))
+	mac.Write(nonce)
+	return mac.Sum(nil)
+}
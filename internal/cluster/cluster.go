@@ -0,0 +1,834 @@
+package cluster
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/rpc"
+	"sync"
+	"time"
+
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// Default cluster timings
+const (
+	defaultHealthCheckInterval = time.Second
+	defaultRequestTimeout      = 500 * time.Millisecond
+)
+
+// errTimeout is returned when a peer does not answer in time. The peer may
+// still have applied the call, so it is not retried on another node.
+var errTimeout = errors.New("cluster: request timed out")
+
+// Cluster routes each limit key to the node that owns it, so a static set
+// of nodes enforces every limit once without an external store
+type Cluster struct {
+	self     string
+	secret   string
+	peers    []string
+	limiter  *ratelimit.Limiter
+	interval time.Duration
+	timeout  time.Duration
+	ring     *Ring
+	alive    map[string]bool
+	clients  map[string]*rpc.Client
+	server   *rpc.Server
+	listener net.Listener
+	conns    map[net.Conn]struct{}
+	done     chan struct{}
+	mutex    sync.RWMutex
+}
+
+// ReserveArgs carries a forwarded reservation
+type ReserveArgs struct {
+	ClientID       string
+	Tokens         int
+	Requests       int
+	APIKey         string
+	TargetEndpoint string
+	MaxWait        time.Duration
+}
+
+// Node is the RPC service each cluster member exposes to its peers
+type Node struct {
+	limiter *ratelimit.Limiter
+}
+
+// Reserve reserves capacity on this node for a key it owns
+func (n *Node) Reserve(args *ReserveArgs, reply *ratelimit.Reservation) error {
+	*reply = *n.limiter.Reserve(args.ClientID, args.Tokens, args.Requests, args.APIKey, args.TargetEndpoint)
+	return nil
+}
+
+// ReserveWait queues a reservation on this node for a key it owns, waiting
+// up to MaxWait for capacity
+func (n *Node) ReserveWait(args *ReserveArgs, reply *ratelimit.Reservation) error {
+	ctx, cancel := context.WithTimeout(context.Background(), args.MaxWait)
+	defer cancel()
+
+	*reply = *n.limiter.ReserveWait(ctx, args.ClientID, args.Tokens, args.Requests, args.APIKey, args.TargetEndpoint)
+	return nil
+}
+
+// BatchArgs carries a forwarded batch of reservations. A batch with a Hold
+// is one part of a batch spanning several nodes, kept pending for at least
+// Hold so it can be cancelled if another part is denied.
+type BatchArgs struct {
+	Requests []ratelimit.BatchRequest
+	Hold     time.Duration
+}
+
+// BatchReply carries the reservations of a forwarded batch
+type BatchReply struct {
+	Reservations []*ratelimit.Reservation
+}
+
+// ReserveBatch reserves a batch on this node for keys it owns
+func (n *Node) ReserveBatch(args *BatchArgs, reply *BatchReply) error {
+	reservations, err := n.limiter.HoldBatch(args.Requests, args.Hold)
+	if err != nil {
+		return err
+	}
+	reply.Reservations = reservations
+	return nil
+}
+
+// QuotaArgs carries a forwarded quota inspection
+type QuotaArgs struct {
+	APIKey   string
+	Endpoint string
+	ClientID string
+}
+
+// QuotaReply carries the quotas of a forwarded inspection
+type QuotaReply struct {
+	Quotas []ratelimit.Quota
+}
+
+// Quota inspects the limits of a key this node owns
+func (n *Node) Quota(args *QuotaArgs, reply *QuotaReply) error {
+	quotas, err := n.limiter.Quota(args.APIKey, args.Endpoint, args.ClientID)
+	if err != nil {
+		return err
+	}
+	reply.Quotas = quotas
+	return nil
+}
+
+// CommitArgs carries a forwarded commit
+type CommitArgs struct {
+	ID     string
+	Tokens int
+}
+
+// Commit commits a reservation pending on this node
+func (n *Node) Commit(args *CommitArgs, reply *ratelimit.Settlement) error {
+	settlement, err := n.limiter.Commit(args.ID, args.Tokens)
+	if err != nil {
+		return err
+	}
+	*reply = *settlement
+	return nil
+}
+
+// Cancel cancels a reservation pending on this node
+func (n *Node) Cancel(id string, reply *ratelimit.Settlement) error {
+	settlement, err := n.limiter.Cancel(id)
+	if err != nil {
+		return err
+	}
+	*reply = *settlement
+	return nil
+}
+
+// LeaseArgs carries a forwarded lease request
+type LeaseArgs struct {
+	ClientID       string
+	Tokens         int
+	Requests       int
+	APIKey         string
+	TargetEndpoint string
+	TTL            time.Duration
+}
+
+// Lease grants a lease on this node for a key it owns
+func (n *Node) Lease(args *LeaseArgs, reply *ratelimit.Lease) error {
+	lease, err := n.limiter.Lease(args.ClientID, args.Tokens, args.Requests, args.APIKey, args.TargetEndpoint, args.TTL)
+	if err != nil {
+		return err
+	}
+	*reply = *lease
+	return nil
+}
+
+// ReturnLeaseArgs carries a forwarded lease return
+type ReturnLeaseArgs struct {
+	ID       string
+	Tokens   int
+	Requests int
+}
+
+// ReturnLease returns a lease held on this node
+func (n *Node) ReturnLease(args *ReturnLeaseArgs, reply *ratelimit.Settlement) error {
+	settlement, err := n.limiter.ReturnLease(args.ID, args.Tokens, args.Requests)
+	if err != nil {
+		return err
+	}
+	*reply = *settlement
+	return nil
+}
+
+// Ping reports that this node is up to the peer at from
+func (n *Node) Ping(from string, reply *bool) error {
+	*reply = true
+	return nil
+}
+
+// New creates a new Cluster instance for the local node in cfg. Every peer
+// is assumed alive until a health check or forwarded call says otherwise.
+func New(cfg config.ClusterConfig, limiter *ratelimit.Limiter) *Cluster {
+	c := &Cluster{
+		self:     cfg.Self,
+		secret:   cfg.Secret,
+		limiter:  limiter,
+		interval: cfg.HealthCheckInterval,
+		timeout:  cfg.RequestTimeout,
+		alive:    make(map[string]bool),
+		clients:  make(map[string]*rpc.Client),
+		server:   rpc.NewServer(),
+		conns:    make(map[net.Conn]struct{}),
+		done:     make(chan struct{}),
+	}
+	if c.interval <= 0 {
+		c.interval = defaultHealthCheckInterval
+	}
+	if c.timeout <= 0 {
+		c.timeout = defaultRequestTimeout
+	}
+
+	c.alive[c.self] = true
+	for _, peer := range cfg.Peers {
+		if peer != c.self {
+			c.peers = append(c.peers, peer)
+			c.alive[peer] = true
+		}
+	}
+	c.rebuild()
+
+	c.server.RegisterName("Cluster", &Node{limiter: limiter})
+	return c
+}
+
+// ListenAndServe accepts peer connections on the local node's address
+func (c *Cluster) ListenAndServe() error {
+	listener, err := net.Listen("tcp", c.self)
+	if err != nil {
+		return err
+	}
+	return c.Serve(listener)
+}
+
+// Serve accepts peer connections on listener until the cluster is closed
+func (c *Cluster) Serve(listener net.Listener) error {
+	c.mutex.Lock()
+	c.listener = listener
+	c.mutex.Unlock()
+
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			select {
+			case <-c.done:
+				return nil
+			default:
+				return err
+			}
+		}
+		go c.serveConn(conn)
+	}
+}
+
+// serveConn serves RPCs from one peer connection, tracking it so Close
+// can disconnect the peer
+func (c *Cluster) serveConn(conn net.Conn) {
+	c.mutex.Lock()
+	select {
+	case <-c.done:
+		c.mutex.Unlock()
+		conn.Close()
+		return
+	default:
+	}
+	c.conns[conn] = struct{}{}
+	c.mutex.Unlock()
+
+	if err := c.challenge(conn); err != nil {
+		log.Printf("Rejected cluster connection from %s: %v", conn.RemoteAddr(), err)
+		conn.Close()
+	} else {
+		c.server.ServeConn(conn)
+	}
+
+	c.mutex.Lock()
+	delete(c.conns, conn)
+	c.mutex.Unlock()
+}
+
+// Start begins health checking peers in the background
+func (c *Cluster) Start() {
+	go func() {
+		ticker := time.NewTicker(c.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				c.checkPeers()
+			case <-c.done:
+				return
+			}
+		}
+	}()
+}
+
+// Close stops health checks, the RPC listener and all peer connections
+func (c *Cluster) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	default:
+	}
+	close(c.done)
+
+	for peer, client := range c.clients {
+		client.Close()
+		delete(c.clients, peer)
+	}
+	for conn := range c.conns {
+		conn.Close()
+	}
+	if c.listener != nil {
+		return c.listener.Close()
+	}
+	return nil
+}
+
+// Owner returns the node currently responsible for key
+func (c *Cluster) Owner(key string) string {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+
+	return c.ring.Owner(key)
+}
+
+// endpointOwner returns the node responsible for the endpoint an API key's
+// target endpoint resolves to, so every path matching a route pattern is
+// limited on the same node
+func (c *Cluster) endpointOwner(apiKey, targetEndpoint string) string {
+	return c.Owner(ratelimit.Key(apiKey, c.limiter.Resolve(apiKey, targetEndpoint)))
+}
+
+// Reserve reserves capacity on the node that owns the key, failing over to
+// the next owner if that node cannot be reached
+func (c *Cluster) Reserve(clientID string, tokens, requests int, apiKey, targetEndpoint string) *ratelimit.Reservation {
+	args := &ReserveArgs{
+		ClientID:       clientID,
+		Tokens:         tokens,
+		Requests:       requests,
+		APIKey:         apiKey,
+		TargetEndpoint: targetEndpoint,
+	}
+	return c.route(args, "Cluster.Reserve", c.timeout, func() *ratelimit.Reservation {
+		return c.limiter.Reserve(clientID, tokens, requests, apiKey, targetEndpoint)
+	})
+}
+
+// ReserveWait queues a reservation on the node that owns the key until
+// capacity frees up or ctx is done
+func (c *Cluster) ReserveWait(ctx context.Context, clientID string, tokens, requests int, apiKey, targetEndpoint string) *ratelimit.Reservation {
+	var maxWait time.Duration
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = time.Until(deadline)
+	}
+	args := &ReserveArgs{
+		ClientID:       clientID,
+		Tokens:         tokens,
+		Requests:       requests,
+		APIKey:         apiKey,
+		TargetEndpoint: targetEndpoint,
+		MaxWait:        maxWait,
+	}
+	return c.route(args, "Cluster.ReserveWait", maxWait+c.timeout, func() *ratelimit.Reservation {
+		return c.limiter.ReserveWait(ctx, clientID, tokens, requests, apiKey, targetEndpoint)
+	})
+}
+
+// ReserveBatch reserves a batch on the node that owns all of its keys,
+// failing over to the next owner if that node cannot be reached. A batch
+// whose keys have different owners is reserved in two phases: each owner
+// holds its part pending, and if any part is denied the parts already held
+// are cancelled, so the batch is still all or nothing. Limits shared
+// across owners, such as the global cap, only see each part's demand.
+func (c *Cluster) ReserveBatch(requests []ratelimit.BatchRequest) ([]*ratelimit.Reservation, error) {
+	if len(requests) == 0 {
+		return nil, ratelimit.ErrEmptyBatch
+	}
+	if len(requests) > ratelimit.MaxBatchSize {
+		return nil, ratelimit.ErrBatchTooLarge
+	}
+
+	for {
+		owners, parts := c.partition(requests)
+		if len(owners) == 1 {
+			reservations, err := c.reserveBatchOn(owners[0], requests, 0)
+			if err == errUnreachable {
+				continue
+			}
+			return reservations, err
+		}
+
+		reservations, err := c.reserveParts(requests, owners, parts)
+		if err == errUnreachable {
+			continue
+		}
+		return reservations, err
+	}
+}
+
+// errUnreachable is returned when a node could not be reached and has been
+// marked down, so the call should be routed again
+var errUnreachable = errors.New("cluster: node unreachable")
+
+// partition groups a batch's requests by the node that owns them, in order
+// of first appearance, returning each part's indices into requests
+func (c *Cluster) partition(requests []ratelimit.BatchRequest) ([]string, [][]int) {
+	var owners []string
+	var parts [][]int
+	index := make(map[string]int)
+	for i, request := range requests {
+		owner := c.endpointOwner(request.APIKey, request.TargetEndpoint)
+		j, seen := index[owner]
+		if !seen {
+			j = len(owners)
+			index[owner] = j
+			owners = append(owners, owner)
+			parts = append(parts, nil)
+		}
+		parts[j] = append(parts[j], i)
+	}
+	return owners, parts
+}
+
+// batchHold is how long each part of a batch spanning several nodes stays
+// pending at most if the node reserving the batch fails before settling it
+const batchHold = 10 * time.Second
+
+// reserveParts holds each part of a batch on its owner, stopping at the
+// first denied part and cancelling the parts already held. If every part
+// is allowed and reservations have no TTL, the held parts are committed so
+// they are final as usual.
+func (c *Cluster) reserveParts(requests []ratelimit.BatchRequest, owners []string, parts [][]int) ([]*ratelimit.Reservation, error) {
+	reservations := make([]*ratelimit.Reservation, len(requests))
+	var held []*ratelimit.Reservation
+	release := func() {
+		for _, reservation := range held {
+			if _, err := c.Cancel(reservation.ReservationID); err != nil {
+				log.Printf("Error cancelling reservation %s: %v", reservation.ReservationID, err)
+			}
+		}
+	}
+
+	for k, owner := range owners {
+		part := make([]ratelimit.BatchRequest, len(parts[k]))
+		for n, i := range parts[k] {
+			part[n] = requests[i]
+		}
+		partReservations, err := c.reserveBatchOn(owner, part, batchHold)
+		if err != nil {
+			release()
+			return nil, err
+		}
+		for n, i := range parts[k] {
+			reservations[i] = partReservations[n]
+		}
+		if !partReservations[0].Allowed {
+			release()
+			return denyParts(reservations, partReservations), nil
+		}
+		held = append(held, partReservations...)
+	}
+
+	if c.limiter.ReservationTTL() <= 0 {
+		for _, reservation := range held {
+			if _, err := c.Commit(reservation.ReservationID, reservation.ReservedTokens); err != nil {
+				log.Printf("Error committing reservation %s: %v", reservation.ReservationID, err)
+			}
+			reservation.ReservationID = ""
+			reservation.ExpiresAt = nil
+		}
+	}
+	return reservations, nil
+}
+
+// denyParts turns the reservations of a batch whose part was denied into
+// denials, with the retry hint of the denied part. Parts that were never
+// tried are denied without budgets.
+func denyParts(reservations, denied []*ratelimit.Reservation) []*ratelimit.Reservation {
+	retryAfterMs := denied[0].RetryAfterMs
+	for i, reservation := range reservations {
+		if reservation == nil {
+			reservations[i] = &ratelimit.Reservation{Allowed: false, RetryAfterMs: retryAfterMs}
+			continue
+		}
+		if reservation.Allowed {
+			reservations[i] = &ratelimit.Reservation{
+				Allowed:           false,
+				RemainingTokens:   reservation.RemainingTokens,
+				RemainingRequests: reservation.RemainingRequests,
+				RequestLimit:      reservation.RequestLimit,
+				ResetMs:           reservation.ResetMs,
+				RetryAfterMs:      retryAfterMs,
+			}
+		}
+	}
+	return reservations
+}
+
+// reserveBatchOn reserves a batch on owner, holding it pending for at
+// least hold. It returns errUnreachable if owner could not be reached.
+func (c *Cluster) reserveBatchOn(owner string, requests []ratelimit.BatchRequest, hold time.Duration) ([]*ratelimit.Reservation, error) {
+	if owner == c.self {
+		return c.limiter.HoldBatch(requests, hold)
+	}
+
+	var reply BatchReply
+	err := c.callTimeout(owner, "Cluster.ReserveBatch", &BatchArgs{Requests: requests, Hold: hold}, &reply, c.timeout)
+	if err == nil {
+		return reply.Reservations, nil
+	}
+	var serverErr rpc.ServerError
+	if errors.As(err, &serverErr) {
+		return nil, serverErr
+	}
+	log.Printf("Error forwarding batch to %s: %v", owner, err)
+	if errors.Is(err, errTimeout) {
+		return nil, err
+	}
+	c.setAlive(owner, false)
+	return nil, errUnreachable
+}
+
+// Quota inspects limits on the nodes that own them, marking each quota
+// with the node it was read from. For an endpoint, every level comes from
+// the endpoint's owner, as the caps that bound reservations on it. For a
+// whole API key, each endpoint is read from its owner, while the global
+// and per-key caps are this node's share of them.
+func (c *Cluster) Quota(apiKey, endpoint, clientID string) ([]ratelimit.Quota, error) {
+	if endpoint != "" {
+		return c.quota(apiKey, endpoint, clientID)
+	}
+
+	quotas, err := c.limiter.Quota(apiKey, "", "")
+	if err != nil {
+		return nil, err
+	}
+	for i, quota := range quotas {
+		quotas[i].Node = c.self
+		route := quotaRoute(quota)
+		if quota.Path == "" || c.Owner(ratelimit.Key(apiKey, route)) == c.self {
+			continue
+		}
+		owned, err := c.quota(apiKey, route, "")
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range owned {
+			if o.Path != "" && quotaRoute(o) == route {
+				quotas[i] = o
+			}
+		}
+	}
+	return quotas, nil
+}
+
+// withNode marks quotas as read from node
+func withNode(quotas []ratelimit.Quota, node string) []ratelimit.Quota {
+	for i := range quotas {
+		quotas[i].Node = node
+	}
+	return quotas
+}
+
+// quotaRoute returns the route of an endpoint's quota
+func quotaRoute(quota ratelimit.Quota) string {
+	return ratelimit.EndpointConfig{Path: quota.Path, Methods: quota.Methods}.Route()
+}
+
+// quota inspects an endpoint's limits on the node that owns it, failing
+// over to the next owner if that node cannot be reached
+func (c *Cluster) quota(apiKey, endpoint, clientID string) ([]ratelimit.Quota, error) {
+	args := &QuotaArgs{APIKey: apiKey, Endpoint: endpoint, ClientID: clientID}
+	for {
+		owner := c.endpointOwner(apiKey, endpoint)
+		if owner == c.self {
+			quotas, err := c.limiter.Quota(apiKey, endpoint, clientID)
+			return withNode(quotas, owner), err
+		}
+
+		var reply QuotaReply
+		err := c.callTimeout(owner, "Cluster.Quota", args, &reply, c.timeout)
+		if err == nil {
+			return withNode(reply.Quotas, owner), nil
+		}
+		var serverErr rpc.ServerError
+		if errors.As(err, &serverErr) {
+			if string(serverErr) == ratelimit.ErrUnknownKey.Error() {
+				return nil, ratelimit.ErrUnknownKey
+			}
+			return nil, serverErr
+		}
+		log.Printf("Error forwarding quota inspection to %s: %v", owner, err)
+		if errors.Is(err, errTimeout) {
+			return nil, err
+		}
+		c.setAlive(owner, false)
+	}
+}
+
+// Commit commits a pending reservation on whichever node holds it. The
+// reservation is tried locally first, then on each live peer.
+func (c *Cluster) Commit(id string, actualTokens int) (*ratelimit.Settlement, error) {
+	return c.settle("Cluster.Commit", &CommitArgs{ID: id, Tokens: actualTokens}, ratelimit.ErrReservationNotFound, func() (*ratelimit.Settlement, error) {
+		return c.limiter.Commit(id, actualTokens)
+	})
+}
+
+// Cancel cancels a pending reservation on whichever node holds it
+func (c *Cluster) Cancel(id string) (*ratelimit.Settlement, error) {
+	return c.settle("Cluster.Cancel", id, ratelimit.ErrReservationNotFound, func() (*ratelimit.Settlement, error) {
+		return c.limiter.Cancel(id)
+	})
+}
+
+// Lease grants a lease on the node that owns the key, so that node's
+// ledger caps every outstanding lease on it, failing over to the next
+// owner if that node cannot be reached
+func (c *Cluster) Lease(clientID string, tokens, requests int, apiKey, targetEndpoint string, ttl time.Duration) (*ratelimit.Lease, error) {
+	args := &LeaseArgs{
+		ClientID:       clientID,
+		Tokens:         tokens,
+		Requests:       requests,
+		APIKey:         apiKey,
+		TargetEndpoint: targetEndpoint,
+		TTL:            ttl,
+	}
+	for {
+		owner := c.endpointOwner(apiKey, targetEndpoint)
+		if owner == c.self {
+			return c.limiter.Lease(clientID, tokens, requests, apiKey, targetEndpoint, ttl)
+		}
+
+		var reply ratelimit.Lease
+		err := c.callTimeout(owner, "Cluster.Lease", args, &reply, c.timeout)
+		if err == nil {
+			return &reply, nil
+		}
+		var serverErr rpc.ServerError
+		if errors.As(err, &serverErr) {
+			switch string(serverErr) {
+			case ratelimit.ErrUnknownKey.Error():
+				return nil, ratelimit.ErrUnknownKey
+			case ratelimit.ErrInvalidLeaseTTL.Error():
+				return nil, ratelimit.ErrInvalidLeaseTTL
+			}
+			return nil, serverErr
+		}
+		log.Printf("Error forwarding lease to %s: %v", owner, err)
+		if errors.Is(err, errTimeout) {
+			return nil, err
+		}
+		c.setAlive(owner, false)
+	}
+}
+
+// ReturnLease returns a lease on whichever node holds it
+func (c *Cluster) ReturnLease(id string, usedTokens, usedRequests int) (*ratelimit.Settlement, error) {
+	args := &ReturnLeaseArgs{ID: id, Tokens: usedTokens, Requests: usedRequests}
+	return c.settle("Cluster.ReturnLease", args, ratelimit.ErrLeaseNotFound, func() (*ratelimit.Settlement, error) {
+		return c.limiter.ReturnLease(id, usedTokens, usedRequests)
+	})
+}
+
+// settle runs local, and on notFound forwards the call to each live peer
+// until one holds the reservation or lease. Both stay on the node that
+// granted them even if ownership of their key moves. If no peer holds it
+// but one timed out, the timeout is returned, as that peer may have
+// settled it.
+func (c *Cluster) settle(method string, args interface{}, notFound error, local func() (*ratelimit.Settlement, error)) (*ratelimit.Settlement, error) {
+	settlement, err := local()
+	if !errors.Is(err, notFound) {
+		return settlement, err
+	}
+
+	c.mutex.RLock()
+	var peers []string
+	for _, peer := range c.peers {
+		if c.alive[peer] {
+			peers = append(peers, peer)
+		}
+	}
+	c.mutex.RUnlock()
+
+	failure := notFound
+	for _, peer := range peers {
+		var reply ratelimit.Settlement
+		err := c.call(peer, method, args, &reply)
+		if err == nil {
+			return &reply, nil
+		}
+		if err.Error() != notFound.Error() {
+			log.Printf("Error forwarding %s to %s: %v", method, peer, err)
+		}
+		if errors.Is(err, errTimeout) {
+			failure = err
+		}
+	}
+	return nil, failure
+}
+
+// route runs local if this node owns the key, and otherwise forwards the
+// call to the owner, failing over to the next owner if it cannot be
+// reached. A call that times out is denied instead, as the owner may
+// already have reserved it.
+func (c *Cluster) route(args *ReserveArgs, method string, timeout time.Duration, local func() *ratelimit.Reservation) *ratelimit.Reservation {
+	for {
+		owner := c.endpointOwner(args.APIKey, args.TargetEndpoint)
+		if owner == c.self {
+			return local()
+		}
+
+		var reservation ratelimit.Reservation
+		err := c.callTimeout(owner, method, args, &reservation, timeout)
+		if err == nil {
+			return &reservation
+		}
+		log.Printf("Error forwarding reservation to %s: %v", owner, err)
+		if errors.Is(err, errTimeout) {
+			return &ratelimit.Reservation{Allowed: false}
+		}
+		c.setAlive(owner, false)
+	}
+}
+
+// checkPeers pings every peer and updates ownership if any joined or left
+func (c *Cluster) checkPeers() {
+	var wg sync.WaitGroup
+	for _, peer := range c.peers {
+		wg.Add(1)
+		go func(peer string) {
+			defer wg.Done()
+			var reply bool
+			err := c.call(peer, "Cluster.Ping", c.self, &reply)
+			c.setAlive(peer, err == nil && reply)
+		}(peer)
+	}
+	wg.Wait()
+}
+
+// setAlive records a peer's health, rebalancing keys if it changed
+func (c *Cluster) setAlive(peer string, alive bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.alive[peer] == alive {
+		return
+	}
+	c.alive[peer] = alive
+	c.rebuild()
+
+	if alive {
+		log.Printf("Cluster peer %s joined", peer)
+	} else {
+		log.Printf("Cluster peer %s left", peer)
+	}
+}
+
+// rebuild recreates the ring from the live members. Callers hold the mutex
+// or have exclusive access.
+func (c *Cluster) rebuild() {
+	members := []string{c.self}
+	for _, peer := range c.peers {
+		if c.alive[peer] {
+			members = append(members, peer)
+		}
+	}
+	c.ring = NewRing(members)
+}
+
+// call invokes method on peer with the default request timeout
+func (c *Cluster) call(peer, method string, args, reply interface{}) error {
+	return c.callTimeout(peer, method, args, reply, c.timeout)
+}
+
+// callTimeout invokes method on peer, dropping the connection on transport
+// errors
+func (c *Cluster) callTimeout(peer, method string, args, reply interface{}, timeout time.Duration) error {
+	client, err := c.client(peer)
+	if err != nil {
+		return err
+	}
+
+	call := client.Go(method, args, reply, make(chan *rpc.Call, 1))
+	select {
+	case <-call.Done:
+		err = call.Error
+	case <-time.After(timeout):
+		err = errTimeout
+	}
+
+	var serverErr rpc.ServerError
+	if err != nil && !errors.As(err, &serverErr) {
+		c.mutex.Lock()
+		if c.clients[peer] == client {
+			delete(c.clients, peer)
+		}
+		c.mutex.Unlock()
+		client.Close()
+	}
+	return err
+}
+
+// client returns a connection to peer, dialing one if needed
+func (c *Cluster) client(peer string) (*rpc.Client, error) {
+	c.mutex.RLock()
+	client, exists := c.clients[peer]
+	c.mutex.RUnlock()
+	if exists {
+		return client, nil
+	}
+
+	conn, err := net.DialTimeout("tcp", peer, c.timeout)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.answer(conn); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	client = rpc.NewClient(conn)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if existing, exists := c.clients[peer]; exists {
+		client.Close()
+		return existing, nil
+	}
+	c.clients[peer] = client
+	return client, nil
+}
@@ -0,0 +1,395 @@
+package cluster
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// startNodes starts n cluster nodes on localhost that know about each other
+func startNodes(t *testing.T, n int) []*Cluster {
+	return startSecretNodes(t, make([]string, n)...)
+}
+
+// startSecretNodes starts a cluster node on localhost for each secret, all
+// knowing about each other
+func startSecretNodes(t *testing.T, secrets ...string) []*Cluster {
+	n := len(secrets)
+	rateLimits := []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{
+					Path: "/test",
+					RPM:  10,
+					TPM:  100,
+				},
+			},
+		},
+	}
+
+	listeners := make([]net.Listener, n)
+	peers := make([]string, n)
+	for i := range listeners {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		listeners[i] = listener
+		peers[i] = listener.Addr().String()
+	}
+
+	nodes := make([]*Cluster, n)
+	for i := range nodes {
+		node := New(config.ClusterConfig{
+			Self:                peers[i],
+			Peers:               peers,
+			Secret:              secrets[i],
+			HealthCheckInterval: 20 * time.Millisecond,
+			RequestTimeout:      200 * time.Millisecond,
+		}, ratelimit.MustNew(ratelimit.Config{RateLimits: rateLimits}))
+		go node.Serve(listeners[i])
+		node.Start()
+		t.Cleanup(func() { node.Close() })
+		nodes[i] = node
+	}
+
+	return nodes
+}
+
+func TestCluster_SharesLimitsAcrossNodes(t *testing.T) {
+	nodes := startNodes(t, 3)
+
+	key := ratelimit.Key("API_KEY_1", "/test")
+	owner := nodes[0].Owner(key)
+	for _, node := range nodes {
+		if node.Owner(key) != owner {
+			t.Fatalf("Expected every node to agree on the owner of %s", key)
+		}
+	}
+
+	allowed := 0
+	for i := 0; i < 15; i++ {
+		if nodes[i%len(nodes)].Reserve("client1", 5, 1, "API_KEY_1", "/test").Allowed {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("Expected 10 requests allowed across nodes, got %d", allowed)
+	}
+}
+
+func TestCluster_RebalancesWhenPeerLeaves(t *testing.T) {
+	nodes := startNodes(t, 3)
+
+	key := ratelimit.Key("API_KEY_1", "/test")
+	owner := nodes[0].Owner(key)
+
+	var survivors []*Cluster
+	for _, node := range nodes {
+		if node.self == owner {
+			node.Close()
+		} else {
+			survivors = append(survivors, node)
+		}
+	}
+
+	// Health checks move the key to a surviving node
+	deadline := time.Now().Add(2 * time.Second)
+	for _, node := range survivors {
+		for node.Owner(key) == owner {
+			if time.Now().After(deadline) {
+				t.Fatalf("Expected %s to stop owning %s", owner, key)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if survivors[0].Owner(key) != survivors[1].Owner(key) {
+		t.Fatal("Expected surviving nodes to agree on the new owner")
+	}
+
+	allowed := 0
+	for i := 0; i < 12; i++ {
+		if survivors[i%len(survivors)].Reserve("client1", 5, 1, "API_KEY_1", "/test").Allowed {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("Expected 10 requests allowed after rebalancing, got %d", allowed)
+	}
+}
+
+func TestCluster_FailsOverOnForwardError(t *testing.T) {
+	nodes := startNodes(t, 2)
+
+	key := ratelimit.Key("API_KEY_1", "/test")
+	owner := nodes[0].Owner(key)
+
+	// Stop the owner and reserve through the other node before any health
+	// check notices
+	var survivor *Cluster
+	for _, node := range nodes {
+		if node.self == owner {
+			node.Close()
+		} else {
+			survivor = node
+		}
+	}
+
+	if !survivor.Reserve("client1", 5, 1, "API_KEY_1", "/test").Allowed {
+		t.Error("Expected the reservation to fail over to a live node")
+	}
+}
+
+func TestCluster_TimeoutKeepsPeerAlive(t *testing.T) {
+	// A peer that accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			t.Cleanup(func() { conn.Close() })
+		}
+	}()
+	silent := listener.Addr().String()
+
+	node := New(config.ClusterConfig{
+		Self:           "127.0.0.1:0",
+		Peers:          []string{silent},
+		RequestTimeout: 50 * time.Millisecond,
+	}, ratelimit.MustNew(ratelimit.Config{}))
+	t.Cleanup(func() { node.Close() })
+
+	apiKey := ""
+	for i := 0; apiKey == ""; i++ {
+		key := fmt.Sprintf("API_KEY_%d", i)
+		if node.Owner(ratelimit.Key(key, "/test")) == silent {
+			apiKey = key
+		}
+	}
+
+	// The peer may have applied a call it did not answer, so the call is
+	// not retried elsewhere and the peer keeps its keys
+	if node.Reserve("client1", 1, 1, apiKey, "/test").Allowed {
+		t.Error("Expected a timed out reservation to be denied")
+	}
+	if _, err := node.Quota(apiKey, "/test", ""); !errors.Is(err, errTimeout) {
+		t.Errorf("Expected a timeout error, got %v", err)
+	}
+	if owner := node.Owner(ratelimit.Key(apiKey, "/test")); owner != silent {
+		t.Errorf("Expected %s to keep its keys after a timeout, got %s", silent, owner)
+	}
+}
+
+func TestCluster_RejectsPeersWithoutSecret(t *testing.T) {
+	nodes := startSecretNodes(t, "secret", "secret", "wrong")
+
+	var reply bool
+	if err := nodes[0].call(nodes[1].self, "Cluster.Ping", nodes[0].self, &reply); err != nil {
+		t.Errorf("Expected peers sharing the secret to connect, got %v", err)
+	}
+	if err := nodes[2].call(nodes[0].self, "Cluster.Ping", nodes[2].self, &reply); err == nil {
+		t.Error("Expected a peer with the wrong secret to be rejected")
+	}
+	if err := nodes[0].call(nodes[2].self, "Cluster.Ping", nodes[0].self, &reply); err == nil {
+		t.Error("Expected a node with the wrong secret to be rejected as a peer")
+	}
+}
+
+func TestCluster_CommitOnGrantingNode(t *testing.T) {
+	nodes := startNodes(t, 3)
+	for _, node := range nodes {
+		node.limiter.SetReservationTTL(time.Minute)
+	}
+
+	// Reserve through one node and commit through every other, so at least
+	// one commit has to find the reservation on a peer
+	reservation := nodes[0].Reserve("client1", 100, 1, "API_KEY_1", "/test")
+	if !reservation.Allowed || reservation.ReservationID == "" {
+		t.Fatalf("Expected an allowed pending reservation, got %+v", reservation)
+	}
+
+	settlement, err := nodes[1].Commit(reservation.ReservationID, 40)
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if settlement.RefundedTokens != 60 {
+		t.Errorf("Expected 60 refunded tokens, got %d", settlement.RefundedTokens)
+	}
+	if _, err := nodes[2].Cancel(reservation.ReservationID); !errors.Is(err, ratelimit.ErrReservationNotFound) {
+		t.Errorf("Expected ErrReservationNotFound after commit, got %v", err)
+	}
+
+	if next := nodes[2].Reserve("client1", 60, 1, "API_KEY_1", "/test"); !next.Allowed {
+		t.Errorf("Expected the refunded tokens to be reservable, got %+v", next)
+	}
+}
+
+func TestCluster_ReserveBatch(t *testing.T) {
+	nodes := startNodes(t, 3)
+
+	// Batches on one key run on its owner, whichever node receives them
+	batch := []ratelimit.BatchRequest{
+		{ClientID: "client1", Tokens: 10, Requests: 3, APIKey: "API_KEY_1", TargetEndpoint: "/test"},
+		{ClientID: "client2", Tokens: 10, Requests: 3, APIKey: "API_KEY_1", TargetEndpoint: "/test"},
+	}
+	reservations, err := nodes[0].ReserveBatch(batch)
+	if err != nil {
+		t.Fatalf("ReserveBatch failed: %v", err)
+	}
+	if !reservations[0].Allowed || !reservations[1].Allowed {
+		t.Fatalf("Expected the first batch to be allowed, got %+v and %+v", reservations[0], reservations[1])
+	}
+	reservations, err = nodes[1].ReserveBatch(batch)
+	if err != nil {
+		t.Fatalf("ReserveBatch failed: %v", err)
+	}
+	if reservations[0].Allowed || reservations[1].Allowed {
+		t.Errorf("Expected the second batch to be denied by the shared limit, got %+v and %+v", reservations[0], reservations[1])
+	}
+
+	// Find an endpoint owned by another node
+	owner := nodes[0].Owner(ratelimit.Key("API_KEY_1", "/test"))
+	other := ""
+	for i := 0; other == ""; i++ {
+		path := fmt.Sprintf("/other%d", i)
+		if nodes[0].Owner(ratelimit.Key("API_KEY_1", path)) != owner {
+			other = path
+		}
+	}
+	for _, node := range nodes {
+		node.limiter.UpdateLimits(config.LimitConfig{}, []config.RateLimit{{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/test", RPM: 10, TPM: 100},
+				{Path: other, RPM: 2, TPM: 100},
+			},
+		}})
+	}
+
+	// Batches spanning owners are reserved in parts, all or nothing
+	spanning := []ratelimit.BatchRequest{
+		{ClientID: "client1", Tokens: 1, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/test"},
+		{ClientID: "client1", Tokens: 1, Requests: 2, APIKey: "API_KEY_1", TargetEndpoint: other},
+	}
+	reservations, err = nodes[0].ReserveBatch(spanning)
+	if err != nil {
+		t.Fatalf("ReserveBatch failed: %v", err)
+	}
+	if !reservations[0].Allowed || !reservations[1].Allowed {
+		t.Fatalf("Expected the spanning batch to be allowed, got %+v and %+v", reservations[0], reservations[1])
+	}
+	if reservations[0].ReservationID != "" {
+		t.Errorf("Expected parts to be final without a reservation TTL, got ID %s", reservations[0].ReservationID)
+	}
+
+	reservations, err = nodes[1].ReserveBatch(spanning)
+	if err != nil {
+		t.Fatalf("ReserveBatch failed: %v", err)
+	}
+	if reservations[0].Allowed || reservations[1].Allowed {
+		t.Fatalf("Expected the second spanning batch to be denied, got %+v and %+v", reservations[0], reservations[1])
+	}
+	quotas, err := nodes[2].Quota("API_KEY_1", "/test", "")
+	if err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	if used := quotas[len(quotas)-1].Requests.Used; used != 7 {
+		t.Errorf("Expected the denied batch's part on /test to be cancelled, leaving 7 requests used, got %d", used)
+	}
+}
+
+func TestCluster_LeaseOnOwner(t *testing.T) {
+	nodes := startNodes(t, 3)
+
+	// Leases through any node are capped by the owner's ledger
+	granted := 0
+	var leases []*ratelimit.Lease
+	for _, node := range nodes {
+		lease, err := node.Lease("client1", 10, 4, "API_KEY_1", "/test", time.Minute)
+		if err != nil {
+			t.Fatalf("Lease failed: %v", err)
+		}
+		if lease.Granted {
+			granted++
+			leases = append(leases, lease)
+		}
+	}
+	if granted != 2 {
+		t.Fatalf("Expected 2 leases of 4 requests within a limit of 10, got %d", granted)
+	}
+
+	// Leases are returned on whichever node granted them
+	for i, lease := range leases {
+		settlement, err := nodes[(i+1)%len(nodes)].ReturnLease(lease.LeaseID, 0, 0)
+		if err != nil {
+			t.Fatalf("ReturnLease failed: %v", err)
+		}
+		if settlement.RefundedRequests != 4 {
+			t.Errorf("Expected 4 refunded requests, got %d", settlement.RefundedRequests)
+		}
+	}
+	if _, err := nodes[0].ReturnLease(leases[0].LeaseID, 0, 0); !errors.Is(err, ratelimit.ErrLeaseNotFound) {
+		t.Errorf("Expected ErrLeaseNotFound after return, got %v", err)
+	}
+
+	if _, err := nodes[1].Lease("client1", 10, 4, "INVALID_KEY", "/test", time.Minute); !errors.Is(err, ratelimit.ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestCluster_QuotaMarksNodes(t *testing.T) {
+	nodes := startNodes(t, 3)
+	for _, node := range nodes {
+		node.limiter.UpdateLimits(config.LimitConfig{RPM: 100}, []config.RateLimit{{
+			APIKey:    "API_KEY_1",
+			Endpoints: []config.EndpointConfig{{Path: "/test", RPM: 10, TPM: 100}},
+		}})
+	}
+
+	owner := nodes[0].Owner(ratelimit.Key("API_KEY_1", "/test"))
+	var other *Cluster
+	for _, node := range nodes {
+		if node.self != owner {
+			other = node
+		}
+	}
+	other.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+
+	// Every level of an endpoint comes from its owner
+	quotas, err := other.Quota("API_KEY_1", "/test", "")
+	if err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	for _, quota := range quotas {
+		if quota.Node != owner || quota.Requests.Used != 1 {
+			t.Errorf("Expected the %s quota from %s with 1 request used, got %+v", quota.Level, owner, quota)
+		}
+	}
+
+	// A whole key's aggregate caps are the answering node's own
+	quotas, err = other.Quota("API_KEY_1", "", "")
+	if err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	for _, quota := range quotas {
+		want := other.self
+		if quota.Path != "" {
+			want = owner
+		}
+		if quota.Node != want {
+			t.Errorf("Expected the %s quota from %s, got %s", quota.Level, want, quota.Node)
+		}
+	}
+}
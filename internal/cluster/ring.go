@@ -0,0 +1,53 @@
+package cluster
+
+import (
+	"hash/crc32"
+	"sort"
+	"strconv"
+)
+
+// defaultReplicas is the number of virtual nodes per member, which evens
+// out how many keys each member owns
+const defaultReplicas = 128
+
+// Ring assigns keys to members with consistent hashing, so adding or
+// removing a member only moves the keys that member gains or loses
+type Ring struct {
+	replicas int
+	hashes   []uint32
+	owners   map[uint32]string
+}
+
+// NewRing creates a new Ring instance over members
+func NewRing(members []string) *Ring {
+	ring := &Ring{
+		replicas: defaultReplicas,
+		owners:   make(map[uint32]string),
+	}
+
+	for _, member := range members {
+		for i := 0; i < ring.replicas; i++ {
+			hash := crc32.ChecksumIEEE([]byte(strconv.Itoa(i) + "#" + member))
+			ring.hashes = append(ring.hashes, hash)
+			ring.owners[hash] = member
+		}
+	}
+	sort.Slice(ring.hashes, func(i, j int) bool { return ring.hashes[i] < ring.hashes[j] })
+
+	return ring
+}
+
+// Owner returns the member responsible for key, or an empty string if the
+// ring has no members
+func (r *Ring) Owner(key string) string {
+	if len(r.hashes) == 0 {
+		return ""
+	}
+
+	hash := crc32.ChecksumIEEE([]byte(key))
+	i := sort.Search(len(r.hashes), func(i int) bool { return r.hashes[i] >= hash })
+	if i == len(r.hashes) {
+		i = 0
+	}
+	return r.owners[r.hashes[i]]
+}
@@ -0,0 +1,48 @@
+package cluster
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestRing_Owner(t *testing.T) {
+	if owner := NewRing(nil).Owner("key"); owner != "" {
+		t.Errorf("Expected no owner on an empty ring, got %s", owner)
+	}
+
+	members := []string{"node-a", "node-b", "node-c"}
+	ring := NewRing(members)
+
+	// Every member owns a fair share of keys
+	counts := make(map[string]int)
+	for i := 0; i < 3000; i++ {
+		counts[ring.Owner(fmt.Sprintf("API_KEY_%d-/api/endpoint", i))]++
+	}
+	for _, member := range members {
+		if counts[member] < 600 {
+			t.Errorf("Expected %s to own a fair share of keys, got %d of 3000", member, counts[member])
+		}
+	}
+
+	// Ownership is deterministic
+	if NewRing(members).Owner("key") != ring.Owner("key") {
+		t.Error("Expected rings over the same members to agree")
+	}
+}
+
+func TestRing_Rebalance(t *testing.T) {
+	before := NewRing([]string{"node-a", "node-b", "node-c"})
+	after := NewRing([]string{"node-a", "node-b"})
+
+	// Removing a member only moves the keys it owned
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("API_KEY_%d-/api/endpoint", i)
+		owner := before.Owner(key)
+		if owner != "node-c" && after.Owner(key) != owner {
+			t.Errorf("Key %s moved from %s to %s", key, owner, after.Owner(key))
+		}
+		if after.Owner(key) == "node-c" {
+			t.Errorf("Key %s still owned by the removed member", key)
+		}
+	}
+}
@@ -1,27 +1,181 @@
 package config
 
 import (
+	"fmt"
+	"net/url"
 	"os"
+	"strings"
+	"time"
 
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
 	"gopkg.in/yaml.v2"
 )
 
+// Supported rate limiting algorithms
+const (
+	AlgorithmFixedWindow          = ratelimit.AlgorithmFixedWindow
+	AlgorithmTokenBucket          = ratelimit.AlgorithmTokenBucket
+	AlgorithmSlidingWindowLog     = ratelimit.AlgorithmSlidingWindowLog
+	AlgorithmSlidingWindowCounter = ratelimit.AlgorithmSlidingWindowCounter
+	AlgorithmGCRA                 = ratelimit.AlgorithmGCRA
+)
+
+// Supported counter store backends
+const (
+	StoreMemory = ratelimit.StoreMemory
+	StoreRedis  = ratelimit.StoreRedis
+)
+
+// Supported dispatch modes for priority classes
+const (
+	DispatchImmediate  = ratelimit.DispatchImmediate
+	DispatchDelayed    = ratelimit.DispatchDelayed
+	DispatchBackground = ratelimit.DispatchBackground
+)
+
+// AnyClient is the ClientLimit ClientID that applies to every client
+// without a limit of its own
+const AnyClient = ratelimit.AnyClient
+
+// The limits and their parts are those of the public limiter package, so
+// that the configuration file can be handed to it directly
+type (
+	LimitConfig    = ratelimit.LimitConfig
+	RateLimit      = ratelimit.RateLimit
+	EndpointConfig = ratelimit.EndpointConfig
+	ClientLimit    = ratelimit.ClientLimit
+	DispatchPolicy = ratelimit.DispatchPolicy
+	StoreConfig    = ratelimit.StoreConfig
+)
+
 // Configuration represents the main configuration structure
 type Configuration struct {
-	RateLimits []RateLimit `yaml:"rateLimits"`
+	GlobalLimit      LimitConfig            `yaml:"globalLimit"`
+	RateLimits       []RateLimit            `yaml:"rateLimits"`
+	PriorityClasses  map[string]int         `yaml:"priorityClasses"`
+	DispatchPolicies map[int]DispatchPolicy `yaml:"dispatchPolicies"`
+	Reservations     ReservationConfig      `yaml:"reservations"`
+	Store            StoreConfig            `yaml:"store"`
+	Snapshot         SnapshotConfig         `yaml:"snapshot"`
+	Shutdown         ShutdownConfig         `yaml:"shutdown"`
+	Cluster          ClusterConfig          `yaml:"cluster"`
+	Gateway          GatewayConfig          `yaml:"gateway"`
+	GRPC             GRPCConfig             `yaml:"grpc"`
+	Envoy            EnvoyConfig            `yaml:"envoy"`
+	Admin            AdminConfig            `yaml:"admin"`
+	TargetEndpoints  []TargetEndpoint       `yaml:"targetEndpoints"`
+}
+
+// DefaultMaxWait is how long reservations may wait for capacity when
+// MaxWait is not set
+const DefaultMaxWait = 30 * time.Second
+
+// ReservationConfig controls the reservation lifecycle. The TTL is off by
+// default, making reservations final once allowed. With a TTL, each
+// allowed reservation stays pending until it is committed with its actual
+// token usage or cancelled, and is refunded if the TTL passes first.
+// MaxWait caps how long a reservation may queue for capacity; longer
+// maxWaitMs values are cut down to it.
+type ReservationConfig struct {
+	TTL     time.Duration `yaml:"ttl"`
+	MaxWait time.Duration `yaml:"maxWait"`
+}
+
+// ClampWait converts a requested wait in milliseconds to a duration of at
+// most maxWait, or of at most DefaultMaxWait if maxWait is not positive
+func ClampWait(ms int64, maxWait time.Duration) time.Duration {
+	if maxWait <= 0 {
+		maxWait = DefaultMaxWait
+	}
+	if wait := time.Duration(ms) * time.Millisecond; wait < maxWait {
+		return wait
+	}
+	return maxWait
+}
+
+// SnapshotConfig saves the in-memory counters to Path every Interval and on
+// shutdown, and restores them on startup, so that restarts do not hand out
+// a fresh budget. Snapshots are disabled if Path is empty.
+type SnapshotConfig struct {
+	Path     string        `yaml:"path"`
+	Interval time.Duration `yaml:"interval"`
+}
+
+// ShutdownConfig bounds how long the server drains in-flight requests and
+// dispatched reservations after SIGINT or SIGTERM before exiting anyway
+type ShutdownConfig struct {
+	Timeout time.Duration `yaml:"timeout"`
+}
+
+// ClusterConfig enables peer-to-peer cluster mode when Self is set. Each
+// limit key is owned by one live peer, and reservations for keys owned by
+// another peer are forwarded to it. Peers must share Secret to connect to
+// each other; without one, any connection to Self is accepted.
+type ClusterConfig struct {
+	Self                string        `yaml:"self"`
+	Secret              string        `yaml:"secret"`
+	Peers               []string      `yaml:"peers"`
+	HealthCheckInterval time.Duration `yaml:"healthCheckInterval"`
+	RequestTimeout      time.Duration `yaml:"requestTimeout"`
+}
+
+// GatewayConfig enables gateway mode, in which requests to the target
+// endpoints are charged against the caller's API key and proxied to an
+// upstream service. Empty headers and timeouts take the gateway defaults.
+// MinTokens is the least a request is charged whatever its tokens header
+// says, since callers set that header themselves.
+type GatewayConfig struct {
+	Enabled        bool          `yaml:"enabled"`
+	Upstream       string        `yaml:"upstream"`
+	APIKeyHeader   string        `yaml:"apiKeyHeader"`
+	ClientIDHeader string        `yaml:"clientIDHeader"`
+	TokensHeader   string        `yaml:"tokensHeader"`
+	UsageHeader    string        `yaml:"usageHeader"`
+	MinTokens      int           `yaml:"minTokens"`
+	Timeout        time.Duration `yaml:"timeout"`
+}
+
+// GRPCConfig enables the gRPC API on Address alongside the HTTP API
+type GRPCConfig struct {
+	Address string `yaml:"address"`
+}
+
+// EnvoyConfig enables the Envoy rate limit service on the gRPC server,
+// mapping the descriptors Envoy sends to API keys and endpoints
+type EnvoyConfig struct {
+	Descriptors []DescriptorMapping `yaml:"descriptors"`
+}
+
+// DescriptorMapping maps Envoy descriptors to an API key's endpoint. The
+// API key, endpoint and client ID are each either fixed or taken from the
+// value of the descriptor entry with the given key. A descriptor matches if
+// its domain matches (an empty Domain matches any) and it has every entry
+// the mapping reads from; the first matching mapping applies.
+type DescriptorMapping struct {
+	Domain        string `yaml:"domain"`
+	APIKey        string `yaml:"apiKey"`
+	APIKeyEntry   string `yaml:"apiKeyEntry"`
+	Endpoint      string `yaml:"endpoint"`
+	EndpointEntry string `yaml:"endpointEntry"`
+	ClientID      string `yaml:"clientID"`
+	ClientIDEntry string `yaml:"clientIDEntry"`
 }
 
-// RateLimit represents rate limiting configuration for an API key
-type RateLimit struct {
-	APIKey    string           `yaml:"apiKey"`
-	Endpoints []EndpointConfig `yaml:"endpoints"`
+// AdminConfig enables the /admin API when Token is set; requests must
+// present it as a bearer token. With Persist, changes made through the API
+// are written back to the configuration file.
+type AdminConfig struct {
+	Token   string `yaml:"token"`
+	Persist bool   `yaml:"persist"`
 }
 
-// EndpointConfig represents configuration for a specific endpoint
-type EndpointConfig struct {
-	Path string `yaml:"path"`
-	RPM  int    `yaml:"rpm"`
-	TPM  int    `yaml:"tpm"`
+// TargetEndpoint is a path served behind the gateway. Requests to it, or
+// below it, are charged against the API key's limits for Path and proxied
+// to Upstream, or to the gateway's default upstream if Upstream is empty.
+type TargetEndpoint struct {
+	Path     string `yaml:"path"`
+	Handler  string `yaml:"handler"`
+	Upstream string `yaml:"upstream"`
 }
 
 // Load reads and parses the configuration file
@@ -36,5 +190,101 @@ func Load(filepath string) (*Configuration, error) {
 		return nil, err
 	}
 
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
 	return &config, nil
 }
+
+// Validate checks the configuration for values the rate limiter cannot use
+func (c *Configuration) Validate() error {
+	if err := c.LimiterConfig().Validate(); err != nil {
+		return err
+	}
+
+	if c.Snapshot.Path != "" && c.Store.Type == StoreRedis {
+		return fmt.Errorf("snapshot: only the memory store can be snapshotted")
+	}
+	if c.Snapshot.Interval < 0 {
+		return fmt.Errorf("snapshot: interval must be non-negative")
+	}
+	if c.Shutdown.Timeout < 0 {
+		return fmt.Errorf("shutdown: timeout must be non-negative")
+	}
+
+	if c.Cluster.Self == "" && len(c.Cluster.Peers) > 0 {
+		return fmt.Errorf("cluster: peers require self to be set")
+	}
+
+	if err := c.validateGateway(); err != nil {
+		return fmt.Errorf("gateway: %v", err)
+	}
+
+	if len(c.Envoy.Descriptors) > 0 && c.GRPC.Address == "" {
+		return fmt.Errorf("envoy: descriptors require grpc.address to be set")
+	}
+	for i, mapping := range c.Envoy.Descriptors {
+		if (mapping.APIKey == "") == (mapping.APIKeyEntry == "") {
+			return fmt.Errorf("envoy descriptor %d: set exactly one of apiKey and apiKeyEntry", i)
+		}
+		if (mapping.Endpoint == "") == (mapping.EndpointEntry == "") {
+			return fmt.Errorf("envoy descriptor %d: set exactly one of endpoint and endpointEntry", i)
+		}
+	}
+	return nil
+}
+
+// LimiterConfig returns the settings of the rate limiter itself
+func (c *Configuration) LimiterConfig() ratelimit.Config {
+	return ratelimit.Config{
+		GlobalLimit:      c.GlobalLimit,
+		RateLimits:       c.RateLimits,
+		PriorityClasses:  c.PriorityClasses,
+		DispatchPolicies: c.DispatchPolicies,
+		ReservationTTL:   c.Reservations.TTL,
+		Store:            c.Store,
+	}
+}
+
+// validateGateway checks that every target endpoint has a usable upstream
+// when gateway mode is enabled
+func (c *Configuration) validateGateway() error {
+	if !c.Gateway.Enabled {
+		return nil
+	}
+	if c.Gateway.Timeout < 0 {
+		return fmt.Errorf("timeout must be non-negative")
+	}
+	if c.Gateway.MinTokens < 0 {
+		return fmt.Errorf("minTokens must be non-negative")
+	}
+	if c.Gateway.Upstream != "" {
+		if err := validateUpstream(c.Gateway.Upstream); err != nil {
+			return err
+		}
+	}
+	for _, target := range c.TargetEndpoints {
+		if !strings.HasPrefix(target.Path, "/") {
+			return fmt.Errorf("target endpoint %q: path must start with /", target.Path)
+		}
+		if target.Upstream == "" && c.Gateway.Upstream == "" {
+			return fmt.Errorf("target endpoint %s: no upstream configured", target.Path)
+		}
+		if target.Upstream != "" {
+			if err := validateUpstream(target.Upstream); err != nil {
+				return fmt.Errorf("target endpoint %s: %v", target.Path, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateUpstream checks that an upstream is an absolute http(s) URL
+func validateUpstream(upstream string) error {
+	u, err := url.Parse(upstream)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("upstream %q must be an http or https URL", upstream)
+	}
+	return nil
+}
@@ -3,16 +3,30 @@ package config
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestLoad(t *testing.T) {
 	// Create a temporary config file
-	content := `rateLimits:
+	content := `globalLimit:
+  rpm: 1000
+rateLimits:
   - apiKey: TEST_KEY
+    tpm: 50
     endpoints:
       - path: /test
         rpm: 100
-        tpm: 10`
+        tpm: 10
+        algorithm: gcra
+        clientLimits:
+          - clientID: "*"
+            fraction: 0.25
+priorityClasses:
+  TEST_KEY: 2
+dispatchPolicies:
+  2:
+    mode: delayed
+    delay: 5s`
 
 	tmpfile, err := os.CreateTemp("", "config-*.yaml")
 	if err != nil {
@@ -45,6 +59,12 @@ func TestLoad(t *testing.T) {
 				if cfg.RateLimits[0].APIKey != "TEST_KEY" {
 					t.Errorf("Expected API key TEST_KEY, got %s", cfg.RateLimits[0].APIKey)
 				}
+				if cfg.GlobalLimit.RPM != 1000 {
+					t.Errorf("Expected global RPM 1000, got %d", cfg.GlobalLimit.RPM)
+				}
+				if limits := cfg.RateLimits[0].Limits(); limits.TPM != 50 || limits.RPM != 0 {
+					t.Errorf("Expected an API key cap of 50 TPM only, got %+v", limits)
+				}
 				if len(cfg.RateLimits[0].Endpoints) != 1 {
 					t.Error("Expected 1 endpoint configuration")
 				}
@@ -58,8 +78,135 @@ func TestLoad(t *testing.T) {
 				if endpoint.TPM != 10 {
 					t.Errorf("Expected TPM 10, got %d", endpoint.TPM)
 				}
+				if endpoint.Algorithm != "gcra" {
+					t.Errorf("Expected algorithm gcra, got %s", endpoint.Algorithm)
+				}
+				if len(endpoint.ClientLimits) != 1 || endpoint.ClientLimits[0].Fraction != 0.25 {
+					t.Errorf("Expected one client limit with fraction 0.25, got %+v", endpoint.ClientLimits)
+				}
+				if cfg.PriorityClasses["TEST_KEY"] != 2 {
+					t.Errorf("Expected priority class 2, got %d", cfg.PriorityClasses["TEST_KEY"])
+				}
+				policy := cfg.DispatchPolicies[2]
+				if policy.Mode != DispatchDelayed || policy.Delay != 5*time.Second {
+					t.Errorf("Expected delayed dispatch after 5s, got %s after %v", policy.Mode, policy.Delay)
+				}
 			},
 		},
+		{
+			name: "Unknown algorithm",
+			path: func() string {
+				f, _ := os.CreateTemp("", "algorithm-*.yaml")
+				f.Write([]byte(`rateLimits:
+  - apiKey: TEST_KEY
+    endpoints:
+      - path: /test
+        rpm: 100
+        tpm: 10
+        algorithm: leaky_faucet`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
+		{
+			name: "Delayed dispatch without delay",
+			path: func() string {
+				f, _ := os.CreateTemp("", "dispatch-*.yaml")
+				f.Write([]byte(`dispatchPolicies:
+  2:
+    mode: delayed`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
+		{
+			name: "Client limit with fraction and rpm",
+			path: func() string {
+				f, _ := os.CreateTemp("", "client-*.yaml")
+				f.Write([]byte(`rateLimits:
+  - apiKey: TEST_KEY
+    endpoints:
+      - path: /test
+        rpm: 100
+        tpm: 10
+        clientLimits:
+          - clientID: noisy
+            rpm: 10
+            fraction: 0.5`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
+		{
+			name: "Gateway target without upstream",
+			path: func() string {
+				f, _ := os.CreateTemp("", "gateway-*.yaml")
+				f.Write([]byte(`gateway:
+  enabled: true
+targetEndpoints:
+  - path: /api/endpoint1
+    handler: endpoint1Handler`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
+		{
+			name: "Envoy descriptors without gRPC address",
+			path: func() string {
+				f, _ := os.CreateTemp("", "envoy-*.yaml")
+				f.Write([]byte(`envoy:
+  descriptors:
+    - domain: edge
+      apiKeyEntry: api_key
+      endpointEntry: path`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
+		{
+			name: "Snapshot with Redis store",
+			path: func() string {
+				f, _ := os.CreateTemp("", "snapshot-*.yaml")
+				f.Write([]byte(`store:
+  type: redis
+  address: localhost:6379
+snapshot:
+  path: ratelimiter.snapshot`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
+		{
+			name: "Negative shutdown timeout",
+			path: func() string {
+				f, _ := os.CreateTemp("", "shutdown-*.yaml")
+				f.Write([]byte(`shutdown:
+  timeout: -1s`))
+				name := f.Name()
+				f.Close()
+				return name
+			}(),
+			wantErr: true,
+			check:   nil,
+		},
 		{
 			name:    "Non-existent file",
 			path:    "nonexistent.yaml",
@@ -93,3 +240,24 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+func TestClampWait(t *testing.T) {
+	tests := []struct {
+		name    string
+		ms      int64
+		maxWait time.Duration
+		want    time.Duration
+	}{
+		{name: "Under the cap", ms: 500, maxWait: time.Second, want: 500 * time.Millisecond},
+		{name: "Over the cap", ms: 5000, maxWait: time.Second, want: time.Second},
+		{name: "Default cap", ms: 600000, want: DefaultMaxWait},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClampWait(tt.ms, tt.maxWait); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v2"
+)
+
+// rateLimitsKey is the top-level key rate limits are stored under
+const rateLimitsKey = "rateLimits"
+
+// SaveRateLimits replaces the rate limits in the configuration file at path,
+// keeping every other setting and the order of top-level keys. Comments are
+// not preserved. The file is replaced atomically, so a watcher never sees
+// it half written.
+func SaveRateLimits(path string, rateLimits []RateLimit) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var document yaml.MapSlice
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return err
+	}
+
+	replaced := false
+	for i, item := range document {
+		if item.Key == rateLimitsKey {
+			document[i].Value = rateLimits
+			replaced = true
+		}
+	}
+	if !replaced {
+		document = append(document, yaml.MapItem{Key: rateLimitsKey, Value: rateLimits})
+	}
+
+	data, err = yaml.Marshal(document)
+	if err != nil {
+		return err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(info.Mode()); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveRateLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{
+			name: "Replaces existing rate limits",
+			content: `globalLimit:
+  rpm: 1000
+rateLimits:
+  - apiKey: OLD_KEY
+    endpoints:
+      - path: /old
+        rpm: 1
+        tpm: 1
+reservations:
+  ttl: 30s
+`,
+		},
+		{
+			name: "Adds missing rate limits",
+			content: `globalLimit:
+  rpm: 1000
+reservations:
+  ttl: 30s
+`,
+		},
+	}
+
+	rateLimits := []RateLimit{
+		{
+			APIKey:    "NEW_KEY",
+			TPM:       500,
+			Endpoints: []EndpointConfig{{Path: "/new", RPM: 10, TPM: 100, ClientLimits: []ClientLimit{{ClientID: AnyClient, Fraction: 0.5}}}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(tt.content), 0o600); err != nil {
+				t.Fatalf("Failed to write config: %v", err)
+			}
+
+			if err := SaveRateLimits(path, rateLimits); err != nil {
+				t.Fatalf("SaveRateLimits failed: %v", err)
+			}
+
+			cfg, err := Load(path)
+			if err != nil {
+				t.Fatalf("Failed to load saved config: %v", err)
+			}
+			if len(cfg.RateLimits) != 1 || cfg.RateLimits[0].APIKey != "NEW_KEY" || cfg.RateLimits[0].TPM != 500 {
+				t.Fatalf("Expected only NEW_KEY with 500 TPM, got %+v", cfg.RateLimits)
+			}
+			if client := cfg.RateLimits[0].Endpoints[0].ClientLimits[0]; client.Fraction != 0.5 {
+				t.Errorf("Expected a client fraction of 0.5, got %+v", client)
+			}
+			if cfg.GlobalLimit.RPM != 1000 || cfg.Reservations.TTL.String() != "30s" {
+				t.Errorf("Expected other settings to be kept, got %+v and %v", cfg.GlobalLimit, cfg.Reservations.TTL)
+			}
+
+			data, _ := os.ReadFile(path)
+			if !strings.HasPrefix(string(data), "globalLimit:") {
+				t.Errorf("Expected top-level keys to keep their order, got:\n%s", data)
+			}
+			if info, _ := os.Stat(path); info.Mode().Perm() != 0o600 {
+				t.Errorf("Expected file mode 0600 to be kept, got %v", info.Mode().Perm())
+			}
+		})
+	}
+}
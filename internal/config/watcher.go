@@ -0,0 +1,122 @@
+package config
+
+import (
+	"bytes"
+	"log"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// defaultWatchInterval is how often the watcher checks the file
+const defaultWatchInterval = 2 * time.Second
+
+// Watcher reloads a configuration file when it changes on disk or the
+// process receives SIGHUP. Only configurations that load and validate are
+// passed on; the previous one stays in effect otherwise.
+type Watcher struct {
+	path     string
+	interval time.Duration
+	onChange func(*Configuration)
+	modTime  time.Time
+	data     []byte
+	signals  chan os.Signal
+	done     chan struct{}
+	once     sync.Once
+	mutex    sync.Mutex
+}
+
+// NewWatcher creates a new Watcher instance for the file at path. onChange
+// is called with each new valid configuration.
+func NewWatcher(path string, interval time.Duration, onChange func(*Configuration)) *Watcher {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+
+	w := &Watcher{
+		path:     path,
+		interval: interval,
+		onChange: onChange,
+		signals:  make(chan os.Signal, 1),
+		done:     make(chan struct{}),
+	}
+	if info, err := os.Stat(path); err == nil {
+		w.modTime = info.ModTime()
+	}
+	w.data, _ = os.ReadFile(path)
+
+	return w
+}
+
+// Start begins watching in the background
+func (w *Watcher) Start() {
+	signal.Notify(w.signals, syscall.SIGHUP)
+
+	go func() {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if w.changed() {
+					w.reload()
+				}
+			case <-w.signals:
+				log.Printf("Received SIGHUP, reloading %s", w.path)
+				w.reload()
+			case <-w.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops watching
+func (w *Watcher) Stop() {
+	w.once.Do(func() {
+		signal.Stop(w.signals)
+		close(w.done)
+	})
+}
+
+// Reload loads the file and applies it if it is valid
+func (w *Watcher) Reload() error {
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	cfg, err := Load(w.path)
+	if err != nil {
+		return err
+	}
+
+	w.onChange(cfg)
+	return nil
+}
+
+// reload reloads the file, logging instead of returning errors
+func (w *Watcher) reload() {
+	if err := w.Reload(); err != nil {
+		log.Printf("Error reloading configuration from %s, keeping current limits: %v", w.path, err)
+		return
+	}
+	log.Printf("Reloaded configuration from %s", w.path)
+}
+
+// changed reports whether the file's contents differ from the last check
+func (w *Watcher) changed() bool {
+	info, err := os.Stat(w.path)
+	if err != nil || info.ModTime().Equal(w.modTime) {
+		return false
+	}
+	w.modTime = info.ModTime()
+
+	data, err := os.ReadFile(w.path)
+	if err != nil || bytes.Equal(data, w.data) {
+		return false
+	}
+	w.data = data
+	return true
+}
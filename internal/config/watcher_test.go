@@ -0,0 +1,57 @@
+package config
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, path string, rpm string) {
+	content := `rateLimits:
+  - apiKey: TEST_KEY
+    endpoints:
+      - path: /test
+        rpm: ` + rpm + `
+        tpm: 10`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+func TestWatcher_ReloadsOnChange(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	writeConfig(t, path, "100")
+
+	changes := make(chan *Configuration, 4)
+	watcher := NewWatcher(path, 10*time.Millisecond, func(cfg *Configuration) {
+		changes <- cfg
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	// Make sure the modification time moves even on coarse filesystems
+	time.Sleep(20 * time.Millisecond)
+	writeConfig(t, path, "200")
+	os.Chtimes(path, time.Now(), time.Now().Add(time.Second))
+
+	select {
+	case cfg := <-changes:
+		if rpm := cfg.RateLimits[0].Endpoints[0].RPM; rpm != 200 {
+			t.Errorf("Expected reloaded RPM 200, got %d", rpm)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the watcher to reload the changed file")
+	}
+
+	// Invalid configurations are not applied
+	if err := os.WriteFile(path, []byte("invalid: [yaml: content"), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	os.Chtimes(path, time.Now(), time.Now().Add(2*time.Second))
+
+	select {
+	case <-changes:
+		t.Error("Expected an invalid configuration to be ignored")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,32 @@
+//go:build !windows
+// +build !windows
+
+package config
+
+import (
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestWatcher_ReloadsOnSIGHUP(t *testing.T) {
+	path := t.TempDir() + "/config.yaml"
+	writeConfig(t, path, "100")
+
+	changes := make(chan *Configuration, 1)
+	watcher := NewWatcher(path, time.Hour, func(cfg *Configuration) {
+		changes <- cfg
+	})
+	watcher.Start()
+	defer watcher.Stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGHUP); err != nil {
+		t.Fatalf("Failed to send SIGHUP: %v", err)
+	}
+
+	select {
+	case <-changes:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected SIGHUP to trigger a reload")
+	}
+}
@@ -0,0 +1,244 @@
+package envoy
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	corev3 "github.com/yourusername/ratelimiter/api/envoy/config/core/v3"
+	ratelimitv3 "github.com/yourusername/ratelimiter/api/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/yourusername/ratelimiter/api/envoy/service/ratelimit/v3"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// defaultClientID is charged for descriptors whose mapping names no client
+const defaultClientID = "envoy"
+
+// Reserver reserves rate limit capacity in batches and commits pending
+// reservations, either locally or across a cluster
+type Reserver interface {
+	ReserveBatch(requests []ratelimit.BatchRequest) ([]*ratelimit.Reservation, error)
+	Commit(id string, actualTokens int) (*ratelimit.Settlement, error)
+}
+
+// Server implements Envoy's rate limit service over the rate limiter. Each
+// descriptor that matches a mapping reserves the request's hits against
+// its API key's endpoint, all of them or none; descriptors that match no
+// mapping, or map to an API key or endpoint that is not configured, are not
+// limited.
+type Server struct {
+	rlsv3.UnimplementedRateLimitServiceServer
+
+	limiter  Reserver
+	mappings []config.DescriptorMapping
+	mutex    sync.RWMutex
+}
+
+// New creates a new Server instance
+func New(limiter Reserver, mappings []config.DescriptorMapping) *Server {
+	return &Server{
+		limiter:  limiter,
+		mappings: mappings,
+	}
+}
+
+// Register registers the rate limit service on server
+func (s *Server) Register(server *grpc.Server) {
+	rlsv3.RegisterRateLimitServiceServer(server, s)
+}
+
+// Update replaces the descriptor mappings
+func (s *Server) Update(mappings []config.DescriptorMapping) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.mappings = mappings
+}
+
+// ShouldRateLimit checks every descriptor in the request as one batch, so
+// the request's hits are charged to every mapped descriptor or to none.
+// The overall code is OVER_LIMIT if any descriptor is over its limit.
+// Envoy never settles what it reserves, so allowed reservations are
+// committed right away rather than left pending.
+func (s *Server) ShouldRateLimit(ctx context.Context, request *rlsv3.RateLimitRequest) (*rlsv3.RateLimitResponse, error) {
+	hits := int(request.HitsAddend)
+	if hits == 0 {
+		hits = 1
+	}
+
+	response := &rlsv3.RateLimitResponse{OverallCode: rlsv3.RateLimitResponse_OK}
+	response.Statuses = make([]*rlsv3.RateLimitResponse_DescriptorStatus, len(request.Descriptors))
+	var batch []ratelimit.BatchRequest
+	var mapped []int
+	for i, descriptor := range request.Descriptors {
+		apiKey, endpoint, clientID, ok := s.resolve(request.Domain, descriptor)
+		if !ok {
+			response.Statuses[i] = &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}
+			continue
+		}
+		batch = append(batch, ratelimit.BatchRequest{
+			ClientID:       clientID,
+			Requests:       hits,
+			APIKey:         apiKey,
+			TargetEndpoint: endpoint,
+		})
+		mapped = append(mapped, i)
+	}
+	reservations, mapped, err := s.reserve(batch, mapped, response.Statuses)
+	if err != nil {
+		return nil, status.Error(codes.Unavailable, err.Error())
+	}
+
+	var retryAfterMs int64
+	for k, reservation := range reservations {
+		response.Statuses[mapped[k]] = toStatus(reservation, hits)
+		if !reservation.Allowed {
+			response.OverallCode = rlsv3.RateLimitResponse_OVER_LIMIT
+			if reservation.RetryAfterMs > retryAfterMs {
+				retryAfterMs = reservation.RetryAfterMs
+			}
+			continue
+		}
+		if reservation.ReservationID != "" {
+			if _, err := s.limiter.Commit(reservation.ReservationID, 0); err != nil {
+				log.Printf("Error committing reservation %s: %v", reservation.ReservationID, err)
+			}
+		}
+	}
+
+	// A batch can be denied on combined demand alone, with no descriptor
+	// short on its own; then every descriptor is over the limit
+	if response.OverallCode == rlsv3.RateLimitResponse_OVER_LIMIT && !anyOverLimit(response.Statuses) {
+		for _, i := range mapped {
+			response.Statuses[i].Code = rlsv3.RateLimitResponse_OVER_LIMIT
+		}
+	}
+
+	// Tell the caller when every denied descriptor would have capacity
+	if retryAfterMs > 0 {
+		response.ResponseHeadersToAdd = append(response.ResponseHeadersToAdd, &corev3.HeaderValue{
+			Key:   "retry-after",
+			Value: strconv.FormatInt((retryAfterMs+999)/1000, 10),
+		})
+	}
+
+	return response, nil
+}
+
+// resolve maps a descriptor to the API key, endpoint and client it is
+// charged to, using the first matching mapping
+func (s *Server) resolve(domain string, descriptor *ratelimitv3.RateLimitDescriptor) (string, string, string, bool) {
+	entries := make(map[string]string, len(descriptor.Entries))
+	for _, entry := range descriptor.Entries {
+		entries[entry.Key] = entry.Value
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, mapping := range s.mappings {
+		if mapping.Domain != "" && mapping.Domain != domain {
+			continue
+		}
+		apiKey, ok := value(entries, mapping.APIKey, mapping.APIKeyEntry)
+		if !ok {
+			continue
+		}
+		endpoint, ok := value(entries, mapping.Endpoint, mapping.EndpointEntry)
+		if !ok {
+			continue
+		}
+		clientID, ok := value(entries, mapping.ClientID, mapping.ClientIDEntry)
+		if !ok {
+			continue
+		}
+		if clientID == "" {
+			clientID = defaultClientID
+		}
+		return apiKey, endpoint, clientID, true
+	}
+	return "", "", "", false
+}
+
+// value returns the fixed value if set, otherwise the value of the entry
+// with key entry, reporting false if that entry is missing
+func value(entries map[string]string, fixed, entry string) (string, bool) {
+	if fixed != "" || entry == "" {
+		return fixed, true
+	}
+	v, exists := entries[entry]
+	return v, exists
+}
+
+// anyOverLimit reports whether any descriptor status is over the limit
+func anyOverLimit(statuses []*rlsv3.RateLimitResponse_DescriptorStatus) bool {
+	for _, s := range statuses {
+		if s.Code == rlsv3.RateLimitResponse_OVER_LIMIT {
+			return true
+		}
+	}
+	return false
+}
+
+// reserve reserves batch for the descriptors at the mapped indexes. A
+// descriptor whose API key or endpoint is not configured denies the whole
+// batch, so it is treated as unmapped: its status is set to OK and the
+// rest of the batch is reserved again. The indexes of the descriptors the
+// reservations belong to are returned with them.
+func (s *Server) reserve(batch []ratelimit.BatchRequest, mapped []int, statuses []*rlsv3.RateLimitResponse_DescriptorStatus) ([]*ratelimit.Reservation, []int, error) {
+	for len(batch) > 0 {
+		reservations, err := s.limiter.ReserveBatch(batch)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		known := 0
+		for k, reservation := range reservations {
+			if reservation.UnknownKey {
+				statuses[mapped[k]] = &rlsv3.RateLimitResponse_DescriptorStatus{Code: rlsv3.RateLimitResponse_OK}
+				continue
+			}
+			batch[known] = batch[k]
+			mapped[known] = mapped[k]
+			known++
+		}
+		if known == len(batch) {
+			return reservations, mapped, nil
+		}
+		batch = batch[:known]
+		mapped = mapped[:known]
+	}
+	return nil, nil, nil
+}
+
+// toStatus converts a reservation of hits to a descriptor status. A denied
+// reservation is only over the limit if its own budgets are short of hits;
+// otherwise another descriptor in the batch was denied.
+func toStatus(reservation *ratelimit.Reservation, hits int) *rlsv3.RateLimitResponse_DescriptorStatus {
+	result := &rlsv3.RateLimitResponse_DescriptorStatus{
+		Code: rlsv3.RateLimitResponse_OK,
+	}
+	if !reservation.Allowed && reservation.RemainingRequests < hits {
+		result.Code = rlsv3.RateLimitResponse_OVER_LIMIT
+	}
+	if reservation.RequestLimit > 0 {
+		remaining := reservation.RemainingRequests
+		if remaining < 0 {
+			remaining = 0
+		}
+		result.CurrentLimit = &rlsv3.RateLimitResponse_RateLimit{
+			RequestsPerUnit: uint32(reservation.RequestLimit),
+			Unit:            rlsv3.RateLimitResponse_RateLimit_MINUTE,
+		}
+		result.LimitRemaining = uint32(remaining)
+		result.DurationUntilReset = durationpb.New(time.Duration(reservation.ResetMs) * time.Millisecond)
+	}
+	return result
+}
@@ -0,0 +1,191 @@
+package envoy
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	ratelimitv3 "github.com/yourusername/ratelimiter/api/envoy/extensions/common/ratelimit/v3"
+	rlsv3 "github.com/yourusername/ratelimiter/api/envoy/service/ratelimit/v3"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// descriptor builds a descriptor from alternating entry keys and values
+func descriptor(pairs ...string) *ratelimitv3.RateLimitDescriptor {
+	d := &ratelimitv3.RateLimitDescriptor{}
+	for i := 0; i+1 < len(pairs); i += 2 {
+		d.Entries = append(d.Entries, &ratelimitv3.RateLimitDescriptor_Entry{Key: pairs[i], Value: pairs[i+1]})
+	}
+	return d
+}
+
+func TestServer_ShouldRateLimit(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 2, TPM: 100},
+				{Path: "/api/endpoint2", RPM: 10, TPM: 100},
+			},
+		},
+	}})
+	server := New(limiter, []config.DescriptorMapping{
+		{Domain: "edge", APIKeyEntry: "api_key", EndpointEntry: "path", ClientIDEntry: "remote_address"},
+		{Domain: "internal", APIKey: "API_KEY_1", Endpoint: "/api/endpoint2"},
+	})
+
+	tests := []struct {
+		name        string
+		request     *rlsv3.RateLimitRequest
+		wantOverall rlsv3.RateLimitResponse_Code
+		wantCodes   []rlsv3.RateLimitResponse_Code
+		// wantUsed is the requests used on /api/endpoint2 afterwards
+		wantUsed int
+	}{
+		{
+			name: "Mapped descriptor within limit",
+			request: &rlsv3.RateLimitRequest{
+				Domain:      "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("api_key", "API_KEY_1", "path", "/api/endpoint1", "remote_address", "10.0.0.1")},
+			},
+			wantOverall: rlsv3.RateLimitResponse_OK,
+			wantCodes:   []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK},
+		},
+		{
+			// The descriptor within its limit is not charged either
+			name: "One descriptor over the limit",
+			request: &rlsv3.RateLimitRequest{
+				Domain: "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{
+					descriptor("api_key", "API_KEY_1", "path", "/api/endpoint2", "remote_address", "10.0.0.1"),
+					descriptor("api_key", "API_KEY_1", "path", "/api/endpoint1", "remote_address", "10.0.0.1"),
+				},
+				HitsAddend: 2,
+			},
+			wantOverall: rlsv3.RateLimitResponse_OVER_LIMIT,
+			wantCodes:   []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK, rlsv3.RateLimitResponse_OVER_LIMIT},
+		},
+		{
+			name: "Descriptors within their limits",
+			request: &rlsv3.RateLimitRequest{
+				Domain: "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{
+					descriptor("api_key", "API_KEY_1", "path", "/api/endpoint2", "remote_address", "10.0.0.1"),
+					descriptor("api_key", "API_KEY_1", "path", "/api/endpoint1", "remote_address", "10.0.0.1"),
+				},
+			},
+			wantOverall: rlsv3.RateLimitResponse_OK,
+			wantCodes:   []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK, rlsv3.RateLimitResponse_OK},
+			wantUsed:    1,
+		},
+		{
+			name: "Fixed mapping for another domain",
+			request: &rlsv3.RateLimitRequest{
+				Domain:      "internal",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("generic_key", "anything")},
+			},
+			wantOverall: rlsv3.RateLimitResponse_OK,
+			wantCodes:   []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK},
+			wantUsed:    2,
+		},
+		{
+			name: "Unmapped descriptor is not limited",
+			request: &rlsv3.RateLimitRequest{
+				Domain:      "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("path", "/api/endpoint1")},
+			},
+			wantOverall: rlsv3.RateLimitResponse_OK,
+			wantCodes:   []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK},
+			wantUsed:    2,
+		},
+		{
+			// The configured descriptor is still charged
+			name: "Unconfigured API key or endpoint is not limited",
+			request: &rlsv3.RateLimitRequest{
+				Domain: "edge",
+				Descriptors: []*ratelimitv3.RateLimitDescriptor{
+					descriptor("api_key", "UNKNOWN_KEY", "path", "/api/endpoint2", "remote_address", "10.0.0.1"),
+					descriptor("api_key", "API_KEY_1", "path", "/api/endpoint2", "remote_address", "10.0.0.1"),
+					descriptor("api_key", "API_KEY_1", "path", "/api/unknown", "remote_address", "10.0.0.1"),
+				},
+			},
+			wantOverall: rlsv3.RateLimitResponse_OK,
+			wantCodes:   []rlsv3.RateLimitResponse_Code{rlsv3.RateLimitResponse_OK, rlsv3.RateLimitResponse_OK, rlsv3.RateLimitResponse_OK},
+			wantUsed:    3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			response, err := server.ShouldRateLimit(context.Background(), tt.request)
+			if err != nil {
+				t.Fatalf("ShouldRateLimit failed: %v", err)
+			}
+			if response.OverallCode != tt.wantOverall {
+				t.Errorf("Expected overall code %v, got %v", tt.wantOverall, response.OverallCode)
+			}
+			if len(response.Statuses) != len(tt.wantCodes) {
+				t.Fatalf("Expected %d statuses, got %d", len(tt.wantCodes), len(response.Statuses))
+			}
+			for i, status := range response.Statuses {
+				if status.Code != tt.wantCodes[i] {
+					t.Errorf("Expected descriptor %d code %v, got %v", i, tt.wantCodes[i], status.Code)
+				}
+			}
+
+			quotas, err := limiter.Quota("API_KEY_1", "/api/endpoint2", "")
+			if err != nil {
+				t.Fatalf("Failed to get quota: %v", err)
+			}
+			if used := quotas[len(quotas)-1].Requests.Used; used != tt.wantUsed {
+				t.Errorf("Expected %d requests used on /api/endpoint2, got %d", tt.wantUsed, used)
+			}
+		})
+	}
+
+	// Over-limit responses report the limit and when to retry
+	response, _ := server.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+		Domain:      "edge",
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("api_key", "API_KEY_1", "path", "/api/endpoint1", "remote_address", "10.0.0.1")},
+		HitsAddend:  2,
+	})
+	if response.OverallCode != rlsv3.RateLimitResponse_OVER_LIMIT {
+		t.Fatalf("Expected OVER_LIMIT, got %v", response.OverallCode)
+	}
+	status := response.Statuses[0]
+	if status.CurrentLimit.GetRequestsPerUnit() != 2 || status.CurrentLimit.GetUnit() != rlsv3.RateLimitResponse_RateLimit_MINUTE {
+		t.Errorf("Expected a limit of 2 per minute, got %v", status.CurrentLimit)
+	}
+	if len(response.ResponseHeadersToAdd) != 1 || response.ResponseHeadersToAdd[0].Key != "retry-after" {
+		t.Errorf("Expected a retry-after header, got %v", response.ResponseHeadersToAdd)
+	}
+}
+
+func TestServer_CommitsReservations(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey:    "API_KEY_1",
+			Endpoints: []config.EndpointConfig{{Path: "/api/endpoint1", RPM: 10, TPM: 100}},
+		},
+	}})
+	limiter.SetReservationTTL(10 * time.Millisecond)
+	server := New(limiter, []config.DescriptorMapping{{APIKey: "API_KEY_1", Endpoint: "/api/endpoint1"}})
+
+	response, err := server.ShouldRateLimit(context.Background(), &rlsv3.RateLimitRequest{
+		Descriptors: []*ratelimitv3.RateLimitDescriptor{descriptor("generic_key", "anything")},
+	})
+	if err != nil || response.OverallCode != rlsv3.RateLimitResponse_OK {
+		t.Fatalf("Expected OK, got %v, %v", response, err)
+	}
+
+	// Envoy never settles, so the hit must not be refunded at the TTL
+	time.Sleep(50 * time.Millisecond)
+	quotas, err := limiter.Quota("API_KEY_1", "/api/endpoint1", "")
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	if used := quotas[len(quotas)-1].Requests.Used; used != 1 {
+		t.Errorf("Expected the hit to stay charged, got %d requests used", used)
+	}
+}
@@ -0,0 +1,212 @@
+package grpcserver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	pb "github.com/yourusername/ratelimiter/api/ratelimiter/v1"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/internal/metrics"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// maxStreamInFlight caps how many reservations one stream works on at once
+const maxStreamInFlight = 64
+
+// Limiter reserves capacity and settles pending reservations, either
+// locally or across a cluster
+type Limiter interface {
+	Reserve(clientID string, tokens, requests int, apiKey, targetEndpoint string) *ratelimit.Reservation
+	ReserveWait(ctx context.Context, clientID string, tokens, requests int, apiKey, targetEndpoint string) *ratelimit.Reservation
+	Commit(id string, actualTokens int) (*ratelimit.Settlement, error)
+	Cancel(id string) (*ratelimit.Settlement, error)
+}
+
+// Server implements the RateLimiter gRPC service
+type Server struct {
+	pb.UnimplementedRateLimiterServer
+
+	limiter Limiter
+	metrics *metrics.Metrics
+	maxWait int64
+}
+
+// New creates a new Server instance
+func New(limiter Limiter) *Server {
+	return &Server{
+		limiter: limiter,
+	}
+}
+
+// SetMetrics sets where the latency of reservation requests is recorded
+func (s *Server) SetMetrics(m *metrics.Metrics) {
+	s.metrics = m
+}
+
+// SetMaxWait sets the longest a reservation may queue for capacity. It is
+// safe to call while requests are served.
+func (s *Server) SetMaxWait(d time.Duration) {
+	atomic.StoreInt64(&s.maxWait, int64(d))
+}
+
+// Register creates a gRPC server with the RateLimiter service registered
+func (s *Server) Register(opts ...grpc.ServerOption) *grpc.Server {
+	server := grpc.NewServer(opts...)
+	pb.RegisterRateLimiterServer(server, s)
+	return server
+}
+
+// Reserve reserves capacity, queueing for up to MaxWaitMs if set
+func (s *Server) Reserve(ctx context.Context, request *pb.ReserveRequest) (*pb.Reservation, error) {
+	if err := validateRequest(request); err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	return s.reserve(ctx, request), nil
+}
+
+// Commit settles a pending reservation with the tokens actually used
+func (s *Server) Commit(ctx context.Context, request *pb.CommitRequest) (*pb.Settlement, error) {
+	if request.Tokens < 0 {
+		return nil, status.Error(codes.InvalidArgument, "Tokens must be non-negative")
+	}
+	settlement, err := s.limiter.Commit(request.ReservationId, int(request.Tokens))
+	return toSettlement(settlement, err)
+}
+
+// Cancel releases a pending reservation
+func (s *Server) Cancel(ctx context.Context, request *pb.CancelRequest) (*pb.Settlement, error) {
+	settlement, err := s.limiter.Cancel(request.ReservationId)
+	return toSettlement(settlement, err)
+}
+
+// ReserveStream answers reservations as they arrive on the stream. Each is
+// handled concurrently, so a reservation queueing for capacity does not
+// hold up the ones behind it.
+func (s *Server) ReserveStream(stream pb.RateLimiter_ReserveStreamServer) error {
+	var wg sync.WaitGroup
+	var sendMutex sync.Mutex
+	var sendErr error
+	slots := make(chan struct{}, maxStreamInFlight)
+
+	send := func(response *pb.ReserveStreamResponse) {
+		sendMutex.Lock()
+		defer sendMutex.Unlock()
+		if sendErr == nil {
+			sendErr = stream.Send(response)
+		}
+	}
+
+	for {
+		message, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			wg.Wait()
+			return err
+		}
+
+		if err := validateRequest(message.Request); err != nil {
+			send(&pb.ReserveStreamResponse{Id: message.Id, Error: err.Error()})
+			continue
+		}
+
+		slots <- struct{}{}
+		wg.Add(1)
+		go func(message *pb.ReserveStreamRequest) {
+			defer wg.Done()
+			defer func() { <-slots }()
+
+			reservation := s.reserve(stream.Context(), message.Request)
+			send(&pb.ReserveStreamResponse{Id: message.Id, Reservation: reservation})
+		}(message)
+	}
+
+	wg.Wait()
+	sendMutex.Lock()
+	defer sendMutex.Unlock()
+	return sendErr
+}
+
+// reserve runs a validated reservation and converts the result
+func (s *Server) reserve(ctx context.Context, request *pb.ReserveRequest) *pb.Reservation {
+	start := time.Now()
+	var reservation *ratelimit.Reservation
+	if request.MaxWaitMs > 0 {
+		ctx, cancel := context.WithTimeout(ctx, config.ClampWait(request.MaxWaitMs, time.Duration(atomic.LoadInt64(&s.maxWait))))
+		defer cancel()
+		reservation = s.limiter.ReserveWait(ctx, request.ClientId, int(request.Tokens), int(request.Requests), request.ApiKey, request.TargetEndpoint)
+	} else {
+		reservation = s.limiter.Reserve(request.ClientId, int(request.Tokens), int(request.Requests), request.ApiKey, request.TargetEndpoint)
+	}
+	s.metrics.ObserveReserve(time.Since(start), reservation.Allowed)
+
+	return toReservation(reservation)
+}
+
+// validateRequest performs the same checks as the HTTP reserve handler
+func validateRequest(request *pb.ReserveRequest) error {
+	switch {
+	case request == nil:
+		return errors.New("Request is required")
+	case request.ClientId == "":
+		return errors.New("ClientID is required")
+	case request.ApiKey == "":
+		return errors.New("APIKey is required")
+	case request.TargetEndpoint == "":
+		return errors.New("TargetEndpoint is required")
+	case request.Tokens < 0:
+		return errors.New("Tokens must be non-negative")
+	case request.Requests < 0:
+		return errors.New("Requests must be non-negative")
+	case request.MaxWaitMs < 0:
+		return errors.New("MaxWaitMs must be non-negative")
+	}
+	return nil
+}
+
+// toReservation converts a reservation to its protobuf message
+func toReservation(reservation *ratelimit.Reservation) *pb.Reservation {
+	message := &pb.Reservation{
+		Allowed:            reservation.Allowed,
+		ReservedTokens:     int64(reservation.ReservedTokens),
+		ReservedRequests:   int64(reservation.ReservedRequests),
+		RemainingTokens:    int64(reservation.RemainingTokens),
+		RemainingRequests:  int64(reservation.RemainingRequests),
+		TargetEndpointPath: reservation.TargetEndpointPath,
+		ReservationId:      reservation.ReservationID,
+		RequestLimit:       int64(reservation.RequestLimit),
+		ResetMs:            reservation.ResetMs,
+		RetryAfterMs:       reservation.RetryAfterMs,
+	}
+	if reservation.ExpiresAt != nil {
+		message.ExpiresAtUnixMs = reservation.ExpiresAt.UnixNano() / int64(time.Millisecond)
+	}
+	return message
+}
+
+// toSettlement converts a settlement to its protobuf message, mapping
+// errors to gRPC status codes
+func toSettlement(settlement *ratelimit.Settlement, err error) (*pb.Settlement, error) {
+	if errors.Is(err, ratelimit.ErrReservationNotFound) {
+		return nil, status.Error(codes.NotFound, "Reservation not found or expired")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+	return &pb.Settlement{
+		ReservationId:    settlement.ReservationID,
+		ReservedTokens:   int64(settlement.ReservedTokens),
+		ReservedRequests: int64(settlement.ReservedRequests),
+		CommittedTokens:  int64(settlement.CommittedTokens),
+		RefundedTokens:   int64(settlement.RefundedTokens),
+		RefundedRequests: int64(settlement.RefundedRequests),
+	}, nil
+}
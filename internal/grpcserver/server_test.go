@@ -0,0 +1,163 @@
+package grpcserver
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	pb "github.com/yourusername/ratelimiter/api/ratelimiter/v1"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newClient serves limiter over an in-memory connection and returns a
+// client for it
+func newClient(t *testing.T, limiter *ratelimit.Limiter) pb.RateLimiterClient {
+	listener := bufconn.Listen(1 << 20)
+	server := New(limiter).Register()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return pb.NewRateLimiterClient(conn)
+}
+
+func newLimiter() *ratelimit.Limiter {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/test", RPM: 10, TPM: 100},
+			},
+		},
+	}})
+	limiter.SetReservationTTL(time.Minute)
+	return limiter
+}
+
+func TestServer_ReserveCommitCancel(t *testing.T) {
+	client := newClient(t, newLimiter())
+	ctx := context.Background()
+
+	reservation, err := client.Reserve(ctx, &pb.ReserveRequest{
+		ClientId: "client1", Tokens: 80, Requests: 1, ApiKey: "API_KEY_1", TargetEndpoint: "/test",
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if !reservation.Allowed || reservation.ReservationId == "" || reservation.ExpiresAtUnixMs == 0 {
+		t.Fatalf("Expected an allowed pending reservation, got %v", reservation)
+	}
+
+	settlement, err := client.Commit(ctx, &pb.CommitRequest{ReservationId: reservation.ReservationId, Tokens: 30})
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if settlement.RefundedTokens != 50 {
+		t.Errorf("Expected 50 refunded tokens, got %d", settlement.RefundedTokens)
+	}
+
+	if _, err := client.Cancel(ctx, &pb.CancelRequest{ReservationId: reservation.ReservationId}); status.Code(err) != codes.NotFound {
+		t.Errorf("Expected NotFound cancelling a committed reservation, got %v", err)
+	}
+
+	denied, err := client.Reserve(ctx, &pb.ReserveRequest{
+		ClientId: "client1", Tokens: 80, Requests: 1, ApiKey: "API_KEY_1", TargetEndpoint: "/test",
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if denied.Allowed || denied.RetryAfterMs == 0 {
+		t.Errorf("Expected a denial with a retry hint, got %v", denied)
+	}
+
+	if _, err := client.Reserve(ctx, &pb.ReserveRequest{ApiKey: "API_KEY_1", TargetEndpoint: "/test"}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("Expected InvalidArgument without a client ID, got %v", err)
+	}
+}
+
+func TestServer_ReserveStream(t *testing.T) {
+	client := newClient(t, newLimiter())
+
+	stream, err := client.ReserveStream(context.Background())
+	if err != nil {
+		t.Fatalf("ReserveStream failed: %v", err)
+	}
+
+	ids := []string{"a", "b", "c", "d", "e", "f", "g", "h", "i", "j", "k", "l"}
+	for _, id := range ids {
+		if err := stream.Send(&pb.ReserveStreamRequest{
+			Id:      id,
+			Request: &pb.ReserveRequest{ClientId: "client1", Tokens: 1, Requests: 1, ApiKey: "API_KEY_1", TargetEndpoint: "/test"},
+		}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if err := stream.Send(&pb.ReserveStreamRequest{Id: "invalid", Request: &pb.ReserveRequest{}}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	stream.CloseSend()
+
+	seen := make(map[string]bool)
+	allowed := 0
+	for range append(ids, "invalid") {
+		response, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv failed: %v", err)
+		}
+		seen[response.Id] = true
+		if response.Id == "invalid" {
+			if response.Error != "ClientID is required" {
+				t.Errorf("Expected a validation error, got %v", response)
+			}
+			continue
+		}
+		if response.Reservation.Allowed {
+			allowed++
+		}
+	}
+
+	if len(seen) != len(ids)+1 {
+		t.Errorf("Expected a response for every request, got %d", len(seen))
+	}
+	if allowed != 10 {
+		t.Errorf("Expected 10 reservations allowed over the stream, got %d", allowed)
+	}
+}
+
+func TestServer_MaxWaitIsCapped(t *testing.T) {
+	limiter := newLimiter()
+	limiter.Reserve("client1", 1, 10, "API_KEY_1", "/test")
+	server := New(limiter)
+	server.SetMaxWait(50 * time.Millisecond)
+
+	start := time.Now()
+	reservation, err := server.Reserve(context.Background(), &pb.ReserveRequest{
+		ClientId: "client1", Tokens: 1, Requests: 1, ApiKey: "API_KEY_1", TargetEndpoint: "/test", MaxWaitMs: 600000,
+	})
+	if err != nil {
+		t.Fatalf("Reserve failed: %v", err)
+	}
+	if reservation.Allowed {
+		t.Errorf("Expected a denial once the capped wait ran out, got %v", reservation)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the wait to be capped at 50ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,449 @@
+package handlers
+
+import (
+	"crypto/subtle"
+	"errors"
+	"log"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+)
+
+// RateLimitsResponse represents a list of API key rate limits
+type RateLimitsResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data []config.RateLimit `json:"data"`
+}
+
+// RateLimitResponse represents a single API key's rate limits
+type RateLimitResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data config.RateLimit `json:"data"`
+}
+
+// EndpointResponse represents a single endpoint's limits
+type EndpointResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data config.EndpointConfig `json:"data"`
+}
+
+// unpersistedWarning is the Warning header of changes that are only kept in
+// memory, which the next reload of the configuration file discards
+const unpersistedWarning = `299 - "Change not persisted; the next configuration reload discards it"`
+
+// LimitUpdater replaces the limits a rate limiter enforces
+type LimitUpdater interface {
+	UpdateLimits(global config.LimitConfig, rateLimits []config.RateLimit)
+}
+
+// AdminHandler manages API keys and their endpoint limits at runtime.
+// Every change is validated against the whole configuration and applied
+// to the limiter at once; counters of unchanged limits carry over. Changes
+// are refused in cluster mode, since they would only reach this node.
+// Without a persist path, changes carry a Warning header, since the next
+// reload of the configuration file replaces them.
+type AdminHandler struct {
+	limiter LimitUpdater
+	token   string
+	path    string
+	cfg     config.Configuration
+	// unpersisted is set while the limits hold changes that are only kept
+	// in memory
+	unpersisted bool
+	mutex       sync.Mutex
+}
+
+// NewAdminHandler creates a new AdminHandler instance that manages the
+// rate limits of cfg and requires its admin token on every request
+func NewAdminHandler(limiter LimitUpdater, cfg *config.Configuration) *AdminHandler {
+	return &AdminHandler{
+		limiter: limiter,
+		token:   cfg.Admin.Token,
+		cfg:     *cfg,
+	}
+}
+
+// SetPersistPath sets the configuration file changes are written back to.
+// Changes are only kept in memory if it is empty.
+func (h *AdminHandler) SetPersistPath(path string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.path = path
+}
+
+// Update replaces the managed configuration and admin token, such as
+// after the file was reloaded, discarding changes that were not persisted
+func (h *AdminHandler) Update(cfg *config.Configuration) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.unpersisted {
+		log.Printf("Reloaded rate limits replace changes made through the admin API that were not persisted")
+		h.unpersisted = false
+	}
+	h.cfg = *cfg
+	h.token = cfg.Admin.Token
+}
+
+// Register adds the admin routes to router
+func (h *AdminHandler) Register(router fiber.Router) {
+	router.Use(h.Authenticate)
+	router.Get("/ratelimits", h.List)
+	router.Post("/ratelimits", h.Create)
+	router.Get("/ratelimits/:apiKey", h.Get)
+	router.Put("/ratelimits/:apiKey", h.Replace)
+	router.Delete("/ratelimits/:apiKey", h.Delete)
+	router.Get("/ratelimits/:apiKey/endpoints/*", h.GetEndpoint)
+	router.Put("/ratelimits/:apiKey/endpoints/*", h.PutEndpoint)
+	router.Delete("/ratelimits/:apiKey/endpoints/*", h.DeleteEndpoint)
+}
+
+// Authenticate rejects requests without the admin bearer token. Every
+// request is rejected while no token is configured.
+func (h *AdminHandler) Authenticate(c *fiber.Ctx) error {
+	h.mutex.Lock()
+	expected := h.token
+	h.mutex.Unlock()
+
+	token, ok := bearerToken(c.Get(fiber.HeaderAuthorization))
+	if !ok || expected == "" || subtle.ConstantTimeCompare([]byte(token), []byte(expected)) != 1 {
+		c.Set(fiber.HeaderWWWAuthenticate, "Bearer")
+		return sendError(c, fiber.StatusUnauthorized, "Invalid admin token")
+	}
+	return c.Next()
+}
+
+// bearerToken returns the token of a bearer Authorization header
+func bearerToken(header string) (string, bool) {
+	const prefix = "Bearer "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
+
+// List returns every API key's rate limits
+func (h *AdminHandler) List(c *fiber.Ctx) error {
+	h.mutex.Lock()
+	rateLimits := append([]config.RateLimit{}, h.cfg.RateLimits...)
+	h.mutex.Unlock()
+
+	response := RateLimitsResponse{}
+	response.Status.Code = fiber.StatusOK
+	response.Status.Message = "Success"
+	response.Data = rateLimits
+	return sendJSONResponse(c, fiber.StatusOK, response)
+}
+
+// Get returns one API key's rate limits
+func (h *AdminHandler) Get(c *fiber.Ctx) error {
+	apiKey, err := url.PathUnescape(c.Params("apiKey"))
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid API key")
+	}
+
+	h.mutex.Lock()
+	i := findRateLimit(h.cfg.RateLimits, apiKey)
+	var rateLimit config.RateLimit
+	if i >= 0 {
+		rateLimit = h.cfg.RateLimits[i]
+	}
+	h.mutex.Unlock()
+
+	if i < 0 {
+		return sendError(c, fiber.StatusNotFound, "Unknown API key")
+	}
+	return h.respond(c, fiber.StatusOK, rateLimit)
+}
+
+// Create adds a new API key with its endpoints
+func (h *AdminHandler) Create(c *fiber.Ctx) error {
+	var rateLimit config.RateLimit
+	if err := c.BodyParser(&rateLimit); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if err := validateRateLimit(&rateLimit); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	return h.change(c, func(rateLimits []config.RateLimit) ([]config.RateLimit, error) {
+		if findRateLimit(rateLimits, rateLimit.APIKey) >= 0 {
+			return nil, fiber.NewError(fiber.StatusConflict, "API key already exists")
+		}
+		return append(rateLimits, rateLimit), nil
+	}, func() error {
+		return h.respond(c, fiber.StatusCreated, rateLimit)
+	})
+}
+
+// Replace replaces an existing API key's aggregate cap and endpoints
+func (h *AdminHandler) Replace(c *fiber.Ctx) error {
+	apiKey, err := url.PathUnescape(c.Params("apiKey"))
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid API key")
+	}
+	var rateLimit config.RateLimit
+	if err := c.BodyParser(&rateLimit); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if rateLimit.APIKey == "" {
+		rateLimit.APIKey = apiKey
+	}
+	if rateLimit.APIKey != apiKey {
+		return sendError(c, fiber.StatusBadRequest, "APIKey does not match the path")
+	}
+	if err := validateRateLimit(&rateLimit); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	return h.change(c, func(rateLimits []config.RateLimit) ([]config.RateLimit, error) {
+		i := findRateLimit(rateLimits, apiKey)
+		if i < 0 {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Unknown API key")
+		}
+		rateLimits[i] = rateLimit
+		return rateLimits, nil
+	}, func() error {
+		return h.respond(c, fiber.StatusOK, rateLimit)
+	})
+}
+
+// Delete removes an API key and all of its endpoints
+func (h *AdminHandler) Delete(c *fiber.Ctx) error {
+	apiKey, err := url.PathUnescape(c.Params("apiKey"))
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid API key")
+	}
+
+	return h.change(c, func(rateLimits []config.RateLimit) ([]config.RateLimit, error) {
+		i := findRateLimit(rateLimits, apiKey)
+		if i < 0 {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Unknown API key")
+		}
+		return append(rateLimits[:i], rateLimits[i+1:]...), nil
+	}, func() error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
+
+// GetEndpoint returns the limits of one of an API key's endpoints
+func (h *AdminHandler) GetEndpoint(c *fiber.Ctx) error {
+	apiKey, target, err := endpointParams(c)
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	h.mutex.Lock()
+	var endpoint config.EndpointConfig
+	found := false
+	if i := findRateLimit(h.cfg.RateLimits, apiKey); i >= 0 {
+		if j := findEndpoint(h.cfg.RateLimits[i].Endpoints, target.Route()); j >= 0 {
+			endpoint = h.cfg.RateLimits[i].Endpoints[j]
+			found = true
+		}
+	}
+	h.mutex.Unlock()
+
+	if !found {
+		return sendError(c, fiber.StatusNotFound, "Unknown API key or endpoint")
+	}
+	return h.respondEndpoint(c, fiber.StatusOK, endpoint)
+}
+
+// PutEndpoint adds an endpoint to an existing API key, or replaces its
+// limits if the key already has it
+func (h *AdminHandler) PutEndpoint(c *fiber.Ctx) error {
+	apiKey, target, err := endpointParams(c)
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+	var endpoint config.EndpointConfig
+	if err := c.BodyParser(&endpoint); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if endpoint.Path == "" {
+		endpoint.Path = target.Path
+	}
+	if endpoint.Methods == nil {
+		endpoint.Methods = target.Methods
+	}
+	if endpoint.Route() != target.Route() {
+		return sendError(c, fiber.StatusBadRequest, "Path or methods do not match the URL")
+	}
+
+	status := fiber.StatusOK
+	return h.change(c, func(rateLimits []config.RateLimit) ([]config.RateLimit, error) {
+		i := findRateLimit(rateLimits, apiKey)
+		if i < 0 {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Unknown API key")
+		}
+		endpoints := append([]config.EndpointConfig{}, rateLimits[i].Endpoints...)
+		if j := findEndpoint(endpoints, target.Route()); j >= 0 {
+			endpoints[j] = endpoint
+		} else {
+			endpoints = append(endpoints, endpoint)
+			status = fiber.StatusCreated
+		}
+		rateLimits[i].Endpoints = endpoints
+		return rateLimits, nil
+	}, func() error {
+		return h.respondEndpoint(c, status, endpoint)
+	})
+}
+
+// DeleteEndpoint removes one of an API key's endpoints
+func (h *AdminHandler) DeleteEndpoint(c *fiber.Ctx) error {
+	apiKey, target, err := endpointParams(c)
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	return h.change(c, func(rateLimits []config.RateLimit) ([]config.RateLimit, error) {
+		i := findRateLimit(rateLimits, apiKey)
+		if i < 0 {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Unknown API key")
+		}
+		j := findEndpoint(rateLimits[i].Endpoints, target.Route())
+		if j < 0 {
+			return nil, fiber.NewError(fiber.StatusNotFound, "Unknown endpoint")
+		}
+		endpoints := append([]config.EndpointConfig{}, rateLimits[i].Endpoints[:j]...)
+		rateLimits[i].Endpoints = append(endpoints, rateLimits[i].Endpoints[j+1:]...)
+		return rateLimits, nil
+	}, func() error {
+		return c.SendStatus(fiber.StatusNoContent)
+	})
+}
+
+// change applies modify to a copy of the rate limits. The result is
+// validated, persisted if configured and then applied to the limiter;
+// nothing changes if any step fails. done writes the response on success,
+// with a warning if the change was not persisted.
+func (h *AdminHandler) change(c *fiber.Ctx, modify func([]config.RateLimit) ([]config.RateLimit, error), done func() error) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.cfg.Cluster.Self != "" {
+		return sendError(c, fiber.StatusConflict, "Rate limits cannot be changed through the admin API in cluster mode; update the configuration file on every node")
+	}
+
+	rateLimits, err := modify(append([]config.RateLimit{}, h.cfg.RateLimits...))
+	if err != nil {
+		var fiberErr *fiber.Error
+		if errors.As(err, &fiberErr) {
+			return sendError(c, fiberErr.Code, fiberErr.Message)
+		}
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	candidate := h.cfg
+	candidate.RateLimits = rateLimits
+	if err := candidate.Validate(); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+
+	if h.path != "" {
+		if err := config.SaveRateLimits(h.path, rateLimits); err != nil {
+			log.Printf("Error saving rate limits to %s: %v", h.path, err)
+			return sendError(c, fiber.StatusInternalServerError, "Failed to persist configuration")
+		}
+	}
+
+	h.limiter.UpdateLimits(candidate.GlobalLimit, candidate.RateLimits)
+	h.cfg = candidate
+	if h.path == "" {
+		h.unpersisted = true
+		c.Set(fiber.HeaderWarning, unpersistedWarning)
+	}
+	return done()
+}
+
+// respond sends an API key's rate limits
+func (h *AdminHandler) respond(c *fiber.Ctx, status int, rateLimit config.RateLimit) error {
+	response := RateLimitResponse{}
+	response.Status.Code = status
+	response.Status.Message = "Success"
+	response.Data = rateLimit
+	return sendJSONResponse(c, status, response)
+}
+
+// respondEndpoint sends an endpoint's limits
+func (h *AdminHandler) respondEndpoint(c *fiber.Ctx, status int, endpoint config.EndpointConfig) error {
+	response := EndpointResponse{}
+	response.Status.Code = status
+	response.Status.Message = "Success"
+	response.Data = endpoint
+	return sendJSONResponse(c, status, response)
+}
+
+// validateRateLimit checks the fields the admin API identifies limits by;
+// the limits themselves are checked with the rest of the configuration
+func validateRateLimit(rateLimit *config.RateLimit) error {
+	if rateLimit.APIKey == "" {
+		return fiber.NewError(fiber.StatusBadRequest, "APIKey is required")
+	}
+	seen := make(map[string]bool)
+	for _, endpoint := range rateLimit.Endpoints {
+		if endpoint.Path == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "Endpoint path is required")
+		}
+		if seen[endpoint.Route()] {
+			return fiber.NewError(fiber.StatusBadRequest, "Duplicate endpoint "+endpoint.Route())
+		}
+		seen[endpoint.Route()] = true
+	}
+	return nil
+}
+
+// endpointParams returns the API key and endpoint of an endpoint route,
+// where the path is the rest of the URL and the optional methods query
+// selects among endpoints sharing the path
+func endpointParams(c *fiber.Ctx) (string, config.EndpointConfig, error) {
+	apiKey, err := url.PathUnescape(c.Params("apiKey"))
+	if err != nil {
+		return "", config.EndpointConfig{}, fiber.NewError(fiber.StatusBadRequest, "Invalid API key")
+	}
+	path, err := url.PathUnescape(c.Params("*"))
+	if err != nil || path == "" {
+		return "", config.EndpointConfig{}, fiber.NewError(fiber.StatusBadRequest, "Invalid endpoint")
+	}
+
+	endpoint := config.EndpointConfig{Path: "/" + path}
+	if methods := c.Query("methods"); methods != "" {
+		endpoint.Methods = strings.Split(methods, ",")
+	}
+	return apiKey, endpoint, nil
+}
+
+// findRateLimit returns the index of apiKey's rate limit, or -1
+func findRateLimit(rateLimits []config.RateLimit, apiKey string) int {
+	for i, rateLimit := range rateLimits {
+		if rateLimit.APIKey == apiKey {
+			return i
+		}
+	}
+	return -1
+}
+
+// findEndpoint returns the index of the endpoint with route, or -1
+func findEndpoint(endpoints []config.EndpointConfig, route string) int {
+	for i, endpoint := range endpoints {
+		if endpoint.Route() == route {
+			return i
+		}
+	}
+	return -1
+}
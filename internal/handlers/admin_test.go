@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestAdminHandler(t *testing.T) {
+	cfg := &config.Configuration{
+		RateLimits: []config.RateLimit{
+			{
+				APIKey:    "API_KEY_1",
+				Endpoints: []config.EndpointConfig{{Path: "/api/endpoint1", RPM: 100, TPM: 10}},
+			},
+		},
+		Admin: config.AdminConfig{Token: "secret"},
+	}
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: cfg.RateLimits})
+	admin := NewAdminHandler(limiter, cfg)
+	app := fiber.New()
+	admin.Register(app.Group("/admin"))
+
+	request := func(method, path, token string, body interface{}) *http.Response {
+		var reader io.Reader
+		if body != nil {
+			data, _ := json.Marshal(body)
+			reader = bytes.NewReader(data)
+		}
+		req := httptest.NewRequest(method, path, reader)
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		return resp
+	}
+
+	newKey := config.RateLimit{
+		APIKey:    "API_KEY_2",
+		Endpoints: []config.EndpointConfig{{Path: "/api/endpoint2", RPM: 1, TPM: 10}},
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		token          string
+		body           interface{}
+		expectedStatus int
+	}{
+		{"Missing token", "GET", "/admin/ratelimits", "", nil, fiber.StatusUnauthorized},
+		{"Wrong token", "GET", "/admin/ratelimits", "guess", nil, fiber.StatusUnauthorized},
+		{"List", "GET", "/admin/ratelimits", "secret", nil, fiber.StatusOK},
+		{"Create", "POST", "/admin/ratelimits", "secret", newKey, fiber.StatusCreated},
+		{"Create existing key", "POST", "/admin/ratelimits", "secret", newKey, fiber.StatusConflict},
+		{"Create without API key", "POST", "/admin/ratelimits", "secret", config.RateLimit{}, fiber.StatusBadRequest},
+		{"Invalid algorithm", "PUT", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint2", "secret", config.EndpointConfig{RPM: 5, TPM: 10, Algorithm: "leaky_faucet"}, fiber.StatusBadRequest},
+		{"Get endpoint", "GET", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint2", "secret", nil, fiber.StatusOK},
+		{"Add endpoint", "PUT", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint3", "secret", config.EndpointConfig{RPM: 5, TPM: 10}, fiber.StatusCreated},
+		{"Add method endpoint", "PUT", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint3?methods=POST", "secret", config.EndpointConfig{RPM: 1, TPM: 10}, fiber.StatusCreated},
+		{"Get method endpoint", "GET", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint3?methods=post", "secret", nil, fiber.StatusOK},
+		{"Methods differ from the URL", "PUT", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint3?methods=POST", "secret", config.EndpointConfig{Methods: []string{"GET"}, RPM: 1, TPM: 10}, fiber.StatusBadRequest},
+		{"Add endpoint to unknown key", "PUT", "/admin/ratelimits/API_KEY_9/endpoints/api/endpoint3", "secret", config.EndpointConfig{RPM: 5, TPM: 10}, fiber.StatusNotFound},
+		{"Delete endpoint", "DELETE", "/admin/ratelimits/API_KEY_1/endpoints/api/endpoint1", "secret", nil, fiber.StatusNoContent},
+		{"Delete unknown key", "DELETE", "/admin/ratelimits/API_KEY_9", "secret", nil, fiber.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := request(tt.method, tt.path, tt.token, tt.body)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+
+	// Changes apply to the limiter right away; rejected ones do not
+	if resp := request("PUT", "/admin/ratelimits/API_KEY_2/endpoints/api/endpoint2", "secret", config.EndpointConfig{RPM: 1, TPM: 10}); resp.Header.Get(fiber.HeaderWarning) == "" {
+		t.Error("Expected a warning that the change is not persisted")
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_2", "/api/endpoint2"); !reservation.Allowed {
+		t.Errorf("Expected the created key to be enforced, got %+v", reservation)
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_2", "/api/endpoint2"); reservation.Allowed {
+		t.Errorf("Expected the created key's RPM of 1 to hold, got %+v", reservation)
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_2", "/api/endpoint3"); !reservation.Allowed {
+		t.Errorf("Expected the added endpoint to be enforced, got %+v", reservation)
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_2", "POST /api/endpoint3"); !reservation.Allowed {
+		t.Errorf("Expected the POST endpoint to be enforced, got %+v", reservation)
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_2", "POST /api/endpoint3"); reservation.Allowed {
+		t.Errorf("Expected the POST endpoint's RPM of 1 to hold, got %+v", reservation)
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_1", "/api/endpoint1"); reservation.Allowed {
+		t.Errorf("Expected the deleted endpoint to be unknown, got %+v", reservation)
+	}
+
+	resp := request("DELETE", "/admin/ratelimits/API_KEY_2", "secret", nil)
+	if resp.StatusCode != fiber.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusNoContent, resp.StatusCode)
+	}
+	var list RateLimitsResponse
+	resp = request("GET", "/admin/ratelimits", "secret", nil)
+	if err := json.NewDecoder(resp.Body).Decode(&list); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if len(list.Data) != 1 || list.Data[0].APIKey != "API_KEY_1" || len(list.Data[0].Endpoints) != 0 {
+		t.Errorf("Expected only API_KEY_1 without endpoints, got %+v", list.Data)
+	}
+}
+
+func TestAdminHandler_Persist(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `globalLimit:
+  rpm: 1000
+rateLimits:
+  - apiKey: API_KEY_1
+    endpoints:
+      - path: /api/endpoint1
+        rpm: 100
+        tpm: 10
+admin:
+  token: secret
+  persist: true
+`
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	cfg, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	admin := NewAdminHandler(ratelimit.MustNew(ratelimit.Config{RateLimits: cfg.RateLimits}), cfg)
+	admin.SetPersistPath(path)
+	app := fiber.New()
+	admin.Register(app.Group("/admin"))
+
+	body, _ := json.Marshal(config.EndpointConfig{RPM: 50, TPM: 5, Algorithm: config.AlgorithmGCRA})
+	req := httptest.NewRequest("PUT", "/admin/ratelimits/API_KEY_1/endpoints/api/endpoint2", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer secret")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusCreated {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusCreated, resp.StatusCode)
+	}
+	if warning := resp.Header.Get(fiber.HeaderWarning); warning != "" {
+		t.Errorf("Expected no warning for a persisted change, got %q", warning)
+	}
+
+	saved, err := config.Load(path)
+	if err != nil {
+		t.Fatalf("Failed to reload config: %v", err)
+	}
+	if len(saved.RateLimits) != 1 || len(saved.RateLimits[0].Endpoints) != 2 {
+		t.Fatalf("Expected the new endpoint in the saved config, got %+v", saved.RateLimits)
+	}
+	if endpoint := saved.RateLimits[0].Endpoints[1]; endpoint.Path != "/api/endpoint2" || endpoint.RPM != 50 || endpoint.Algorithm != config.AlgorithmGCRA {
+		t.Errorf("Expected /api/endpoint2 at 50 RPM with gcra, got %+v", endpoint)
+	}
+	if saved.GlobalLimit.RPM != 1000 || saved.Admin.Token != "secret" {
+		t.Errorf("Expected other settings to be kept, got %+v and %+v", saved.GlobalLimit, saved.Admin)
+	}
+}
+
+func TestAdminHandler_ClusterMode(t *testing.T) {
+	cfg := &config.Configuration{
+		RateLimits: []config.RateLimit{
+			{
+				APIKey:    "API_KEY_1",
+				Endpoints: []config.EndpointConfig{{Path: "/api/endpoint1", RPM: 100, TPM: 10}},
+			},
+		},
+		Admin:   config.AdminConfig{Token: "secret"},
+		Cluster: config.ClusterConfig{Self: "127.0.0.1:7946", Peers: []string{"127.0.0.1:7946", "127.0.0.1:7947"}},
+	}
+	admin := NewAdminHandler(ratelimit.MustNew(ratelimit.Config{RateLimits: cfg.RateLimits}), cfg)
+	app := fiber.New()
+	admin.Register(app.Group("/admin"))
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           interface{}
+		expectedStatus int
+	}{
+		{name: "Reads are served", method: "GET", path: "/admin/ratelimits/API_KEY_1", expectedStatus: fiber.StatusOK},
+		{
+			name:           "Changes would not reach peers",
+			method:         "PUT",
+			path:           "/admin/ratelimits/API_KEY_1/endpoints/api/endpoint1",
+			body:           config.EndpointConfig{RPM: 50, TPM: 5},
+			expectedStatus: fiber.StatusConflict,
+		},
+		{name: "Deletes are refused too", method: "DELETE", path: "/admin/ratelimits/API_KEY_1", expectedStatus: fiber.StatusConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var reader io.Reader
+			if tt.body != nil {
+				data, _ := json.Marshal(tt.body)
+				reader = bytes.NewReader(data)
+			}
+			req := httptest.NewRequest(tt.method, tt.path, reader)
+			req.Header.Set("Content-Type", "application/json")
+			req.Header.Set("Authorization", "Bearer secret")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/metrics"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit/middleware"
+)
+
+// BatchReserveRequest represents a batch of reservations that are granted
+// together or not at all
+type BatchReserveRequest struct {
+	Requests []ReserveRequest `json:"requests"`
+}
+
+// BatchReserveResponse represents the response to a batch reservation
+type BatchReserveResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data struct {
+		Allowed      bool              `json:"allowed"`
+		RetryAfterMs int64             `json:"retryAfterMs,omitempty"`
+		Results      []ReservationData `json:"results"`
+	} `json:"data"`
+}
+
+// BatchReserver reserves batches of capacity atomically, either locally or
+// across a cluster
+type BatchReserver interface {
+	ReserveBatch(requests []ratelimit.BatchRequest) ([]*ratelimit.Reservation, error)
+}
+
+// BatchHandler handles batch reservation requests
+type BatchHandler struct {
+	limiter BatchReserver
+	metrics *metrics.Metrics
+}
+
+// NewBatchHandler creates a new BatchHandler instance
+func NewBatchHandler(limiter BatchReserver) *BatchHandler {
+	return &BatchHandler{
+		limiter: limiter,
+	}
+}
+
+// SetMetrics sets where the latency of batch requests is recorded
+func (h *BatchHandler) SetMetrics(m *metrics.Metrics) {
+	h.metrics = m
+}
+
+// Handle processes the batch reservation request
+func (h *BatchHandler) Handle(c *fiber.Ctx) error {
+	var request BatchReserveRequest
+	if err := c.BodyParser(&request); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if len(request.Requests) == 0 {
+		return sendError(c, fiber.StatusBadRequest, "Requests are required")
+	}
+
+	// Validate every request; batches are granted at once, so none may wait
+	requests := make([]ratelimit.BatchRequest, len(request.Requests))
+	for i := range request.Requests {
+		item := &request.Requests[i]
+		if err := validateRequest(item); err != nil {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("requests[%d]: %s", i, err.Error()))
+		}
+		if item.MaxWaitMs > 0 {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("requests[%d]: MaxWaitMs is not supported in batches", i))
+		}
+		requests[i] = ratelimit.BatchRequest{
+			ClientID:       item.ClientID,
+			Tokens:         item.Tokens,
+			Requests:       item.Requests,
+			APIKey:         item.APIKey,
+			TargetEndpoint: item.TargetEndpoint,
+		}
+	}
+
+	start := time.Now()
+	reservations, err := h.limiter.ReserveBatch(requests)
+	if errors.Is(err, ratelimit.ErrEmptyBatch) || errors.Is(err, ratelimit.ErrBatchTooLarge) {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+	if err != nil {
+		return sendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	response := BatchReserveResponse{}
+	response.Status.Code = fiber.StatusOK
+	response.Status.Message = "Success"
+	response.Data.Allowed = true
+	response.Data.Results = make([]ReservationData, len(reservations))
+	for i, reservation := range reservations {
+		response.Data.Results[i] = toReservationData(reservation)
+		if !reservation.Allowed {
+			response.Data.Allowed = false
+		}
+		if reservation.RetryAfterMs > response.Data.RetryAfterMs {
+			response.Data.RetryAfterMs = reservation.RetryAfterMs
+		}
+	}
+	h.metrics.ObserveReserve(time.Since(start), response.Data.Allowed)
+
+	if !response.Data.Allowed {
+		if response.Data.RetryAfterMs > 0 {
+			c.Set("Retry-After", strconv.FormatInt(middleware.Seconds(response.Data.RetryAfterMs), 10))
+		}
+		response.Status.Code = fiber.StatusTooManyRequests
+		response.Status.Message = "Rate limit exceeded"
+		return sendJSONResponse(c, fiber.StatusTooManyRequests, response)
+	}
+
+	return sendJSONResponse(c, fiber.StatusOK, response)
+}
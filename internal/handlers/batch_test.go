@@ -0,0 +1,128 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestBatchHandler_Handle(t *testing.T) {
+	tests := []struct {
+		name           string
+		requests       []ReserveRequest
+		expectedStatus int
+		wantAllowed    bool
+	}{
+		{
+			name: "Every endpoint has capacity",
+			requests: []ReserveRequest{
+				{ClientID: "test-client", Tokens: 5, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"},
+				{ClientID: "test-client", Tokens: 5, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint2"},
+			},
+			expectedStatus: fiber.StatusOK,
+			wantAllowed:    true,
+		},
+		{
+			name: "One endpoint over its limit",
+			requests: []ReserveRequest{
+				{ClientID: "test-client", Tokens: 5, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"},
+				{ClientID: "test-client", Tokens: 50, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint2"},
+			},
+			expectedStatus: fiber.StatusTooManyRequests,
+			wantAllowed:    false,
+		},
+		{
+			name: "Invalid item",
+			requests: []ReserveRequest{
+				{ClientID: "test-client", Tokens: 5, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"},
+				{Tokens: 5, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint2"},
+			},
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Empty batch",
+			requests:       nil,
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+				{
+					APIKey: "API_KEY_1",
+					Endpoints: []config.EndpointConfig{
+						{Path: "/api/endpoint1", RPM: 100, TPM: 10},
+						{Path: "/api/endpoint2", RPM: 100, TPM: 10},
+					},
+				},
+			}})
+			app := fiber.New()
+			app.Post("/reserve/batch", NewBatchHandler(limiter).Handle)
+
+			reqBody, _ := json.Marshal(BatchReserveRequest{Requests: tt.requests})
+			req := httptest.NewRequest("POST", "/reserve/batch", bytes.NewReader(reqBody))
+			req.Header.Set("Content-Type", "application/json")
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus == fiber.StatusBadRequest {
+				return
+			}
+
+			var response BatchReserveResponse
+			body, _ := io.ReadAll(resp.Body)
+			if err := json.Unmarshal(body, &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Data.Allowed != tt.wantAllowed {
+				t.Errorf("Expected allowed=%v, got %v", tt.wantAllowed, response.Data.Allowed)
+			}
+			if len(response.Data.Results) != len(tt.requests) {
+				t.Fatalf("Expected %d results, got %d", len(tt.requests), len(response.Data.Results))
+			}
+			for i, result := range response.Data.Results {
+				if result.Allowed != tt.wantAllowed {
+					t.Errorf("Expected result %d allowed=%v, got %v", i, tt.wantAllowed, result.Allowed)
+				}
+			}
+		})
+	}
+}
+
+// failingBatchReserver fails every batch the way an unreachable store or
+// cluster peer would
+type failingBatchReserver struct{}
+
+func (failingBatchReserver) ReserveBatch([]ratelimit.BatchRequest) ([]*ratelimit.Reservation, error) {
+	return nil, errors.New("store unavailable")
+}
+
+func TestBatchHandler_StoreError(t *testing.T) {
+	app := fiber.New()
+	app.Post("/reserve/batch", NewBatchHandler(failingBatchReserver{}).Handle)
+
+	reqBody, _ := json.Marshal(BatchReserveRequest{Requests: []ReserveRequest{
+		{ClientID: "test-client", Tokens: 5, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"},
+	}})
+	req := httptest.NewRequest("POST", "/reserve/batch", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusInternalServerError {
+		t.Errorf("Expected status %d, got %d", fiber.StatusInternalServerError, resp.StatusCode)
+	}
+}
@@ -0,0 +1,190 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/middleware/proxy"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit/middleware"
+)
+
+// Gateway defaults for settings left empty in the configuration
+const (
+	defaultAPIKeyHeader   = "X-API-Key"
+	defaultClientIDHeader = "X-Client-ID"
+	defaultTokensHeader   = "X-Tokens"
+	defaultGatewayTimeout = 30 * time.Second
+)
+
+// Limiter reserves capacity, singly or in batches, settles pending
+// reservations, inspects quotas and grants leases, either locally or
+// across a cluster
+type Limiter interface {
+	Reserver
+	BatchReserver
+	Settler
+	Quoter
+	Leaser
+}
+
+// gatewayRoute is a target endpoint and the upstream it is proxied to
+type gatewayRoute struct {
+	endpoint string
+	prefix   string
+	upstream string
+}
+
+// GatewayHandler enforces rate limits in front of upstream services. Each
+// request to a target endpoint reserves one request, plus the tokens named
+// in the tokens header, against the caller's API key and is proxied
+// upstream if allowed.
+type GatewayHandler struct {
+	limiter Limiter
+	cfg     config.GatewayConfig
+	routes  []gatewayRoute
+	mutex   sync.RWMutex
+}
+
+// NewGatewayHandler creates a new GatewayHandler instance
+func NewGatewayHandler(limiter Limiter, cfg config.GatewayConfig, targets []config.TargetEndpoint) *GatewayHandler {
+	h := &GatewayHandler{
+		limiter: limiter,
+	}
+	h.Update(cfg, targets)
+	return h
+}
+
+// Update replaces the gateway settings and target endpoints
+func (h *GatewayHandler) Update(cfg config.GatewayConfig, targets []config.TargetEndpoint) {
+	if cfg.APIKeyHeader == "" {
+		cfg.APIKeyHeader = defaultAPIKeyHeader
+	}
+	if cfg.ClientIDHeader == "" {
+		cfg.ClientIDHeader = defaultClientIDHeader
+	}
+	if cfg.TokensHeader == "" {
+		cfg.TokensHeader = defaultTokensHeader
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultGatewayTimeout
+	}
+
+	routes := make([]gatewayRoute, 0, len(targets))
+	for _, target := range targets {
+		upstream := target.Upstream
+		if upstream == "" {
+			upstream = cfg.Upstream
+		}
+		routes = append(routes, gatewayRoute{
+			endpoint: target.Path,
+			prefix:   strings.TrimSuffix(target.Path, "/"),
+			upstream: strings.TrimSuffix(upstream, "/"),
+		})
+	}
+	// The most specific target endpoint wins
+	sort.SliceStable(routes, func(i, j int) bool {
+		return len(routes[i].prefix) > len(routes[j].prefix)
+	})
+
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.cfg = cfg
+	h.routes = routes
+}
+
+// match returns the target endpoint a request path falls under
+func (h *GatewayHandler) match(path string) (gatewayRoute, config.GatewayConfig, bool) {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	for _, route := range h.routes {
+		if path == route.endpoint || strings.HasPrefix(path, route.prefix+"/") {
+			return route, h.cfg, true
+		}
+	}
+	return gatewayRoute{}, h.cfg, false
+}
+
+// Handle charges requests to target endpoints and proxies them upstream.
+// Requests to other paths are passed on to the next handler. The tokens
+// header is raised to the configured minimum, and only usage the upstream
+// reports can settle a request for less.
+func (h *GatewayHandler) Handle(c *fiber.Ctx) error {
+	route, cfg, ok := h.match(c.Path())
+	if !ok {
+		return c.Next()
+	}
+
+	apiKey := c.Get(cfg.APIKeyHeader)
+	if apiKey == "" {
+		return sendError(c, fiber.StatusUnauthorized, fmt.Sprintf("%s header is required", cfg.APIKeyHeader))
+	}
+	clientID := c.Get(cfg.ClientIDHeader)
+	if clientID == "" {
+		clientID = c.IP()
+	}
+	tokens := 0
+	if value := c.Get(cfg.TokensHeader); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return sendError(c, fiber.StatusBadRequest, fmt.Sprintf("%s header must be a non-negative integer", cfg.TokensHeader))
+		}
+		tokens = n
+	}
+	if tokens < cfg.MinTokens {
+		tokens = cfg.MinTokens
+	}
+
+	reservation := h.limiter.Reserve(clientID, tokens, 1, apiKey, route.endpoint)
+	if reservation.UnknownKey {
+		return sendError(c, fiber.StatusForbidden, "Unknown API key or endpoint")
+	}
+	if !reservation.Allowed {
+		middleware.SetHeaders(reservation, c.Set)
+		return sendError(c, fiber.StatusTooManyRequests, "Rate limit exceeded")
+	}
+
+	if err := proxy.DoTimeout(c, route.upstream+c.OriginalURL(), cfg.Timeout); err != nil {
+		log.Printf("Error proxying %s to %s: %v", c.Path(), route.upstream, err)
+		h.settle(reservation.ReservationID, nil)
+		c.Response().Reset()
+		return sendError(c, fiber.StatusBadGateway, "Upstream unavailable")
+	}
+
+	// The upstream response replaces ours, so the headers go on afterwards
+	middleware.SetHeaders(reservation, c.Set)
+	used := tokens
+	if cfg.UsageHeader != "" {
+		if n, err := strconv.Atoi(string(c.Response().Header.Peek(cfg.UsageHeader))); err == nil && n >= 0 {
+			used = n
+		}
+	}
+	h.settle(reservation.ReservationID, &used)
+	return nil
+}
+
+// settle commits a pending reservation with the tokens used, or cancels it
+// if used is nil because the request never reached the upstream
+func (h *GatewayHandler) settle(id string, used *int) {
+	if id == "" {
+		return
+	}
+
+	var err error
+	if used != nil {
+		_, err = h.limiter.Commit(id, *used)
+	} else {
+		_, err = h.limiter.Cancel(id)
+	}
+	if err != nil {
+		log.Printf("Error settling reservation %s: %v", id, err)
+	}
+}
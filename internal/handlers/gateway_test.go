@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestGatewayHandler_Handle(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Tokens-Used", "3")
+		w.Write([]byte("upstream " + r.URL.RequestURI()))
+	}))
+	defer upstream.Close()
+
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 3, TPM: 10},
+				{Path: "/api/endpoint2", RPM: 10, TPM: 10},
+			},
+		},
+	}})
+	limiter.SetReservationTTL(time.Minute)
+
+	gateway := NewGatewayHandler(limiter, config.GatewayConfig{
+		Enabled:     true,
+		Upstream:    upstream.URL,
+		UsageHeader: "X-Tokens-Used",
+	}, []config.TargetEndpoint{
+		{Path: "/api/endpoint1"},
+		{Path: "/api/endpoint2", Upstream: "http://127.0.0.1:1"},
+	})
+	app := fiber.New()
+	app.Get("/health", func(c *fiber.Ctx) error { return c.SendString("ok") })
+	app.Use(gateway.Handle)
+
+	tests := []struct {
+		name           string
+		path           string
+		apiKey         string
+		tokens         string
+		expectedStatus int
+		expectedBody   string
+		expectedLimit  string
+	}{
+		{
+			name:           "Allowed request is proxied",
+			path:           "/api/endpoint1/items?page=2",
+			apiKey:         "API_KEY_1",
+			tokens:         "8",
+			expectedStatus: fiber.StatusOK,
+			expectedBody:   "upstream /api/endpoint1/items?page=2",
+			expectedLimit:  "3",
+		},
+		{
+			// Only the 3 tokens the upstream reported were kept
+			name:           "Unused tokens are refunded",
+			path:           "/api/endpoint1",
+			apiKey:         "API_KEY_1",
+			tokens:         "7",
+			expectedStatus: fiber.StatusOK,
+			expectedBody:   "upstream /api/endpoint1",
+			expectedLimit:  "3",
+		},
+		{
+			name:           "Over the limit",
+			path:           "/api/endpoint1",
+			apiKey:         "API_KEY_1",
+			tokens:         "5",
+			expectedStatus: fiber.StatusTooManyRequests,
+			expectedLimit:  "3",
+		},
+		{
+			name:           "Missing API key",
+			path:           "/api/endpoint1",
+			expectedStatus: fiber.StatusUnauthorized,
+		},
+		{
+			name:           "Unknown API key",
+			path:           "/api/endpoint1",
+			apiKey:         "UNKNOWN_KEY",
+			tokens:         "1",
+			expectedStatus: fiber.StatusForbidden,
+		},
+		{
+			name:           "Invalid tokens header",
+			path:           "/api/endpoint1",
+			apiKey:         "API_KEY_1",
+			tokens:         "many",
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Upstream unavailable",
+			path:           "/api/endpoint2",
+			apiKey:         "API_KEY_1",
+			tokens:         "10",
+			expectedStatus: fiber.StatusBadGateway,
+		},
+		{
+			// The failed request's tokens were refunded
+			name:           "Refunded after upstream failure",
+			path:           "/api/endpoint2",
+			apiKey:         "API_KEY_1",
+			tokens:         "10",
+			expectedStatus: fiber.StatusBadGateway,
+		},
+		{
+			name:           "Other routes are not charged",
+			path:           "/health",
+			expectedStatus: fiber.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:           "Unknown path",
+			path:           "/api/endpoint3",
+			apiKey:         "API_KEY_1",
+			expectedStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", tt.path, nil)
+			if tt.apiKey != "" {
+				req.Header.Set("X-API-Key", tt.apiKey)
+			}
+			if tt.tokens != "" {
+				req.Header.Set("X-Tokens", tt.tokens)
+			}
+
+			resp, err := app.Test(req, 5000)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("RateLimit-Limit"); got != tt.expectedLimit {
+				t.Errorf("Expected RateLimit-Limit %q, got %q", tt.expectedLimit, got)
+			}
+			if tt.expectedBody != "" {
+				body, err := io.ReadAll(resp.Body)
+				if err != nil {
+					t.Fatalf("Failed to read response body: %v", err)
+				}
+				if string(body) != tt.expectedBody {
+					t.Errorf("Expected body %q, got %q", tt.expectedBody, body)
+				}
+			}
+		})
+	}
+}
+
+func TestGatewayHandler_MinTokens(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream"))
+	}))
+	defer upstream.Close()
+
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey:    "API_KEY_1",
+			Endpoints: []config.EndpointConfig{{Path: "/api/endpoint1", RPM: 10, TPM: 10}},
+		},
+	}})
+	limiter.SetReservationTTL(time.Minute)
+
+	gateway := NewGatewayHandler(limiter, config.GatewayConfig{
+		Enabled:   true,
+		Upstream:  upstream.URL,
+		MinTokens: 5,
+	}, []config.TargetEndpoint{{Path: "/api/endpoint1"}})
+	app := fiber.New()
+	app.Use(gateway.Handle)
+
+	// Claiming no tokens still costs the minimum, so the third request
+	// is over the token limit
+	for i, want := range []int{fiber.StatusOK, fiber.StatusOK, fiber.StatusTooManyRequests} {
+		req := httptest.NewRequest("GET", "/api/endpoint1", nil)
+		req.Header.Set("X-API-Key", "API_KEY_1")
+		req.Header.Set("X-Tokens", "0")
+
+		resp, err := app.Test(req, 5000)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		if resp.StatusCode != want {
+			t.Errorf("Request %d: expected status %d, got %d", i, want, resp.StatusCode)
+		}
+	}
+}
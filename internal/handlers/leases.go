@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit/middleware"
+)
+
+// LeaseRequest represents a request for a lease on an endpoint's budget
+type LeaseRequest struct {
+	ClientID       string `json:"clientID"`
+	Tokens         int    `json:"tokens"`
+	Requests       int    `json:"requests"`
+	APIKey         string `json:"apiKey"`
+	TargetEndpoint string `json:"targetEndpoint"`
+	TTLMs          int    `json:"ttlMs"`
+}
+
+// ReturnLeaseRequest represents the body of a lease return: what the
+// holder actually used
+type ReturnLeaseRequest struct {
+	Tokens   int `json:"tokens"`
+	Requests int `json:"requests"`
+}
+
+// LeaseResponse represents the response to a lease request
+type LeaseResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data ratelimit.Lease `json:"data"`
+}
+
+// Leaser grants and takes back leases on endpoint budgets, either locally
+// or across a cluster
+type Leaser interface {
+	Lease(clientID string, tokens, requests int, apiKey, targetEndpoint string, ttl time.Duration) (*ratelimit.Lease, error)
+	ReturnLease(id string, usedTokens, usedRequests int) (*ratelimit.Settlement, error)
+}
+
+// LeaseHandler handles lease requests and returns
+type LeaseHandler struct {
+	limiter Leaser
+}
+
+// NewLeaseHandler creates a new LeaseHandler instance
+func NewLeaseHandler(limiter Leaser) *LeaseHandler {
+	return &LeaseHandler{
+		limiter: limiter,
+	}
+}
+
+// Lease grants a lease if it fits, or denies it with a Retry-After hint
+func (h *LeaseHandler) Lease(c *fiber.Ctx) error {
+	var request LeaseRequest
+	if err := c.BodyParser(&request); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if err := validateRequest(&ReserveRequest{
+		ClientID:       request.ClientID,
+		Tokens:         request.Tokens,
+		Requests:       request.Requests,
+		APIKey:         request.APIKey,
+		TargetEndpoint: request.TargetEndpoint,
+	}); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
+	}
+	if request.TTLMs <= 0 {
+		return sendError(c, fiber.StatusBadRequest, "TTLMs must be positive")
+	}
+
+	lease, err := h.limiter.Lease(
+		request.ClientID,
+		request.Tokens,
+		request.Requests,
+		request.APIKey,
+		request.TargetEndpoint,
+		time.Duration(request.TTLMs)*time.Millisecond,
+	)
+	if errors.Is(err, ratelimit.ErrUnknownKey) {
+		return sendError(c, fiber.StatusNotFound, "Unknown API key or endpoint")
+	}
+	if err != nil {
+		return sendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	response := LeaseResponse{}
+	response.Status.Code = fiber.StatusOK
+	response.Status.Message = "Success"
+	response.Data = *lease
+
+	if !lease.Granted {
+		if lease.RetryAfterMs > 0 {
+			c.Set("Retry-After", strconv.FormatInt(middleware.Seconds(lease.RetryAfterMs), 10))
+		}
+		response.Status.Code = fiber.StatusTooManyRequests
+		response.Status.Message = "Rate limit exceeded"
+		return sendJSONResponse(c, fiber.StatusTooManyRequests, response)
+	}
+
+	return sendJSONResponse(c, fiber.StatusOK, response)
+}
+
+// Return ends a lease early, refunding what its holder did not use
+func (h *LeaseHandler) Return(c *fiber.Ctx) error {
+	var request ReturnLeaseRequest
+	if err := c.BodyParser(&request); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if request.Tokens < 0 || request.Requests < 0 {
+		return sendError(c, fiber.StatusBadRequest, "Tokens and requests must be non-negative")
+	}
+
+	settlement, err := h.limiter.ReturnLease(c.Params("id"), request.Tokens, request.Requests)
+	if errors.Is(err, ratelimit.ErrLeaseNotFound) {
+		return sendError(c, fiber.StatusNotFound, "Lease not found or expired")
+	}
+	if err != nil {
+		return sendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	response := SettlementResponse{}
+	response.Status.Code = fiber.StatusOK
+	response.Status.Message = "Success"
+	response.Data = *settlement
+	return sendJSONResponse(c, fiber.StatusOK, response)
+}
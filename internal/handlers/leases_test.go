@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestLeaseHandler(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 100, TPM: 100},
+			},
+		},
+	}})
+
+	handler := NewLeaseHandler(limiter)
+	app := fiber.New()
+	app.Post("/leases", handler.Lease)
+	app.Post("/leases/:id/return", handler.Return)
+
+	returned, err := limiter.Lease("test-client", 20, 2, "API_KEY_1", "/api/endpoint1", time.Minute)
+	if err != nil || !returned.Granted {
+		t.Fatalf("Expected the lease to be granted, got %+v, %v", returned, err)
+	}
+
+	tests := []struct {
+		name            string
+		path            string
+		body            string
+		expectedStatus  int
+		wantRetryAfter  bool
+		wantRefunded    int
+		wantLeaseTokens int
+	}{
+		{
+			name:            "Lease",
+			path:            "/leases",
+			body:            `{"clientID": "test-client", "apiKey": "API_KEY_1", "targetEndpoint": "/api/endpoint1", "tokens": 60, "requests": 10, "ttlMs": 5000}`,
+			expectedStatus:  fiber.StatusOK,
+			wantLeaseTokens: 60,
+		},
+		{
+			name:           "Lease past the budget",
+			path:           "/leases",
+			body:           `{"clientID": "test-client", "apiKey": "API_KEY_1", "targetEndpoint": "/api/endpoint1", "tokens": 30, "requests": 10, "ttlMs": 5000}`,
+			expectedStatus: fiber.StatusTooManyRequests,
+			wantRetryAfter: true,
+		},
+		{
+			name:           "Missing TTL",
+			path:           "/leases",
+			body:           `{"clientID": "test-client", "apiKey": "API_KEY_1", "targetEndpoint": "/api/endpoint1", "tokens": 1, "requests": 1}`,
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Unknown API key",
+			path:           "/leases",
+			body:           `{"clientID": "test-client", "apiKey": "API_KEY_9", "targetEndpoint": "/api/endpoint1", "tokens": 1, "requests": 1, "ttlMs": 5000}`,
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:           "Return",
+			path:           "/leases/" + returned.LeaseID + "/return",
+			body:           `{"tokens": 5, "requests": 1}`,
+			expectedStatus: fiber.StatusOK,
+			wantRefunded:   15,
+		},
+		{
+			name:           "Return twice",
+			path:           "/leases/" + returned.LeaseID + "/return",
+			body:           `{"tokens": 5, "requests": 1}`,
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:           "Negative usage",
+			path:           "/leases/unknown/return",
+			body:           `{"tokens": -1}`,
+			expectedStatus: fiber.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("POST", tt.path, bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if got := resp.Header.Get("Retry-After") != ""; got != tt.wantRetryAfter {
+				t.Errorf("Expected Retry-After set %v, got %q", tt.wantRetryAfter, resp.Header.Get("Retry-After"))
+			}
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			if tt.wantLeaseTokens > 0 {
+				var response LeaseResponse
+				if err := json.Unmarshal(body, &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if !response.Data.Granted || response.Data.LeaseID == "" || response.Data.Tokens != tt.wantLeaseTokens {
+					t.Errorf("Expected a granted lease of %d tokens, got %+v", tt.wantLeaseTokens, response.Data)
+				}
+				return
+			}
+
+			var response SettlementResponse
+			if err := json.Unmarshal(body, &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Data.RefundedTokens != tt.wantRefunded {
+				t.Errorf("Expected %d refunded tokens, got %d", tt.wantRefunded, response.Data.RefundedTokens)
+			}
+		})
+	}
+}
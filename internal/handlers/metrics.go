@@ -0,0 +1,31 @@
+package handlers
+
+import (
+	"bytes"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/metrics"
+)
+
+// MetricsHandler serves metrics in the Prometheus text format
+type MetricsHandler struct {
+	registry *metrics.Registry
+}
+
+// NewMetricsHandler creates a new MetricsHandler instance
+func NewMetricsHandler(registry *metrics.Registry) *MetricsHandler {
+	return &MetricsHandler{
+		registry: registry,
+	}
+}
+
+// Handle renders every registered metric
+func (h *MetricsHandler) Handle(c *fiber.Ctx) error {
+	var buf bytes.Buffer
+	if err := h.registry.Write(&buf); err != nil {
+		return c.Status(fiber.StatusInternalServerError).SendString("Internal Server Error")
+	}
+
+	c.Set("Content-Type", metrics.ContentType)
+	return c.Status(fiber.StatusOK).Send(buf.Bytes())
+}
@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/internal/metrics"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestMetricsHandler_Handle(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 100, TPM: 10},
+			},
+		},
+	}})
+	m := metrics.New()
+	limiter.SetMetrics(m)
+
+	handler := NewReserveHandler(limiter)
+	handler.SetMetrics(m)
+	app := fiber.New()
+	app.Post("/reserve", handler.Handle)
+	app.Get("/metrics", NewMetricsHandler(m.Registry).Handle)
+
+	reqBody, _ := json.Marshal(ReserveRequest{
+		ClientID:       "test-client",
+		Tokens:         20,
+		Requests:       1,
+		APIKey:         "API_KEY_1",
+		TargetEndpoint: "/api/endpoint1",
+	})
+	req := httptest.NewRequest("POST", "/reserve", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+	if _, err := app.Test(req); err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+
+	resp, err := app.Test(httptest.NewRequest("GET", "/metrics", nil))
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Errorf("Expected status %d, got %d", fiber.StatusOK, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Content-Type"); got != metrics.ContentType {
+		t.Errorf("Expected content type %q, got %q", metrics.ContentType, got)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("Failed to read response body: %v", err)
+	}
+	for _, line := range []string{
+		`ratelimiter_reservations_denied_total{api_key="API_KEY_1",endpoint="/api/endpoint1",reason="endpoint_tpm"} 1`,
+		`ratelimiter_reserve_duration_seconds_count{result="denied"} 1`,
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", line, body)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"errors"
+	"net/url"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// QuotaResponse represents the response to a quota inspection
+type QuotaResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data struct {
+		APIKey   string            `json:"apiKey"`
+		Endpoint string            `json:"endpoint,omitempty"`
+		Quotas   []ratelimit.Quota `json:"quotas"`
+	} `json:"data"`
+}
+
+// Quoter inspects limits without consuming capacity, either locally or
+// across a cluster
+type Quoter interface {
+	Quota(apiKey, endpoint, clientID string) ([]ratelimit.Quota, error)
+}
+
+// QuotaHandler handles read-only quota inspection requests
+type QuotaHandler struct {
+	limiter Quoter
+}
+
+// NewQuotaHandler creates a new QuotaHandler instance
+func NewQuotaHandler(limiter Quoter) *QuotaHandler {
+	return &QuotaHandler{
+		limiter: limiter,
+	}
+}
+
+// Handle reports the limits and usage of an API key, or of one of its
+// endpoints when the path continues past the key. The clientID query
+// parameter adds that client's quota to an endpoint's limits.
+func (h *QuotaHandler) Handle(c *fiber.Ctx) error {
+	apiKey, err := url.PathUnescape(c.Params("apiKey"))
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid API key")
+	}
+	endpoint, err := url.PathUnescape(c.Params("*"))
+	if err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid endpoint")
+	}
+	if endpoint != "" {
+		endpoint = "/" + endpoint
+	}
+
+	quotas, err := h.limiter.Quota(apiKey, endpoint, c.Query("clientID"))
+	if errors.Is(err, ratelimit.ErrUnknownKey) {
+		return sendError(c, fiber.StatusNotFound, "Unknown API key or endpoint")
+	}
+	if err != nil {
+		return sendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	response := QuotaResponse{}
+	response.Status.Code = fiber.StatusOK
+	response.Status.Message = "Success"
+	response.Data.APIKey = apiKey
+	response.Data.Endpoint = endpoint
+	response.Data.Quotas = quotas
+	return sendJSONResponse(c, fiber.StatusOK, response)
+}
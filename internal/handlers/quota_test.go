@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestQuotaHandler_Handle(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 100, TPM: 10},
+				{Path: "/api/endpoint2", RPM: 50, TPM: 5},
+			},
+		},
+	}})
+	limiter.Reserve("test-client", 4, 1, "API_KEY_1", "/api/endpoint1")
+
+	app := fiber.New()
+	app.Get("/quota/:apiKey/*", NewQuotaHandler(limiter).Handle)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		wantPaths      []string
+	}{
+		{
+			name:           "Whole API key",
+			path:           "/quota/API_KEY_1",
+			expectedStatus: fiber.StatusOK,
+			wantPaths:      []string{"/api/endpoint1", "/api/endpoint2"},
+		},
+		{
+			name:           "Single endpoint",
+			path:           "/quota/API_KEY_1/api/endpoint1",
+			expectedStatus: fiber.StatusOK,
+			wantPaths:      []string{"/api/endpoint1"},
+		},
+		{
+			name:           "Unknown API key",
+			path:           "/quota/API_KEY_9",
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:           "Unknown endpoint",
+			path:           "/quota/API_KEY_1/api/missing",
+			expectedStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := app.Test(httptest.NewRequest("GET", tt.path, nil))
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			var response QuotaResponse
+			body, _ := io.ReadAll(resp.Body)
+			if err := json.Unmarshal(body, &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if len(response.Data.Quotas) != len(tt.wantPaths) {
+				t.Fatalf("Expected %d quotas, got %d", len(tt.wantPaths), len(response.Data.Quotas))
+			}
+			for i, quota := range response.Data.Quotas {
+				if quota.Path != tt.wantPaths[i] {
+					t.Errorf("Expected quota %d for %s, got %s", i, tt.wantPaths[i], quota.Path)
+				}
+				if quota.Path == "/api/endpoint1" && (quota.Requests.Used != 1 || quota.Tokens.Remaining != 6) {
+					t.Errorf("Expected 1 request used and 6 tokens left, got %+v and %+v", quota.Requests, quota.Tokens)
+				}
+			}
+		})
+	}
+
+	// Inspecting does not consume capacity
+	if reservation := limiter.Reserve("test-client", 6, 1, "API_KEY_1", "/api/endpoint1"); !reservation.Allowed || reservation.RemainingRequests != 98 {
+		t.Errorf("Expected 98 requests left after inspection, got %+v", reservation)
+	}
+}
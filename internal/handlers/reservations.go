@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit/middleware"
+)
+
+// CommitRequest represents the body of a reservation commit
+type CommitRequest struct {
+	Tokens int `json:"tokens"`
+}
+
+// SettlementResponse represents the response to a commit or cancellation
+type SettlementResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data ratelimit.Settlement `json:"data"`
+}
+
+// Settler commits and cancels pending reservations, either locally or
+// across a cluster
+type Settler interface {
+	Commit(id string, actualTokens int) (*ratelimit.Settlement, error)
+	Cancel(id string) (*ratelimit.Settlement, error)
+}
+
+// ReservationHandler handles commits and cancellations of pending
+// reservations
+type ReservationHandler struct {
+	limiter Settler
+}
+
+// NewReservationHandler creates a new ReservationHandler instance
+func NewReservationHandler(limiter Settler) *ReservationHandler {
+	return &ReservationHandler{
+		limiter: limiter,
+	}
+}
+
+// Commit confirms a reservation with the tokens actually used, refunding
+// the rest
+func (h *ReservationHandler) Commit(c *fiber.Ctx) error {
+	var request CommitRequest
+	if err := c.BodyParser(&request); err != nil {
+		return sendError(c, fiber.StatusBadRequest, "Invalid request format")
+	}
+	if request.Tokens < 0 {
+		return sendError(c, fiber.StatusBadRequest, "Tokens must be non-negative")
+	}
+
+	settlement, err := h.limiter.Commit(c.Params("id"), request.Tokens)
+	return h.respond(c, settlement, err)
+}
+
+// Cancel releases a reservation, refunding all of its tokens and requests
+func (h *ReservationHandler) Cancel(c *fiber.Ctx) error {
+	settlement, err := h.limiter.Cancel(c.Params("id"))
+	return h.respond(c, settlement, err)
+}
+
+// respond sends the settlement, or the error that prevented it
+func (h *ReservationHandler) respond(c *fiber.Ctx, settlement *ratelimit.Settlement, err error) error {
+	if errors.Is(err, ratelimit.ErrReservationNotFound) {
+		return sendError(c, fiber.StatusNotFound, "Reservation not found or expired")
+	}
+	if err != nil {
+		return sendError(c, fiber.StatusInternalServerError, err.Error())
+	}
+
+	response := SettlementResponse{}
+	response.Status.Code = fiber.StatusOK
+	response.Status.Message = "Success"
+	response.Data = *settlement
+	return sendJSONResponse(c, fiber.StatusOK, response)
+}
+
+// sendError sends an ErrorResponse with the given status and message
+func sendError(c *fiber.Ctx, status int, message string) error {
+	return sendJSONResponse(c, status, middleware.NewErrorResponse(status, message))
+}
@@ -0,0 +1,109 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestReservationHandler(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 100, TPM: 100},
+			},
+		},
+	}})
+	limiter.SetReservationTTL(time.Minute)
+
+	handler := NewReservationHandler(limiter)
+	app := fiber.New()
+	app.Post("/reservations/:id/commit", handler.Commit)
+	app.Delete("/reservations/:id", handler.Cancel)
+
+	committed := limiter.Reserve("test-client", 60, 1, "API_KEY_1", "/api/endpoint1")
+	cancelled := limiter.Reserve("test-client", 20, 1, "API_KEY_1", "/api/endpoint1")
+
+	tests := []struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+		wantRefunded   int
+	}{
+		{
+			name:           "Commit with actual tokens",
+			method:         "POST",
+			path:           "/reservations/" + committed.ReservationID + "/commit",
+			body:           `{"tokens": 15}`,
+			expectedStatus: fiber.StatusOK,
+			wantRefunded:   45,
+		},
+		{
+			name:           "Commit twice",
+			method:         "POST",
+			path:           "/reservations/" + committed.ReservationID + "/commit",
+			body:           `{"tokens": 15}`,
+			expectedStatus: fiber.StatusNotFound,
+		},
+		{
+			name:           "Negative tokens",
+			method:         "POST",
+			path:           "/reservations/" + cancelled.ReservationID + "/commit",
+			body:           `{"tokens": -1}`,
+			expectedStatus: fiber.StatusBadRequest,
+		},
+		{
+			name:           "Cancel",
+			method:         "DELETE",
+			path:           "/reservations/" + cancelled.ReservationID,
+			expectedStatus: fiber.StatusOK,
+			wantRefunded:   20,
+		},
+		{
+			name:           "Cancel unknown reservation",
+			method:         "DELETE",
+			path:           "/reservations/unknown",
+			expectedStatus: fiber.StatusNotFound,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, bytes.NewReader([]byte(tt.body)))
+			req.Header.Set("Content-Type", "application/json")
+
+			resp, err := app.Test(req)
+			if err != nil {
+				t.Fatalf("Failed to test request: %v", err)
+			}
+			if resp.StatusCode != tt.expectedStatus {
+				t.Errorf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus != fiber.StatusOK {
+				return
+			}
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatalf("Failed to read response body: %v", err)
+			}
+			var response SettlementResponse
+			if err := json.Unmarshal(body, &response); err != nil {
+				t.Fatalf("Failed to unmarshal response: %v", err)
+			}
+			if response.Data.RefundedTokens != tt.wantRefunded {
+				t.Errorf("Expected %d refunded tokens, got %d", tt.wantRefunded, response.Data.RefundedTokens)
+			}
+		})
+	}
+}
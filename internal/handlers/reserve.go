@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
-	"github.com/yourusername/ratelimiter/internal/ratelimiter"
+	"github.com/yourusername/ratelimiter/internal/config"
+	"github.com/yourusername/ratelimiter/internal/metrics"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit/middleware"
 )
 
 // ReserveRequest represents the incoming request structure
@@ -14,6 +20,7 @@ type ReserveRequest struct {
 	Requests       int    `json:"requests"`
 	APIKey         string `json:"apiKey"`
 	TargetEndpoint string `json:"targetEndpoint"`
+	MaxWaitMs      int    `json:"maxWaitMs"`
 }
 
 // ReserveResponse represents the API response structure
@@ -22,37 +29,57 @@ type ReserveResponse struct {
 		Code    int    `json:"code"`
 		Message string `json:"message"`
 	} `json:"status"`
-	Data struct {
-		Allowed            bool   `json:"allowed"`
-		ReservedTokens     int    `json:"reservedTokens"`
-		ReservedRequests   int    `json:"reservedRequests"`
-		RemainingTokens    int    `json:"remainingTokens"`
-		RemainingRequests  int    `json:"remainingRequests"`
-		TargetEndpointPath string `json:"targetEndpointPath"`
-	} `json:"data"`
+	Data ReservationData `json:"data"`
 }
 
-// ErrorResponse represents the error response structure
-type ErrorResponse struct {
-	Status struct {
-		Code    int    `json:"code"`
-		Message string `json:"message"`
-	} `json:"status"`
-	Error string `json:"error"`
+// ReservationData represents the outcome of a single reservation
+type ReservationData struct {
+	Allowed            bool       `json:"allowed"`
+	ReservedTokens     int        `json:"reservedTokens"`
+	ReservedRequests   int        `json:"reservedRequests"`
+	RemainingTokens    int        `json:"remainingTokens"`
+	RemainingRequests  int        `json:"remainingRequests"`
+	TargetEndpointPath string     `json:"targetEndpointPath"`
+	ReservationID      string     `json:"reservationID,omitempty"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"`
+	RetryAfterMs       int64      `json:"retryAfterMs,omitempty"`
+}
+
+// ErrorResponse represents the error response structure, shared with the
+// middleware
+type ErrorResponse = middleware.ErrorResponse
+
+// Reserver reserves rate limit capacity, either locally or across a cluster
+type Reserver interface {
+	Reserve(clientID string, tokens, requests int, apiKey, targetEndpoint string) *ratelimit.Reservation
+	ReserveWait(ctx context.Context, clientID string, tokens, requests int, apiKey, targetEndpoint string) *ratelimit.Reservation
 }
 
 // ReserveHandler handles rate limit reservation requests
 type ReserveHandler struct {
-	limiter *ratelimiter.RateLimiter
+	limiter Reserver
+	metrics *metrics.Metrics
+	maxWait int64
 }
 
 // NewReserveHandler creates a new ReserveHandler instance
-func NewReserveHandler(limiter *ratelimiter.RateLimiter) *ReserveHandler {
+func NewReserveHandler(limiter Reserver) *ReserveHandler {
 	return &ReserveHandler{
 		limiter: limiter,
 	}
 }
 
+// SetMaxWait sets the longest a reservation may queue for capacity. It is
+// safe to call while requests are handled.
+func (h *ReserveHandler) SetMaxWait(d time.Duration) {
+	atomic.StoreInt64(&h.maxWait, int64(d))
+}
+
+// SetMetrics sets where the latency of reservation requests is recorded
+func (h *ReserveHandler) SetMetrics(m *metrics.Metrics) {
+	h.metrics = m
+}
+
 // Handle processes the reservation request
 func (h *ReserveHandler) Handle(c *fiber.Ctx) error {
 	var request ReserveRequest
@@ -65,32 +92,41 @@ func (h *ReserveHandler) Handle(c *fiber.Ctx) error {
 	}
 
 	// Validate request
-	if err := h.validateRequest(&request); err != nil {
-		errResp := ErrorResponse{}
-		errResp.Status.Code = fiber.StatusBadRequest
-		errResp.Status.Message = "Error"
-		errResp.Error = err.Error()
-		return sendJSONResponse(c, fiber.StatusBadRequest, errResp)
+	if err := validateRequest(&request); err != nil {
+		return sendError(c, fiber.StatusBadRequest, err.Error())
 	}
 
-	// Process reservation
-	reservation := h.limiter.Reserve(
-		request.ClientID,
-		request.Tokens,
-		request.Requests,
-		request.APIKey,
-		request.TargetEndpoint,
-	)
+	// Process reservation, queueing for up to MaxWaitMs if requested. The
+	// wait also ends if the server shuts down.
+	start := time.Now()
+	var reservation *ratelimit.Reservation
+	if request.MaxWaitMs > 0 {
+		ctx, cancel := context.WithTimeout(c.Context(), config.ClampWait(int64(request.MaxWaitMs), time.Duration(atomic.LoadInt64(&h.maxWait))))
+		defer cancel()
+		reservation = h.limiter.ReserveWait(
+			ctx,
+			request.ClientID,
+			request.Tokens,
+			request.Requests,
+			request.APIKey,
+			request.TargetEndpoint,
+		)
+	} else {
+		reservation = h.limiter.Reserve(
+			request.ClientID,
+			request.Tokens,
+			request.Requests,
+			request.APIKey,
+			request.TargetEndpoint,
+		)
+	}
+	h.metrics.ObserveReserve(time.Since(start), reservation.Allowed)
 
 	response := ReserveResponse{}
 	response.Status.Code = fiber.StatusOK
 	response.Status.Message = "Success"
-	response.Data.Allowed = reservation.Allowed
-	response.Data.ReservedTokens = reservation.ReservedTokens
-	response.Data.ReservedRequests = reservation.ReservedRequests
-	response.Data.RemainingTokens = reservation.RemainingTokens
-	response.Data.RemainingRequests = reservation.RemainingRequests
-	response.Data.TargetEndpointPath = reservation.TargetEndpointPath
+	response.Data = toReservationData(reservation)
+	middleware.SetHeaders(reservation, c.Set)
 
 	if !reservation.Allowed {
 		response.Status.Code = fiber.StatusTooManyRequests
@@ -102,7 +138,7 @@ func (h *ReserveHandler) Handle(c *fiber.Ctx) error {
 }
 
 // validateRequest performs basic validation on the request
-func (h *ReserveHandler) validateRequest(request *ReserveRequest) error {
+func validateRequest(request *ReserveRequest) error {
 	if request.ClientID == "" {
 		return fiber.NewError(fiber.StatusBadRequest, "ClientID is required")
 	}
@@ -118,9 +154,27 @@ func (h *ReserveHandler) validateRequest(request *ReserveRequest) error {
 	if request.Requests < 0 {
 		return fiber.NewError(fiber.StatusBadRequest, "Requests must be non-negative")
 	}
+	if request.MaxWaitMs < 0 {
+		return fiber.NewError(fiber.StatusBadRequest, "MaxWaitMs must be non-negative")
+	}
 	return nil
 }
 
+// toReservationData converts a reservation to its response representation
+func toReservationData(reservation *ratelimit.Reservation) ReservationData {
+	return ReservationData{
+		Allowed:            reservation.Allowed,
+		ReservedTokens:     reservation.ReservedTokens,
+		ReservedRequests:   reservation.ReservedRequests,
+		RemainingTokens:    reservation.RemainingTokens,
+		RemainingRequests:  reservation.RemainingRequests,
+		TargetEndpointPath: reservation.TargetEndpointPath,
+		ReservationID:      reservation.ReservationID,
+		ExpiresAt:          reservation.ExpiresAt,
+		RetryAfterMs:       reservation.RetryAfterMs,
+	}
+}
+
 // sendJSONResponse sends a JSON response with proper formatting
 func sendJSONResponse(c *fiber.Ctx, status int, data interface{}) error {
 	c.Set("Content-Type", "application/json")
@@ -4,12 +4,14 @@ import (
 	"bytes"
 	"encoding/json"
 	"io"
+	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/yourusername/ratelimiter/internal/config"
-	"github.com/yourusername/ratelimiter/internal/ratelimiter"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
 )
 
 func TestReserveHandler_Handle(t *testing.T) {
@@ -28,7 +30,7 @@ func TestReserveHandler_Handle(t *testing.T) {
 	}
 
 	// Initialize components
-	limiter := ratelimiter.New(rateLimits)
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: rateLimits})
 	handler := NewReserveHandler(limiter)
 	app := fiber.New()
 	app.Post("/reserve", handler.Handle)
@@ -50,13 +52,11 @@ func TestReserveHandler_Handle(t *testing.T) {
 			},
 			expectedStatus: fiber.StatusOK,
 			checkResponse: func(t *testing.T, body []byte) {
-				var response struct {
-					Allowed bool `json:"allowed"`
-				}
+				var response ReserveResponse
 				if err := json.Unmarshal(body, &response); err != nil {
 					t.Fatalf("Failed to unmarshal response: %v", err)
 				}
-				if !response.Allowed {
+				if !response.Data.Allowed {
 					t.Error("Expected request to be allowed")
 				}
 			},
@@ -82,6 +82,29 @@ func TestReserveHandler_Handle(t *testing.T) {
 				}
 			},
 		},
+		{
+			name: "Negative max wait",
+			request: ReserveRequest{
+				ClientID:       "test-client",
+				Tokens:         5,
+				Requests:       1,
+				APIKey:         "API_KEY_1",
+				TargetEndpoint: "/api/endpoint1",
+				MaxWaitMs:      -1,
+			},
+			expectedStatus: fiber.StatusBadRequest,
+			checkResponse: func(t *testing.T, body []byte) {
+				var response struct {
+					Error string `json:"error"`
+				}
+				if err := json.Unmarshal(body, &response); err != nil {
+					t.Fatalf("Failed to unmarshal response: %v", err)
+				}
+				if response.Error != "MaxWaitMs must be non-negative" {
+					t.Errorf("Expected error message about MaxWaitMs, got: %s", response.Error)
+				}
+			},
+		},
 		{
 			name: "Invalid API key",
 			request: ReserveRequest{
@@ -93,13 +116,11 @@ func TestReserveHandler_Handle(t *testing.T) {
 			},
 			expectedStatus: fiber.StatusTooManyRequests,
 			checkResponse: func(t *testing.T, body []byte) {
-				var response struct {
-					Allowed bool `json:"allowed"`
-				}
+				var response ReserveResponse
 				if err := json.Unmarshal(body, &response); err != nil {
 					t.Fatalf("Failed to unmarshal response: %v", err)
 				}
-				if response.Allowed {
+				if response.Data.Allowed {
 					t.Error("Expected request to be denied")
 				}
 			},
@@ -115,13 +136,11 @@ func TestReserveHandler_Handle(t *testing.T) {
 			},
 			expectedStatus: fiber.StatusTooManyRequests,
 			checkResponse: func(t *testing.T, body []byte) {
-				var response struct {
-					Allowed bool `json:"allowed"`
-				}
+				var response ReserveResponse
 				if err := json.Unmarshal(body, &response); err != nil {
 					t.Fatalf("Failed to unmarshal response: %v", err)
 				}
-				if response.Allowed {
+				if response.Data.Allowed {
 					t.Error("Expected request to be denied")
 				}
 			},
@@ -162,3 +181,108 @@ func TestReserveHandler_Handle(t *testing.T) {
 		})
 	}
 }
+
+func TestReserveHandler_RateLimitHeaders(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 2, TPM: 100},
+			},
+		},
+	}})
+	handler := NewReserveHandler(limiter)
+	app := fiber.New()
+	app.Post("/reserve", handler.Handle)
+
+	reserve := func(requests int) (*http.Response, ReserveResponse) {
+		reqBody, _ := json.Marshal(ReserveRequest{
+			ClientID:       "test-client",
+			Tokens:         1,
+			Requests:       requests,
+			APIKey:         "API_KEY_1",
+			TargetEndpoint: "/api/endpoint1",
+		})
+		req := httptest.NewRequest("POST", "/reserve", bytes.NewReader(reqBody))
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatalf("Failed to test request: %v", err)
+		}
+		var response ReserveResponse
+		body, _ := io.ReadAll(resp.Body)
+		if err := json.Unmarshal(body, &response); err != nil {
+			t.Fatalf("Failed to unmarshal response: %v", err)
+		}
+		return resp, response
+	}
+
+	resp, _ := reserve(2)
+	for header, want := range map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "60",
+		"Retry-After":         "",
+	} {
+		if got := resp.Header.Get(header); got != want {
+			t.Errorf("Expected %s %q on an allowed reservation, got %q", header, want, got)
+		}
+	}
+
+	resp, response := reserve(1)
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Fatalf("Expected status %d, got %d", fiber.StatusTooManyRequests, resp.StatusCode)
+	}
+	if got := resp.Header.Get("Retry-After"); got != "60" {
+		t.Errorf("Expected Retry-After 60 on a denial, got %q", got)
+	}
+	if response.Data.RetryAfterMs <= 59000 || response.Data.RetryAfterMs > 60000 {
+		t.Errorf("Expected retryAfterMs close to 60000, got %d", response.Data.RetryAfterMs)
+	}
+
+	// Reservations larger than the limit can never succeed
+	resp, response = reserve(3)
+	if got := resp.Header.Get("Retry-After"); got != "" || response.Data.RetryAfterMs != 0 {
+		t.Errorf("Expected no retry hint for an oversized reservation, got %q and %d", got, response.Data.RetryAfterMs)
+	}
+}
+
+func TestReserveHandler_MaxWaitIsCapped(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []config.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []config.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 1, TPM: 100},
+			},
+		},
+	}})
+	limiter.Reserve("test-client", 1, 1, "API_KEY_1", "/api/endpoint1")
+
+	handler := NewReserveHandler(limiter)
+	handler.SetMaxWait(50 * time.Millisecond)
+	app := fiber.New()
+	app.Post("/reserve", handler.Handle)
+
+	reqBody, _ := json.Marshal(ReserveRequest{
+		ClientID:       "test-client",
+		Tokens:         1,
+		Requests:       1,
+		APIKey:         "API_KEY_1",
+		TargetEndpoint: "/api/endpoint1",
+		MaxWaitMs:      600000,
+	})
+	req := httptest.NewRequest("POST", "/reserve", bytes.NewReader(reqBody))
+	req.Header.Set("Content-Type", "application/json")
+
+	start := time.Now()
+	resp, err := app.Test(req, 5000)
+	if err != nil {
+		t.Fatalf("Failed to test request: %v", err)
+	}
+	if resp.StatusCode != fiber.StatusTooManyRequests {
+		t.Errorf("Expected status %d, got %d", fiber.StatusTooManyRequests, resp.StatusCode)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected the wait to be capped at 50ms, took %v", elapsed)
+	}
+}
@@ -0,0 +1,101 @@
+package metrics
+
+import (
+	"time"
+)
+
+// Labels of allowed and denied reservations
+const (
+	ResultAllowed = "allowed"
+	ResultDenied  = "denied"
+)
+
+// reserveBuckets are the Reserve latency buckets in seconds. Reservations
+// that queue for capacity can take up to their max wait, so the buckets
+// reach well past a single store round trip.
+var reserveBuckets = []float64{
+	0.0001, 0.00025, 0.0005, 0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10,
+}
+
+// Metrics records what the rate limiter decides, as its Recorder. A nil *Metrics records
+// nothing, so callers need not check whether metrics are enabled.
+type Metrics struct {
+	Registry *Registry
+
+	allowed           *Counter
+	denied            *Counter
+	remainingRequests *Gauge
+	remainingTokens   *Gauge
+	reserveDuration   *Histogram
+}
+
+// New creates a new Metrics instance with its own registry
+func New() *Metrics {
+	registry := NewRegistry()
+	return &Metrics{
+		Registry: registry,
+		allowed: registry.NewCounter("ratelimiter_reservations_allowed_total",
+			"Reservations allowed.", "api_key", "endpoint"),
+		denied: registry.NewCounter("ratelimiter_reservations_denied_total",
+			"Reservations denied, by the budget that denied them.", "api_key", "endpoint", "reason"),
+		remainingRequests: registry.NewGauge("ratelimiter_remaining_requests",
+			"Requests left in a budget as of its last reservation.", "level", "api_key", "endpoint"),
+		remainingTokens: registry.NewGauge("ratelimiter_remaining_tokens",
+			"Tokens left in a budget as of its last reservation.", "level", "api_key", "endpoint"),
+		reserveDuration: registry.NewHistogram("ratelimiter_reserve_duration_seconds",
+			"Time taken to answer reservation requests.", reserveBuckets, "result"),
+	}
+}
+
+// Allowed counts an allowed reservation
+func (m *Metrics) Allowed(apiKey, endpoint string) {
+	if m == nil {
+		return
+	}
+	m.allowed.Inc(apiKey, endpoint)
+}
+
+// Denied counts a denied reservation and the reason it was denied
+func (m *Metrics) Denied(apiKey, endpoint, reason string) {
+	if m == nil {
+		return
+	}
+	m.denied.Inc(apiKey, endpoint, reason)
+}
+
+// Remaining records the budget left at one level of the limit tree. A
+// negative value marks an uncapped budget, which is not recorded.
+func (m *Metrics) Remaining(level, apiKey, endpoint string, requests, tokens int) {
+	if m == nil {
+		return
+	}
+	if requests >= 0 {
+		m.remainingRequests.Set(float64(requests), level, apiKey, endpoint)
+	}
+	if tokens >= 0 {
+		m.remainingTokens.Set(float64(tokens), level, apiKey, endpoint)
+	}
+}
+
+// Reload drops the remaining budgets recorded under the previous limits,
+// so budgets that were removed or renamed stop being reported. Budgets that
+// are still configured reappear at their next reservation.
+func (m *Metrics) Reload() {
+	if m == nil {
+		return
+	}
+	m.remainingRequests.Reset()
+	m.remainingTokens.Reset()
+}
+
+// ObserveReserve records how long a reservation request took
+func (m *Metrics) ObserveReserve(duration time.Duration, allowed bool) {
+	if m == nil {
+		return
+	}
+	result := ResultDenied
+	if allowed {
+		result = ResultAllowed
+	}
+	m.reserveDuration.Observe(duration.Seconds(), result)
+}
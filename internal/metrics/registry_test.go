@@ -0,0 +1,79 @@
+package metrics
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+var _ ratelimit.Recorder = (*Metrics)(nil)
+
+func TestRegistry_Write(t *testing.T) {
+	registry := NewRegistry()
+	counter := registry.NewCounter("requests_total", "Requests served.", "code")
+	gauge := registry.NewGauge("temperature", "Current \\ temperature.")
+	histogram := registry.NewHistogram("latency_seconds", "Latency.", []float64{0.1, 1}, "path")
+
+	counter.Inc("500")
+	counter.Add(2, "200")
+	gauge.Set(-1.5)
+	histogram.Observe(0.05, `/a"b`)
+	histogram.Observe(0.5, `/a"b`)
+	histogram.Observe(5, `/a"b`)
+
+	var buf bytes.Buffer
+	if err := registry.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := `# HELP requests_total Requests served.
+# TYPE requests_total counter
+requests_total{code="200"} 2
+requests_total{code="500"} 1
+# HELP temperature Current \\ temperature.
+# TYPE temperature gauge
+temperature -1.5
+# HELP latency_seconds Latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a\"b",le="0.1"} 1
+latency_seconds_bucket{path="/a\"b",le="1"} 2
+latency_seconds_bucket{path="/a\"b",le="+Inf"} 3
+latency_seconds_sum{path="/a\"b"} 5.55
+latency_seconds_count{path="/a\"b"} 3
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestMetrics_NilIsNoop(t *testing.T) {
+	var m *Metrics
+	m.Allowed("API_KEY_1", "/test")
+	m.Denied("API_KEY_1", "/test", "endpoint_rpm")
+	m.Remaining("endpoint", "API_KEY_1", "/test", 1, 1)
+	m.ObserveReserve(time.Millisecond, true)
+	m.Reload()
+}
+
+func TestGauge_Reset(t *testing.T) {
+	registry := NewRegistry()
+	gauge := registry.NewGauge("remaining", "Remaining.", "endpoint")
+	gauge.Set(1, "/a")
+	gauge.Reset()
+	gauge.Set(2, "/b")
+
+	var buf bytes.Buffer
+	if err := registry.Write(&buf); err != nil {
+		t.Fatalf("Write failed: %v", err)
+	}
+
+	want := `# HELP remaining Remaining.
+# TYPE remaining gauge
+remaining{endpoint="/b"} 2
+`
+	if got := buf.String(); got != want {
+		t.Errorf("Unexpected exposition:\n%s\nwant:\n%s", got, want)
+	}
+}
@@ -7,22 +7,28 @@ import (
 	"github.com/yourusername/ratelimiter/internal/config"
 )
 
-func TestRateLimiter_Reserve(t *testing.T) {
-	// Test configuration
-	rateLimits := []config.RateLimit{
-		{
-			APIKey: "API_KEY_1",
-			Endpoints: []config.EndpointConfig{
-				{
-					Path: "/test",
-					RPM:  10,
-					TPM:  100,
+func testConfig(rpm int) *config.Configuration {
+	return &config.Configuration{
+		RateLimits: []config.RateLimit{
+			{
+				APIKey: "API_KEY_1",
+				Endpoints: []config.EndpointConfig{
+					{
+						Path: "/test",
+						RPM:  rpm,
+						TPM:  100,
+					},
 				},
 			},
 		},
 	}
+}
 
-	limiter := New(rateLimits)
+func TestRateLimiter_Reserve(t *testing.T) {
+	limiter, err := New(testConfig(10), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
 
 	tests := []struct {
 		name           string
@@ -45,17 +51,8 @@ func TestRateLimiter_Reserve(t *testing.T) {
 		{
 			name:           "Request exceeds RPM",
 			clientID:       "client1",
-			tokens:         50,
-			requests:       11,
-			apiKey:         "API_KEY_1",
-			targetEndpoint: "/test",
-			wantAllowed:    false,
-		},
-		{
-			name:           "Request exceeds TPM",
-			clientID:       "client1",
-			tokens:         101,
-			requests:       5,
+			tokens:         10,
+			requests:       6,
 			apiKey:         "API_KEY_1",
 			targetEndpoint: "/test",
 			wantAllowed:    false,
@@ -63,8 +60,8 @@ func TestRateLimiter_Reserve(t *testing.T) {
 		{
 			name:           "Invalid API key",
 			clientID:       "client1",
-			tokens:         50,
-			requests:       5,
+			tokens:         10,
+			requests:       1,
 			apiKey:         "INVALID_KEY",
 			targetEndpoint: "/test",
 			wantAllowed:    false,
@@ -73,60 +70,29 @@ func TestRateLimiter_Reserve(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			reservation := limiter.Reserve(
-				tt.clientID,
-				tt.tokens,
-				tt.requests,
-				tt.apiKey,
-				tt.targetEndpoint,
-			)
-
+			reservation := limiter.Reserve(tt.clientID, tt.tokens, tt.requests, tt.apiKey, tt.targetEndpoint)
 			if reservation.Allowed != tt.wantAllowed {
 				t.Errorf("Reserve() allowed = %v, want %v", reservation.Allowed, tt.wantAllowed)
 			}
-
-			// Add a small delay to avoid rate limit conflicts between tests
-			time.Sleep(100 * time.Millisecond)
 		})
 	}
 }
 
-func TestRateLimiter_ResetAfterOneMinute(t *testing.T) {
-	rateLimits := []config.RateLimit{
-		{
-			APIKey: "API_KEY_1",
-			Endpoints: []config.EndpointConfig{
-				{
-					Path: "/test",
-					RPM:  10,
-					TPM:  100,
-				},
-			},
-		},
-	}
-
-	limiter := New(rateLimits)
-
-	// Make initial requests
-	for i := 0; i < 5; i++ {
-		reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
-		if !reservation.Allowed {
-			t.Errorf("Expected request %d to be allowed", i+1)
-		}
+func TestRateLimiter_Reload(t *testing.T) {
+	limiter, err := New(testConfig(10), nil)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
 	}
 
-	// Simulate time passing (> 1 minute)
-	key := "API_KEY_1-/test"
-	state := limiter.apiKeyLimits[key]
-	state.LastRequest = time.Now().Add(-2 * time.Minute)
+	cfg := testConfig(20)
+	cfg.Reservations.TTL = time.Minute
+	limiter.Reload(cfg)
 
-	// Make another request
-	reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+	reservation := limiter.Reserve("client1", 10, 15, "API_KEY_1", "/test")
 	if !reservation.Allowed {
-		t.Error("Expected request to be allowed after counter reset")
+		t.Fatal("Expected the reloaded limit to allow 15 requests")
 	}
-
-	if reservation.RemainingRequests != 9 {
-		t.Errorf("Expected 9 remaining requests, got %d", reservation.RemainingRequests)
+	if reservation.ReservationID == "" {
+		t.Error("Expected the reloaded TTL to keep the reservation pending")
 	}
 }
@@ -0,0 +1,233 @@
+package redis
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Default connection settings
+const (
+	defaultPoolSize    = 16
+	defaultDialTimeout = 5 * time.Second
+	defaultIOTimeout   = 5 * time.Second
+)
+
+// ErrClosed is returned when the client has been closed
+var ErrClosed = errors.New("redis: client closed")
+
+// Error is an error reply sent by the server
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Client is a minimal RESP client that speaks to Redis or any server
+// implementing the Redis protocol
+type Client struct {
+	addr     string
+	password string
+	pool     chan *conn
+	closed   bool
+	mutex    sync.Mutex
+}
+
+// conn is a single pooled connection
+type conn struct {
+	netConn net.Conn
+	reader  *bufio.Reader
+	writer  *bufio.Writer
+}
+
+// NewClient creates a new Client for the server at addr. An empty password
+// skips AUTH.
+func NewClient(addr, password string) *Client {
+	return &Client{
+		addr:     addr,
+		password: password,
+		pool:     make(chan *conn, defaultPoolSize),
+	}
+}
+
+// Do sends a command and returns its reply. Replies are decoded as string
+// (simple and bulk strings), int64, []interface{}, nil for null replies,
+// or an Error for error replies.
+func (c *Client) Do(args ...string) (interface{}, error) {
+	cn, err := c.get()
+	if err != nil {
+		return nil, err
+	}
+
+	reply, err := cn.do(args)
+	if err != nil {
+		var replyErr Error
+		if errors.As(err, &replyErr) {
+			c.put(cn)
+		} else {
+			cn.netConn.Close()
+		}
+		return nil, err
+	}
+
+	c.put(cn)
+	return reply, nil
+}
+
+// Close closes all idle connections and rejects further commands
+func (c *Client) Close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	close(c.pool)
+	for cn := range c.pool {
+		cn.netConn.Close()
+	}
+	return nil
+}
+
+// get returns an idle connection or dials a new one
+func (c *Client) get() (*conn, error) {
+	c.mutex.Lock()
+	closed := c.closed
+	c.mutex.Unlock()
+	if closed {
+		return nil, ErrClosed
+	}
+
+	select {
+	case cn, ok := <-c.pool:
+		if ok {
+			return cn, nil
+		}
+		return nil, ErrClosed
+	default:
+	}
+
+	netConn, err := net.DialTimeout("tcp", c.addr, defaultDialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	cn := &conn{
+		netConn: netConn,
+		reader:  bufio.NewReader(netConn),
+		writer:  bufio.NewWriter(netConn),
+	}
+
+	if c.password != "" {
+		if _, err := cn.do([]string{"AUTH", c.password}); err != nil {
+			netConn.Close()
+			return nil, err
+		}
+	}
+
+	return cn, nil
+}
+
+// put returns a healthy connection to the pool
+func (c *Client) put(cn *conn) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.closed {
+		cn.netConn.Close()
+		return
+	}
+	select {
+	case c.pool <- cn:
+	default:
+		cn.netConn.Close()
+	}
+}
+
+// do writes a command and reads its reply
+func (cn *conn) do(args []string) (interface{}, error) {
+	cn.netConn.SetDeadline(time.Now().Add(defaultIOTimeout))
+
+	fmt.Fprintf(cn.writer, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(cn.writer, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if err := cn.writer.Flush(); err != nil {
+		return nil, err
+	}
+
+	return cn.readReply()
+}
+
+// readReply decodes a single RESP reply
+func (cn *conn) readReply() (interface{}, error) {
+	line, err := cn.readLine()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("redis: empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, Error(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if size < 0 {
+			return nil, nil
+		}
+		buf := make([]byte, size+2)
+		if _, err := io.ReadFull(cn.reader, buf); err != nil {
+			return nil, err
+		}
+		return string(buf[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return nil, err
+		}
+		if count < 0 {
+			return nil, nil
+		}
+		items := make([]interface{}, count)
+		for i := range items {
+			item, err := cn.readReply()
+			if err != nil {
+				var replyErr Error
+				if !errors.As(err, &replyErr) {
+					return nil, err
+				}
+				item = replyErr
+			}
+			items[i] = item
+		}
+		return items, nil
+	default:
+		return nil, fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
+
+// readLine reads a CRLF-terminated line without the terminator
+func (cn *conn) readLine() (string, error) {
+	line, err := cn.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", fmt.Errorf("redis: malformed line %q", line)
+	}
+	return line[:len(line)-2], nil
+}
@@ -0,0 +1,82 @@
+package redis
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestClient_Do(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := NewClient(server.Addr(), "")
+	defer client.Close()
+
+	if _, err := client.Do("SET", "key", "value"); err != nil {
+		t.Fatalf("SET failed: %v", err)
+	}
+
+	reply, err := client.Do("GET", "key")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	if reply != "value" {
+		t.Errorf("Expected value, got %v", reply)
+	}
+
+	reply, err = client.Do("GET", "missing")
+	if err != nil {
+		t.Fatalf("GET failed: %v", err)
+	}
+	if reply != nil {
+		t.Errorf("Expected nil for a missing key, got %v", reply)
+	}
+
+	reply, err = client.Do("INCRBY", "counter", "5")
+	if err != nil {
+		t.Fatalf("INCRBY failed: %v", err)
+	}
+	if reply != int64(5) {
+		t.Errorf("Expected 5, got %v", reply)
+	}
+
+	_, err = client.Do("INCR", "key")
+	var replyErr Error
+	if !errors.As(err, &replyErr) {
+		t.Errorf("Expected an error reply, got %v", err)
+	}
+}
+
+func TestClient_Auth(t *testing.T) {
+	server := miniredis.RunT(t)
+	server.RequireAuth("secret")
+
+	if _, err := NewClient(server.Addr(), "wrong").Do("PING"); err == nil {
+		t.Error("Expected AUTH with the wrong password to fail")
+	}
+
+	client := NewClient(server.Addr(), "secret")
+	defer client.Close()
+	if reply, err := client.Do("PING"); err != nil || reply != "PONG" {
+		t.Errorf("Expected PONG, got %v (%v)", reply, err)
+	}
+}
+
+func TestScript_Run(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := NewClient(server.Addr(), "")
+	defer client.Close()
+
+	script := NewScript("return redis.call('INCRBY', KEYS[1], ARGV[1])")
+
+	// The first run loads the script, the second runs it by SHA
+	for want := int64(2); want <= 4; want += 2 {
+		reply, err := script.Run(client, []string{"counter"}, "2")
+		if err != nil {
+			t.Fatalf("Run failed: %v", err)
+		}
+		if reply != want {
+			t.Errorf("Expected %d, got %v", want, reply)
+		}
+	}
+}
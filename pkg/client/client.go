@@ -0,0 +1,427 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// Retry defaults
+const (
+	defaultMaxRetries   = 3
+	defaultBaseBackoff  = 100 * time.Millisecond
+	defaultMaxBackoff   = 5 * time.Second
+	defaultIdleConns    = 100
+	defaultIdleTimeout  = 90 * time.Second
+	defaultDialTimeout  = 5 * time.Second
+	defaultHTTPTimeout  = 30 * time.Second
+	defaultKeepAlive    = 30 * time.Second
+	maxErrorBodyPreview = 512
+)
+
+// ErrNeverAllowed is returned by WaitAndReserve for reservations the
+// service denies without a hint of when they would fit, such as those for
+// unknown API keys or larger than a whole budget
+var ErrNeverAllowed = errors.New("client: reservation can never be allowed")
+
+// Error is returned for responses the service rejected, other than rate
+// limit denials
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+// Error describes the rejected response
+func (e *Error) Error() string {
+	return fmt.Sprintf("client: service returned %d: %s", e.StatusCode, e.Message)
+}
+
+// Request describes a reservation of requests and tokens on an API key's
+// endpoint. A positive MaxWaitMs has the service queue the reservation for
+// up to that long rather than deny it outright.
+type Request struct {
+	ClientID       string `json:"clientID"`
+	Tokens         int    `json:"tokens"`
+	Requests       int    `json:"requests"`
+	APIKey         string `json:"apiKey"`
+	TargetEndpoint string `json:"targetEndpoint"`
+	MaxWaitMs      int    `json:"maxWaitMs,omitempty"`
+}
+
+// BatchResult is the outcome of a batch reservation. Either every
+// reservation in Results is allowed or none is, and RetryAfter is how long
+// until a denied batch might fit.
+type BatchResult struct {
+	Allowed    bool
+	RetryAfter time.Duration
+	Results    []*ratelimit.Reservation
+}
+
+// Client calls the rate limiting service over HTTP. It is safe for
+// concurrent use, and reuses connections across calls.
+type Client struct {
+	baseURL      string
+	httpClient   *http.Client
+	maxRetries   int
+	retryDenials bool
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+}
+
+// New creates a new Client instance for the service at baseURL, such as
+// http://localhost:8086
+func New(baseURL string) *Client {
+	transport := &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   defaultDialTimeout,
+			KeepAlive: defaultKeepAlive,
+		}).DialContext,
+		MaxIdleConns:        defaultIdleConns,
+		MaxIdleConnsPerHost: defaultIdleConns,
+		IdleConnTimeout:     defaultIdleTimeout,
+	}
+
+	return &Client{
+		baseURL:      strings.TrimRight(baseURL, "/"),
+		httpClient:   &http.Client{Transport: transport, Timeout: defaultHTTPTimeout},
+		maxRetries:   defaultMaxRetries,
+		retryDenials: true,
+		baseBackoff:  defaultBaseBackoff,
+		maxBackoff:   defaultMaxBackoff,
+	}
+}
+
+// SetHTTPClient sets the HTTP client calls are made with, replacing the
+// default pooled one
+func (c *Client) SetHTTPClient(httpClient *http.Client) {
+	c.httpClient = httpClient
+}
+
+// SetRetries sets how many times a call is retried, and the longest to
+// back off between attempts when the service gives no Retry-After hint.
+// Zero retries disables them.
+func (c *Client) SetRetries(maxRetries int, maxBackoff time.Duration) {
+	c.maxRetries = maxRetries
+	c.maxBackoff = maxBackoff
+	if c.baseBackoff > maxBackoff {
+		c.baseBackoff = maxBackoff
+	}
+}
+
+// SetRetryDenials sets whether 429 denials with a Retry-After hint are
+// retried. They are by default; with retries off, a denial is returned
+// after the first attempt.
+func (c *Client) SetRetryDenials(enabled bool) {
+	c.retryDenials = enabled
+}
+
+// Reserve asks for capacity. A denial that could fit later is retried
+// after the service's hint, unless ctx would be done first. A denial is
+// not an error: the last one is returned with Allowed false and, if it
+// could fit later, RetryAfterMs set.
+func (c *Client) Reserve(ctx context.Context, request Request) (*ratelimit.Reservation, error) {
+	var reservation ratelimit.Reservation
+	if _, err := c.do(ctx, http.MethodPost, "/reserve", request, &reservation, http.StatusTooManyRequests); err != nil {
+		return nil, err
+	}
+	return &reservation, nil
+}
+
+// WaitAndReserve asks for capacity until it is allowed or ctx is done,
+// waiting between attempts as long as the service says a denial lasts.
+// It returns ErrNeverAllowed if the service gives no such hint.
+func (c *Client) WaitAndReserve(ctx context.Context, request Request) (*ratelimit.Reservation, error) {
+	for {
+		reservation, err := c.Reserve(ctx, request)
+		if err != nil {
+			return nil, err
+		}
+		if reservation.Allowed {
+			return reservation, nil
+		}
+		if reservation.RetryAfterMs <= 0 {
+			return nil, ErrNeverAllowed
+		}
+		if err := sleep(ctx, time.Duration(reservation.RetryAfterMs)*time.Millisecond); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// ReserveBatch asks for capacity for every request at once; either all of
+// them are allowed or none is
+func (c *Client) ReserveBatch(ctx context.Context, requests []Request) (*BatchResult, error) {
+	body := struct {
+		Requests []Request `json:"requests"`
+	}{Requests: requests}
+	var data struct {
+		Allowed      bool                     `json:"allowed"`
+		RetryAfterMs int64                    `json:"retryAfterMs"`
+		Results      []*ratelimit.Reservation `json:"results"`
+	}
+	if _, err := c.do(ctx, http.MethodPost, "/reserve/batch", body, &data, http.StatusTooManyRequests); err != nil {
+		return nil, err
+	}
+
+	return &BatchResult{
+		Allowed:    data.Allowed,
+		RetryAfter: time.Duration(data.RetryAfterMs) * time.Millisecond,
+		Results:    data.Results,
+	}, nil
+}
+
+// Quota returns the limits and usage of an API key's endpoint, or of the
+// whole key if endpoint is empty, including clientID's quota if it is set.
+// It returns ratelimit.ErrUnknownKey if the key or endpoint is unknown.
+func (c *Client) Quota(ctx context.Context, apiKey, endpoint, clientID string) ([]ratelimit.Quota, error) {
+	path := "/quota/" + url.PathEscape(apiKey)
+	if endpoint != "" {
+		segments := strings.Split(strings.TrimPrefix(endpoint, "/"), "/")
+		for i, segment := range segments {
+			segments[i] = url.PathEscape(segment)
+		}
+		path += "/" + strings.Join(segments, "/")
+	} else {
+		path += "/"
+	}
+	if clientID != "" {
+		path += "?clientID=" + url.QueryEscape(clientID)
+	}
+
+	var data struct {
+		Quotas []ratelimit.Quota `json:"quotas"`
+	}
+	status, err := c.do(ctx, http.MethodGet, path, nil, &data, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, ratelimit.ErrUnknownKey
+	}
+	return data.Quotas, nil
+}
+
+// Commit confirms a pending reservation with the tokens actually used. It
+// returns ratelimit.ErrReservationNotFound if the reservation was already
+// settled or has expired.
+func (c *Client) Commit(ctx context.Context, reservationID string, tokens int) (*ratelimit.Settlement, error) {
+	body := struct {
+		Tokens int `json:"tokens"`
+	}{Tokens: tokens}
+	return c.settle(ctx, http.MethodPost, "/reservations/"+url.PathEscape(reservationID)+"/commit", body, ratelimit.ErrReservationNotFound)
+}
+
+// Cancel releases a pending reservation. It returns
+// ratelimit.ErrReservationNotFound if the reservation was already settled
+// or has expired.
+func (c *Client) Cancel(ctx context.Context, reservationID string) (*ratelimit.Settlement, error) {
+	return c.settle(ctx, http.MethodDelete, "/reservations/"+url.PathEscape(reservationID), nil, ratelimit.ErrReservationNotFound)
+}
+
+// settle commits or cancels a pending reservation, or returns a lease,
+// mapping a 404 to notFound
+func (c *Client) settle(ctx context.Context, method, path string, body interface{}, notFound error) (*ratelimit.Settlement, error) {
+	var settlement ratelimit.Settlement
+	status, err := c.do(ctx, method, path, body, &settlement, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, notFound
+	}
+	return &settlement, nil
+}
+
+// envelope is the shape of every response from the service
+type envelope struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Data  json.RawMessage `json:"data"`
+	Error string          `json:"error"`
+}
+
+// do sends a request, retrying while the service could not act on it or
+// denied it for now, and decodes the data of a successful response into
+// out. Statuses in accept are returned rather than treated as errors, with
+// their data decoded too.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}, accept ...int) (int, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return 0, err
+		}
+	}
+
+	for attempt := 0; ; attempt++ {
+		status, wait, err := c.attempt(ctx, method, path, payload, out, accept)
+		if wait < 0 || attempt >= c.maxRetries {
+			return status, err
+		}
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		}
+		// A denial is worth more than a deadline error when the hint
+		// outlasts the context
+		if deadline, ok := ctx.Deadline(); ok && err == nil && time.Until(deadline) < wait {
+			return status, nil
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// attempt sends a request once. It returns how long to wait before trying
+// again, zero to back off by default, or a negative duration if the result
+// is final. Connection failures and 503s are retried with their error, and
+// 429s with a Retry-After hint with their data decoded into out; nothing
+// else is, since the service may already have acted on the request.
+func (c *Client) attempt(ctx context.Context, method, path string, payload []byte, out interface{}, accept []int) (int, time.Duration, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, body)
+	if err != nil {
+		return 0, -1, err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		// Only requests that never reached the service are safe to send
+		// again, since reservations are not idempotent
+		if ctx.Err() == nil && isDialError(err) {
+			return 0, 0, err
+		}
+		return 0, -1, err
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return 0, -1, err
+	}
+
+	if resp.StatusCode == http.StatusServiceUnavailable {
+		return resp.StatusCode, retryAfter(resp.Header), responseError(resp.StatusCode, data)
+	}
+
+	accepted := resp.StatusCode >= 200 && resp.StatusCode < 300
+	for _, status := range accept {
+		accepted = accepted || resp.StatusCode == status
+	}
+	if !accepted {
+		return resp.StatusCode, -1, responseError(resp.StatusCode, data)
+	}
+
+	var response envelope
+	if err := json.Unmarshal(data, &response); err != nil {
+		return resp.StatusCode, -1, fmt.Errorf("client: decoding response: %v", err)
+	}
+	if out != nil && len(response.Data) > 0 && resp.StatusCode != http.StatusNotFound {
+		// Clear what an earlier attempt decoded, since fields left out of
+		// this response would otherwise survive
+		value := reflect.ValueOf(out).Elem()
+		value.Set(reflect.Zero(value.Type()))
+		if err := json.Unmarshal(response.Data, out); err != nil {
+			return resp.StatusCode, -1, fmt.Errorf("client: decoding response: %v", err)
+		}
+	}
+	if resp.StatusCode == http.StatusTooManyRequests && c.retryDenials {
+		if wait := retryAfter(resp.Header); wait > 0 {
+			return resp.StatusCode, wait, nil
+		}
+	}
+	return resp.StatusCode, -1, nil
+}
+
+// backoff returns how long to wait before a retry without a Retry-After
+// hint: exponential in attempt, capped, with jitter so clients retrying
+// together spread out
+func (c *Client) backoff(attempt int) time.Duration {
+	wait := c.maxBackoff
+	if attempt < 32 && c.baseBackoff<<uint(attempt) < c.maxBackoff {
+		wait = c.baseBackoff << uint(attempt)
+	}
+	if wait <= 0 {
+		return 0
+	}
+	return wait/2 + time.Duration(rand.Int63n(int64(wait/2)+1))
+}
+
+// responseError builds the error for a rejected response, using the
+// service's error message if the body has one
+func responseError(status int, data []byte) error {
+	var response envelope
+	if err := json.Unmarshal(data, &response); err == nil && response.Error != "" {
+		return &Error{StatusCode: status, Message: response.Error}
+	}
+
+	message := strings.TrimSpace(string(data))
+	if len(message) > maxErrorBodyPreview {
+		message = message[:maxErrorBodyPreview]
+	}
+	if message == "" {
+		message = http.StatusText(status)
+	}
+	return &Error{StatusCode: status, Message: message}
+}
+
+// retryAfter parses a Retry-After header given in seconds or as a date,
+// returning zero if there is none
+func retryAfter(header http.Header) time.Duration {
+	value := header.Get("Retry-After")
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if at, err := http.ParseTime(value); err == nil {
+		if wait := time.Until(at); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// isDialError reports whether err happened while connecting, before the
+// request was sent
+func isDialError(err error) bool {
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// sleep waits for d, or returns ctx's error if ctx is done first
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,344 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/internal/handlers"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// newTestService serves the reservation routes for limiter behind
+// middleware, returning the base URL of the service
+func newTestService(t *testing.T, limiter *ratelimit.Limiter, middleware ...fiber.Handler) string {
+	app := fiber.New(fiber.Config{DisableStartupMessage: true})
+	for _, handler := range middleware {
+		app.Use(handler)
+	}
+	app.Post("/reserve", handlers.NewReserveHandler(limiter).Handle)
+	app.Post("/reserve/batch", handlers.NewBatchHandler(limiter).Handle)
+	reservationHandler := handlers.NewReservationHandler(limiter)
+	app.Post("/reservations/:id/commit", reservationHandler.Commit)
+	app.Delete("/reservations/:id", reservationHandler.Cancel)
+	app.Get("/quota/:apiKey/*", handlers.NewQuotaHandler(limiter).Handle)
+	leaseHandler := handlers.NewLeaseHandler(limiter)
+	app.Post("/leases", leaseHandler.Lease)
+	app.Post("/leases/:id/return", leaseHandler.Return)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	go app.Listener(listener)
+	t.Cleanup(func() { app.Shutdown() })
+
+	return "http://" + listener.Addr().String()
+}
+
+func newTestLimiter() *ratelimit.Limiter {
+	return ratelimit.MustNew(ratelimit.Config{RateLimits: []ratelimit.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []ratelimit.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 2, TPM: 100},
+			},
+		},
+	}})
+}
+
+func TestClient_Reserve(t *testing.T) {
+	client := New(newTestService(t, newTestLimiter()))
+	client.SetRetryDenials(false)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		request     Request
+		wantAllowed bool
+		wantErr     bool
+	}{
+		{
+			name:        "Allowed",
+			request:     Request{ClientID: "client1", Tokens: 10, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"},
+			wantAllowed: true,
+		},
+		{
+			name:    "Invalid request",
+			request: Request{ClientID: "client1", Tokens: 10, Requests: 1, TargetEndpoint: "/api/endpoint1"},
+			wantErr: true,
+		},
+		{
+			name:        "Exceeds token budget",
+			request:     Request{ClientID: "client1", Tokens: 91, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"},
+			wantAllowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservation, err := client.Reserve(ctx, tt.request)
+			if tt.wantErr {
+				var clientErr *Error
+				if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusBadRequest {
+					t.Fatalf("Expected a 400 error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if reservation.Allowed != tt.wantAllowed {
+				t.Errorf("Expected allowed %v, got %v", tt.wantAllowed, reservation.Allowed)
+			}
+		})
+	}
+}
+
+func TestClient_ReserveBatch(t *testing.T) {
+	client := New(newTestService(t, newTestLimiter()))
+	client.SetRetryDenials(false)
+	ctx := context.Background()
+
+	request := Request{ClientID: "client1", Tokens: 10, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"}
+	result, err := client.ReserveBatch(ctx, []Request{request, request})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !result.Allowed || len(result.Results) != 2 {
+		t.Fatalf("Expected both reservations to be allowed, got %+v", result)
+	}
+
+	result, err = client.ReserveBatch(ctx, []Request{request})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if result.Allowed {
+		t.Error("Expected the batch to be denied once the request budget is spent")
+	}
+	if result.RetryAfter <= 0 {
+		t.Error("Expected a denied batch to say when to retry")
+	}
+}
+
+func TestClient_Quota(t *testing.T) {
+	client := New(newTestService(t, newTestLimiter()))
+	ctx := context.Background()
+
+	if _, err := client.Reserve(ctx, Request{ClientID: "client1", Tokens: 10, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	quotas, err := client.Quota(ctx, "API_KEY_1", "/api/endpoint1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(quotas) == 0 || quotas[0].Tokens == nil || quotas[0].Tokens.Used != 10 {
+		t.Errorf("Expected 10 tokens used, got %+v", quotas)
+	}
+
+	if _, err := client.Quota(ctx, "INVALID_KEY", "", ""); err != ratelimit.ErrUnknownKey {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestClient_CommitAndCancel(t *testing.T) {
+	limiter := newTestLimiter()
+	limiter.SetReservationTTL(time.Minute)
+	client := New(newTestService(t, limiter))
+	ctx := context.Background()
+
+	request := Request{ClientID: "client1", Tokens: 60, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"}
+	committed, err := client.Reserve(ctx, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	settlement, err := client.Commit(ctx, committed.ReservationID, 15)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if settlement.RefundedTokens != 45 {
+		t.Errorf("Expected 45 refunded tokens, got %d", settlement.RefundedTokens)
+	}
+	if _, err := client.Commit(ctx, committed.ReservationID, 15); err != ratelimit.ErrReservationNotFound {
+		t.Errorf("Expected ErrReservationNotFound, got %v", err)
+	}
+
+	cancelled, err := client.Reserve(ctx, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	settlement, err = client.Cancel(ctx, cancelled.ReservationID)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if settlement.RefundedTokens != 60 {
+		t.Errorf("Expected 60 refunded tokens, got %d", settlement.RefundedTokens)
+	}
+}
+
+func TestClient_Retries(t *testing.T) {
+	tests := []struct {
+		name         string
+		failures     int32
+		retryAfter   string
+		maxRetries   int
+		wantAttempts int32
+		wantErr      bool
+	}{
+		{name: "Succeeds after unavailable", failures: 2, maxRetries: 3, wantAttempts: 3},
+		{name: "Honors Retry-After", failures: 1, retryAfter: "1", maxRetries: 3, wantAttempts: 2},
+		{name: "Gives up after max retries", failures: 5, maxRetries: 2, wantAttempts: 3, wantErr: true},
+		{name: "Retries disabled", failures: 1, maxRetries: 0, wantAttempts: 1, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var attempts int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if atomic.AddInt32(&attempts, 1) <= tt.failures {
+					if tt.retryAfter != "" {
+						w.Header().Set("Retry-After", tt.retryAfter)
+					}
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Header().Set("Content-Type", "application/json")
+				w.Write([]byte(`{"status":{"code":200,"message":"Success"},"data":{"allowed":true}}`))
+			}))
+			defer server.Close()
+
+			client := New(server.URL)
+			client.SetRetries(tt.maxRetries, 10*time.Millisecond)
+
+			start := time.Now()
+			reservation, err := client.Reserve(context.Background(), Request{})
+			if tt.wantErr {
+				var clientErr *Error
+				if !errors.As(err, &clientErr) || clientErr.StatusCode != http.StatusServiceUnavailable {
+					t.Errorf("Expected a 503 error, got %v", err)
+				}
+			} else if err != nil || !reservation.Allowed {
+				t.Errorf("Expected an allowed reservation, got %+v, %v", reservation, err)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, got)
+			}
+			if tt.retryAfter != "" && time.Since(start) < time.Second {
+				t.Error("Expected the retry to wait for Retry-After")
+			}
+		})
+	}
+}
+
+func TestClient_RetriesDenials(t *testing.T) {
+	tests := []struct {
+		name         string
+		requests     int
+		retryDenials bool
+		timeout      time.Duration
+		wantAllowed  bool
+		wantAttempts int32
+		wantStatus   int
+	}{
+		{name: "Retries a denial after Retry-After", requests: 1, retryDenials: true, timeout: 5 * time.Second, wantAllowed: true, wantAttempts: 2},
+		{name: "Denial retries turned off", requests: 1, timeout: 5 * time.Second, wantAttempts: 1},
+		{name: "Deadline before Retry-After", requests: 1, retryDenials: true, timeout: 200 * time.Millisecond, wantAttempts: 1},
+		{name: "Denial that can never fit", requests: 61, retryDenials: true, timeout: 5 * time.Second, wantAttempts: 1},
+		{name: "Rejected request", requests: -1, retryDenials: true, timeout: 5 * time.Second, wantAttempts: 1, wantStatus: http.StatusBadRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// A full bucket of 60 requests refills one a second
+			limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []ratelimit.RateLimit{
+				{
+					APIKey: "API_KEY_1",
+					Endpoints: []ratelimit.EndpointConfig{
+						{Path: "/api/endpoint1", RPM: 60, TPM: 1000, Algorithm: "token_bucket"},
+					},
+				},
+			}})
+			var attempts int32
+			client := New(newTestService(t, limiter, func(c *fiber.Ctx) error {
+				atomic.AddInt32(&attempts, 1)
+				return c.Next()
+			}))
+			client.SetRetryDenials(tt.retryDenials)
+
+			request := Request{ClientID: "client1", Tokens: 1, Requests: 60, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"}
+			if _, err := client.Reserve(context.Background(), request); err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			atomic.StoreInt32(&attempts, 0)
+
+			ctx, cancel := context.WithTimeout(context.Background(), tt.timeout)
+			defer cancel()
+			request.Requests = tt.requests
+			reservation, err := client.Reserve(ctx, request)
+			if tt.wantStatus != 0 {
+				var clientErr *Error
+				if !errors.As(err, &clientErr) || clientErr.StatusCode != tt.wantStatus {
+					t.Errorf("Expected a %d error, got %v", tt.wantStatus, err)
+				}
+			} else if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			} else if reservation.Allowed != tt.wantAllowed {
+				t.Errorf("Expected allowed %v, got %+v", tt.wantAllowed, reservation)
+			} else if reservation.Allowed && reservation.RetryAfterMs != 0 {
+				t.Errorf("Expected the allowed reservation to drop the earlier retry hint, got %d", reservation.RetryAfterMs)
+			}
+			if got := atomic.LoadInt32(&attempts); got != tt.wantAttempts {
+				t.Errorf("Expected %d attempts, got %d", tt.wantAttempts, got)
+			}
+		})
+	}
+}
+
+func TestClient_RetryDeadline(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "60")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	if _, err := New(server.URL).Reserve(ctx, Request{}); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to cut the back-off short, got %v", err)
+	}
+}
+
+func TestClient_WaitAndReserve(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []ratelimit.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []ratelimit.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 1, TPM: 100},
+			},
+		},
+	}})
+	client := New(newTestService(t, limiter))
+	request := Request{ClientID: "client1", Tokens: 1, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1"}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if _, err := client.WaitAndReserve(ctx, request); err != nil {
+		t.Fatalf("Expected the first reservation to be allowed, got %v", err)
+	}
+	if _, err := client.WaitAndReserve(ctx, request); err != context.DeadlineExceeded {
+		t.Errorf("Expected the deadline to pass while waiting, got %v", err)
+	}
+
+	request.APIKey = "INVALID_KEY"
+	if _, err := client.WaitAndReserve(context.Background(), request); err != ErrNeverAllowed {
+		t.Errorf("Expected ErrNeverAllowed, got %v", err)
+	}
+}
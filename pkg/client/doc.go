@@ -0,0 +1,13 @@
+// Package client calls the rate limiting service over HTTP, for services
+// that share one limiter rather than embedding package ratelimit.
+//
+// A Client reserves capacity, settles pending reservations and inspects
+// quotas, returning the same types package ratelimit does. Three kinds of
+// failure are retried, up to the limit SetRetries sets: calls that never
+// reached the service, 503 responses such as those from a proxy in front of
+// it, and 429 denials with a Retry-After hint, unless SetRetryDenials turns
+// those off. Waits follow Retry-After, or back off exponentially without
+// it. Other responses, including 500s, are final since the service may
+// already have acted on them. WaitAndReserve goes further and retries
+// denials until the reservation is allowed or its context is done.
+package client
@@ -0,0 +1,171 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// LeaseRequest describes a lease of requests and tokens on an API key's
+// endpoint, held for TTL
+type LeaseRequest struct {
+	ClientID       string
+	Tokens         int
+	Requests       int
+	APIKey         string
+	TargetEndpoint string
+	TTL            time.Duration
+}
+
+// Lease asks for a slice of an endpoint's budget to spend locally. A
+// denial is not an error: the lease is returned with Granted false and, if
+// it could fit later, RetryAfterMs set. It returns ratelimit.ErrUnknownKey
+// if the key or endpoint is unknown.
+func (c *Client) Lease(ctx context.Context, request LeaseRequest) (*ratelimit.Lease, error) {
+	body := struct {
+		ClientID       string `json:"clientID"`
+		Tokens         int    `json:"tokens"`
+		Requests       int    `json:"requests"`
+		APIKey         string `json:"apiKey"`
+		TargetEndpoint string `json:"targetEndpoint"`
+		TTLMs          int64  `json:"ttlMs"`
+	}{
+		ClientID:       request.ClientID,
+		Tokens:         request.Tokens,
+		Requests:       request.Requests,
+		APIKey:         request.APIKey,
+		TargetEndpoint: request.TargetEndpoint,
+		TTLMs:          request.TTL.Milliseconds(),
+	}
+
+	var lease ratelimit.Lease
+	status, err := c.do(ctx, http.MethodPost, "/leases", body, &lease, http.StatusTooManyRequests, http.StatusNotFound)
+	if err != nil {
+		return nil, err
+	}
+	if status == http.StatusNotFound {
+		return nil, ratelimit.ErrUnknownKey
+	}
+	return &lease, nil
+}
+
+// ReturnLease ends a lease early with the requests and tokens actually
+// used, refunding the rest. It returns ratelimit.ErrLeaseNotFound if the
+// lease was already returned or has expired.
+func (c *Client) ReturnLease(ctx context.Context, leaseID string, usedTokens, usedRequests int) (*ratelimit.Settlement, error) {
+	body := struct {
+		Tokens   int `json:"tokens"`
+		Requests int `json:"requests"`
+	}{Tokens: usedTokens, Requests: usedRequests}
+	return c.settle(ctx, http.MethodPost, "/leases/"+url.PathEscape(leaseID)+"/return", body, ratelimit.ErrLeaseNotFound)
+}
+
+// LeasedLimiter spends leases locally, so only taking and returning
+// leases calls the service. Each lease is the size of its LeaseRequest; a
+// new one is taken when the current one runs out or expires, returning
+// what was left of it. It is safe for concurrent use.
+type LeasedLimiter struct {
+	client  *Client
+	request LeaseRequest
+	lease   *ratelimit.Lease
+	// expiresAt is measured on the local clock from before the lease was
+	// requested, so it never outlasts the lease on the service
+	expiresAt    time.Time
+	usedTokens   int
+	usedRequests int
+	// retryAt holds off asking for leases after a denial with a hint
+	retryAt time.Time
+	mutex   sync.Mutex
+}
+
+// NewLeasedLimiter creates a new LeasedLimiter instance taking leases of
+// request's size through a copy of client's settings. The copy does not
+// retry denials, since Allow holds off after them on its own rather than
+// blocking every caller.
+func NewLeasedLimiter(client *Client, request LeaseRequest) *LeasedLimiter {
+	if request.TTL > ratelimit.MaxLeaseTTL {
+		request.TTL = ratelimit.MaxLeaseTTL
+	}
+	leaser := *client
+	leaser.retryDenials = false
+	return &LeasedLimiter{
+		client:  &leaser,
+		request: request,
+	}
+}
+
+// Allow reports whether requests and tokens are allowed, spending them
+// from the current lease. It only calls the service when the lease cannot
+// cover them, and not at all while a denied lease's retry hint lasts.
+func (l *LeasedLimiter) Allow(ctx context.Context, tokens, requests int) (bool, error) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if l.spend(time.Now(), tokens, requests) {
+		return true, nil
+	}
+	if time.Now().Before(l.retryAt) {
+		return false, nil
+	}
+
+	if err := l.release(ctx); err != nil {
+		return false, err
+	}
+	start := time.Now()
+	lease, err := l.client.Lease(ctx, l.request)
+	if err != nil {
+		return false, err
+	}
+	if !lease.Granted {
+		l.retryAt = start.Add(time.Duration(lease.RetryAfterMs) * time.Millisecond)
+		return false, nil
+	}
+
+	l.lease = lease
+	l.expiresAt = start.Add(l.request.TTL)
+	l.usedTokens, l.usedRequests = 0, 0
+	return l.spend(time.Now(), tokens, requests), nil
+}
+
+// Close returns the current lease with what was used of it, so its unused
+// capacity goes back to the budget
+func (l *LeasedLimiter) Close(ctx context.Context) error {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	return l.release(ctx)
+}
+
+// spend takes requests and tokens from the current lease if it is still
+// valid and has room for them
+func (l *LeasedLimiter) spend(now time.Time, tokens, requests int) bool {
+	if l.lease == nil || !now.Before(l.expiresAt) {
+		return false
+	}
+	if l.usedTokens+tokens > l.lease.Tokens || l.usedRequests+requests > l.lease.Requests {
+		return false
+	}
+	l.usedTokens += tokens
+	l.usedRequests += requests
+	return true
+}
+
+// release returns the current lease, unless it has already expired
+func (l *LeasedLimiter) release(ctx context.Context) error {
+	lease := l.lease
+	l.lease = nil
+	if lease == nil || !time.Now().Before(l.expiresAt) {
+		return nil
+	}
+
+	_, err := l.client.ReturnLease(ctx, lease.LeaseID, l.usedTokens, l.usedRequests)
+	if errors.Is(err, ratelimit.ErrLeaseNotFound) {
+		return nil
+	}
+	return err
+}
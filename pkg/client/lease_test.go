@@ -0,0 +1,98 @@
+package client
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func TestClient_Lease(t *testing.T) {
+	client := New(newTestService(t, newTestLimiter()))
+	client.SetRetryDenials(false)
+	ctx := context.Background()
+
+	request := LeaseRequest{ClientID: "client1", Tokens: 60, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/api/endpoint1", TTL: 5 * time.Second}
+	lease, err := client.Lease(ctx, request)
+	if err != nil || !lease.Granted {
+		t.Fatalf("Expected the lease to be granted, got %+v, %v", lease, err)
+	}
+
+	denied, err := client.Lease(ctx, request)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if denied.Granted || denied.RetryAfterMs <= 0 {
+		t.Errorf("Expected a denial with a retry hint, got %+v", denied)
+	}
+
+	settlement, err := client.ReturnLease(ctx, lease.LeaseID, 20, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if settlement.RefundedTokens != 40 {
+		t.Errorf("Expected 40 refunded tokens, got %d", settlement.RefundedTokens)
+	}
+	if _, err := client.ReturnLease(ctx, lease.LeaseID, 20, 1); err != ratelimit.ErrLeaseNotFound {
+		t.Errorf("Expected ErrLeaseNotFound, got %v", err)
+	}
+
+	request.APIKey = "INVALID_KEY"
+	if _, err := client.Lease(ctx, request); err != ratelimit.ErrUnknownKey {
+		t.Errorf("Expected ErrUnknownKey, got %v", err)
+	}
+}
+
+func TestLeasedLimiter(t *testing.T) {
+	limiter := ratelimit.MustNew(ratelimit.Config{RateLimits: []ratelimit.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []ratelimit.EndpointConfig{
+				{Path: "/api/endpoint1", RPM: 10, TPM: 1000},
+			},
+		},
+	}})
+	leased := NewLeasedLimiter(New(newTestService(t, limiter)), LeaseRequest{
+		ClientID:       "client1",
+		Tokens:         500,
+		Requests:       5,
+		APIKey:         "API_KEY_1",
+		TargetEndpoint: "/api/endpoint1",
+		TTL:            5 * time.Second,
+	})
+	ctx := context.Background()
+
+	// Two leases of 5 requests cover the whole limit, and are spent locally
+	allowed := 0
+	for i := 0; i < 12; i++ {
+		ok, err := leased.Allow(ctx, 10, 1)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if ok {
+			allowed++
+		}
+	}
+	if allowed != 10 {
+		t.Errorf("Expected 10 requests allowed, got %d", allowed)
+	}
+
+	// The last lease was fully used, so nothing is left to refund
+	quotas, err := limiter.Quota("API_KEY_1", "/api/endpoint1", "")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if quotas[0].Requests.Remaining != 0 {
+		t.Errorf("Expected the request budget to be spent, got %+v", quotas[0].Requests)
+	}
+	if err := leased.Close(ctx); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	// Tokens left on the closed lease go back to the budget
+	quotas, _ = limiter.Quota("API_KEY_1", "/api/endpoint1", "")
+	if quotas[0].Tokens.Remaining != 900 {
+		t.Errorf("Expected 900 tokens left after returning the lease, got %+v", quotas[0].Tokens)
+	}
+}
@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+	"time"
+)
+
+// Algorithm enforces a single limit (such as RPM or TPM) over a window
+type Algorithm interface {
+	// Remaining returns how many units can still be taken at now
+	Remaining(now time.Time) int
+	// Take consumes n units at now. Callers check Remaining first, except
+	// when charging usage after the fact, which may overdraw the limit.
+	Take(now time.Time, n int)
+	// Refund returns n units taken at takenAt. Units whose window has
+	// already passed are not refunded.
+	Refund(takenAt, now time.Time, n int)
+	// RetryAfter returns how long from now until n units can be taken, or
+	// a negative duration if n exceeds the limit and never can be
+	RetryAfter(now time.Time, n int) time.Duration
+}
+
+// newAlgorithm builds the named algorithm for limit units per window
+func newAlgorithm(name string, limit int, window time.Duration) Algorithm {
+	switch name {
+	case AlgorithmTokenBucket:
+		return newTokenBucket(limit, window)
+	case AlgorithmSlidingWindowLog:
+		return newSlidingWindowLog(limit, window)
+	case AlgorithmSlidingWindowCounter:
+		return newSlidingWindowCounter(limit, window)
+	case AlgorithmGCRA:
+		return newGCRA(limit, window)
+	default:
+		return newFixedWindow(limit, window)
+	}
+}
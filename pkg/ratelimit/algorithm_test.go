@@ -0,0 +1,146 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+var algorithms = []string{
+	AlgorithmFixedWindow,
+	AlgorithmTokenBucket,
+	AlgorithmSlidingWindowLog,
+	AlgorithmSlidingWindowCounter,
+	AlgorithmGCRA,
+}
+
+func TestAlgorithm_EnforcesLimit(t *testing.T) {
+	for _, name := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			algorithm := newAlgorithm(name, 10, time.Minute)
+
+			if got := algorithm.Remaining(start); got != 10 {
+				t.Fatalf("Expected 10 remaining on a fresh limit, got %d", got)
+			}
+
+			algorithm.Take(start, 10)
+			if got := algorithm.Remaining(start); got != 0 {
+				t.Errorf("Expected 0 remaining after taking the full limit, got %d", got)
+			}
+
+			// Capacity is fully restored once a quiet window has passed
+			later := start.Add(2 * time.Minute)
+			if got := algorithm.Remaining(later); got != 10 {
+				t.Errorf("Expected 10 remaining after two idle windows, got %d", got)
+			}
+		})
+	}
+}
+
+func TestAlgorithm_BoundaryBurst(t *testing.T) {
+	// A fixed window allows the full limit just before and just after a
+	// boundary; the other algorithms must not
+	tests := []struct {
+		name      string
+		wantAfter int
+	}{
+		{name: AlgorithmFixedWindow, wantAfter: 10},
+		{name: AlgorithmTokenBucket, wantAfter: 1},
+		{name: AlgorithmSlidingWindowLog, wantAfter: 0},
+		{name: AlgorithmSlidingWindowCounter, wantAfter: 0},
+		{name: AlgorithmGCRA, wantAfter: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			start := time.Now()
+			algorithm := newAlgorithm(tt.name, 10, time.Minute)
+
+			// Spend the whole budget at the end of the first window
+			algorithm.Remaining(start)
+			end := start.Add(59 * time.Second)
+			algorithm.Take(end, 10)
+
+			// Six seconds later the next fixed window has started
+			after := end.Add(6 * time.Second)
+			if got := algorithm.Remaining(after); got != tt.wantAfter {
+				t.Errorf("Expected %d remaining across the boundary, got %d", tt.wantAfter, got)
+			}
+		})
+	}
+}
+
+func TestAlgorithm_ResetsUnderContinuousTraffic(t *testing.T) {
+	for _, name := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+			algorithm := newAlgorithm(name, 60, time.Minute)
+
+			// Half the limit, spread evenly over three minutes, never hits it
+			for i := 0; i < 90; i++ {
+				if algorithm.Remaining(now) < 1 {
+					t.Fatalf("Request %d denied under a sustainable rate", i+1)
+				}
+				algorithm.Take(now, 1)
+				now = now.Add(2 * time.Second)
+			}
+		})
+	}
+}
+
+func TestAlgorithm_Refund(t *testing.T) {
+	for _, name := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			algorithm := newAlgorithm(name, 10, time.Minute)
+
+			algorithm.Take(start, 8)
+			algorithm.Refund(start, start, 5)
+			if got := algorithm.Remaining(start); got != 7 {
+				t.Errorf("Expected 7 remaining after refunding 5 of 8, got %d", got)
+			}
+
+			// Refunds never raise capacity above the limit
+			algorithm.Refund(start, start, 10)
+			if got := algorithm.Remaining(start); got != 10 {
+				t.Errorf("Expected 10 remaining after an oversized refund, got %d", got)
+			}
+		})
+	}
+}
+
+func TestAlgorithm_RetryAfter(t *testing.T) {
+	for _, name := range algorithms {
+		t.Run(name, func(t *testing.T) {
+			start := time.Now()
+			algorithm := newAlgorithm(name, 10, time.Minute)
+
+			if got := algorithm.RetryAfter(start, 10); got != 0 {
+				t.Errorf("Expected no wait on a fresh limit, got %v", got)
+			}
+			if got := algorithm.RetryAfter(start, 11); got >= 0 {
+				t.Errorf("Expected a negative wait for more than the limit, got %v", got)
+			}
+
+			for _, n := range []int{1, 5, 10} {
+				algorithm := newAlgorithm(name, 10, time.Minute)
+				algorithm.Take(start, 4)
+				now := start.Add(10 * time.Second)
+				algorithm.Take(now, 6)
+
+				wait := algorithm.RetryAfter(now, n)
+				if n == 10 && wait <= 0 {
+					t.Fatalf("Expected a positive wait for the full limit, got %v", wait)
+				}
+				if wait > 0 {
+					if got := algorithm.Remaining(now.Add(wait - time.Millisecond)); got >= n {
+						t.Errorf("Expected fewer than %d units just before %v, got %d", n, wait, got)
+					}
+				}
+				if got := algorithm.Remaining(now.Add(wait)); got < n {
+					t.Errorf("Expected %d units after waiting %v, got %d", n, wait, got)
+				}
+			}
+		})
+	}
+}
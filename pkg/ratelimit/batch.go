@@ -0,0 +1,287 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+// MaxBatchSize is the most reservations a batch may hold
+const MaxBatchSize = 100
+
+// ErrEmptyBatch is returned when a batch holds no reservations
+var ErrEmptyBatch = errors.New("ratelimit: empty batch")
+
+// ErrBatchTooLarge is returned when a batch holds more than MaxBatchSize
+// reservations
+var ErrBatchTooLarge = fmt.Errorf("ratelimit: batch holds more than %d reservations", MaxBatchSize)
+
+// BatchRequest is one reservation in a batch
+type BatchRequest struct {
+	ClientID       string
+	Tokens         int
+	Requests       int
+	APIKey         string
+	TargetEndpoint string
+}
+
+// batchOutcome is the result of taking a batch from the union of its
+// requests' limits
+type batchOutcome struct {
+	allowed bool
+	takenAt time.Time
+	// remaining holds the budget left at each limit of the union
+	remaining []budget
+	// requestLimits and resets describe each request's tightest request
+	// budget, as for a single reservation
+	requestLimits []int
+	resets        []time.Duration
+	// retryAfter is how long until the whole batch would fit, or negative
+	// if it never will
+	retryAfter time.Duration
+}
+
+// ReserveBatch reserves every request in the batch or none of them.
+// Limits shared by several requests, such as the global cap or an API
+// key's aggregate cap, must fit their combined demand. Reservations are
+// returned in request order.
+func (rl *Limiter) ReserveBatch(requests []BatchRequest) ([]*Reservation, error) {
+	return rl.HoldBatch(requests, 0)
+}
+
+// HoldBatch reserves a batch like ReserveBatch, but keeps an allowed batch
+// pending for at least hold even if reservations have no TTL, so that a
+// caller reserving a larger batch in parts can cancel this part if another
+// is denied. The caller settles each reservation by its ID.
+func (rl *Limiter) HoldBatch(requests []BatchRequest, hold time.Duration) ([]*Reservation, error) {
+	if len(requests) == 0 {
+		return nil, ErrEmptyBatch
+	}
+	if len(requests) > MaxBatchSize {
+		return nil, ErrBatchTooLarge
+	}
+
+	reservations, reasons := rl.reserveBatch(requests, hold)
+	for i, request := range requests {
+		rl.record(reservations[i], reasons[i], request.APIKey, request.TargetEndpoint)
+	}
+	return reservations, nil
+}
+
+// reserveBatch attempts a batch without recording metrics, returning why
+// each reservation was denied if they were. Allowed reservations are kept
+// pending for at least hold.
+func (rl *Limiter) reserveBatch(requests []BatchRequest, hold time.Duration) ([]*Reservation, []string) {
+	reservations := make([]*Reservation, len(requests))
+	reasons := make([]string, len(requests))
+
+	// Every chain comes from the same tree, so a key shared between
+	// chains always maps to the same limit
+	chains := make([][]Limit, len(requests))
+	known := true
+	rl.mutex.RLock()
+	for i, request := range requests {
+		limits, exists := rl.limits.chain(request.APIKey, request.TargetEndpoint, request.ClientID)
+		if !exists {
+			reasons[i] = denyUnknownKey
+			known = false
+		}
+		chains[i] = limits
+	}
+	rl.mutex.RUnlock()
+
+	deny := func(reason string) ([]*Reservation, []string) {
+		for i := range requests {
+			reservations[i] = &Reservation{Allowed: false, UnknownKey: reasons[i] == denyUnknownKey}
+			if reasons[i] == "" {
+				reasons[i] = reason
+			}
+		}
+		return reservations, reasons
+	}
+	if !known {
+		return deny(denyBatch)
+	}
+
+	// A request larger than a limit on its chain can never fit, whatever
+	// the rest of the batch holds; denying it here also keeps the sums
+	// below far from overflowing
+	for i, limits := range chains {
+		if reason := exceededLimit(limits, requests[i].Tokens, requests[i].Requests); reason != "" {
+			reasons[i] = reason
+			return deny(denyBatch)
+		}
+	}
+
+	// Merge the chains into one list of limits, summing the demand on
+	// limits that several requests share
+	var union []Limit
+	var demand []budget
+	positions := make([][]int, len(requests))
+	index := make(map[string]int)
+	for i, limits := range chains {
+		for _, limit := range limits {
+			j, seen := index[limit.Key]
+			if !seen {
+				j = len(union)
+				index[limit.Key] = j
+				union = append(union, limit)
+				demand = append(demand, budget{})
+			}
+			positions[i] = append(positions[i], j)
+			demand[j].requests = addCapped(demand[j].requests, requests[i].Requests)
+			demand[j].tokens = addCapped(demand[j].tokens, requests[i].Tokens)
+		}
+	}
+
+	result, err := rl.reserveUnion(union, demand, chains, positions)
+	if err != nil {
+		log.Printf("Error updating counters for batch: %v", err)
+		return deny(denyError)
+	}
+
+	for i, request := range requests {
+		limits := chains[i]
+		remaining := make([]budget, len(positions[i]))
+		for k, j := range positions[i] {
+			remaining[k] = result.remaining[j]
+		}
+		rl.recordRemaining(limits, remaining, request.APIKey)
+
+		reservation := &Reservation{
+			Allowed:           result.allowed,
+			RemainingTokens:   remaining[0].tokens,
+			RemainingRequests: remaining[0].requests,
+		}
+		for _, b := range remaining[1:] {
+			if b.requests < reservation.RemainingRequests {
+				reservation.RemainingRequests = b.requests
+			}
+			if b.tokens < reservation.RemainingTokens {
+				reservation.RemainingTokens = b.tokens
+			}
+		}
+		reservation.RequestLimit = result.requestLimits[i]
+		reservation.ResetMs = millis(result.resets[i])
+		reservations[i] = reservation
+
+		if !result.allowed {
+			if result.retryAfter > 0 {
+				reservation.RetryAfterMs = millis(result.retryAfter)
+			}
+			reasons[i] = batchDenyReason(limits, positions[i], demand, result.remaining)
+			continue
+		}
+
+		reservation.ReservedTokens = request.Tokens
+		reservation.ReservedRequests = request.Requests
+		reservation.TargetEndpointPath = request.TargetEndpoint
+		rl.trackFor(reservation, request.ClientID, request.APIKey, limits, result.takenAt, hold)
+
+		// Process based on priority
+		rl.scheduler.Dispatch(request.APIKey, reservation)
+	}
+
+	return reservations, reasons
+}
+
+// reserveUnion takes each limit's demand from every limit or from none of
+// them. The store locks the limits in a fixed order, so concurrent batches
+// over overlapping endpoints cannot deadlock. chains and positions map each
+// request to its limits within the union.
+func (rl *Limiter) reserveUnion(limits []Limit, demand []budget, chains [][]Limit, positions [][]int) (*batchOutcome, error) {
+	result := &batchOutcome{}
+
+	// budgets reports each request's tightest request budget
+	budgets := func(counters []*Counters, now time.Time) {
+		result.requestLimits = make([]int, len(chains))
+		result.resets = make([]time.Duration, len(chains))
+		for i, chain := range chains {
+			chainCounters := make([]*Counters, len(positions[i]))
+			remaining := make([]budget, len(positions[i]))
+			for k, j := range positions[i] {
+				chainCounters[k] = counters[j]
+				remaining[k] = result.remaining[j]
+			}
+			result.requestLimits[i], result.resets[i] = requestBudget(chain, chainCounters, remaining, now)
+		}
+	}
+
+	err := rl.store.Update(limits, func(counters []*Counters) bool {
+		now := rl.now()
+		*result = batchOutcome{allowed: true, takenAt: now}
+		remaining := remainingByLimit(counters, now)
+		for j := range limits {
+			if demand[j].requests > remaining[j].requests || demand[j].tokens > remaining[j].tokens {
+				result.allowed = false
+			}
+		}
+
+		if !result.allowed {
+			for j, c := range counters {
+				d := c.retryAfter(now, demand[j].tokens, demand[j].requests)
+				if d < 0 {
+					result.retryAfter = d
+					break
+				}
+				if d > result.retryAfter {
+					result.retryAfter = d
+				}
+			}
+			result.remaining = remaining
+			budgets(counters, now)
+			return false
+		}
+
+		// Update state
+		for j, c := range counters {
+			c.Requests.Take(now, demand[j].requests)
+			c.Tokens.Take(now, demand[j].tokens)
+			c.LastRequest = now
+		}
+		result.remaining = remainingByLimit(counters, now)
+		budgets(counters, now)
+		return true
+	})
+
+	return result, err
+}
+
+// exceededLimit names the outermost limit smaller than tokens or requests
+// on their own, or returns "" if every limit could hold them
+func exceededLimit(limits []Limit, tokens, requests int) string {
+	for _, limit := range limits {
+		if requests > limit.State.RPM {
+			return limit.level + "_rpm"
+		}
+		if tokens > limit.State.TPM {
+			return limit.level + "_tpm"
+		}
+	}
+	return ""
+}
+
+// addCapped adds two non-negative amounts, stopping at math.MaxInt rather
+// than wrapping around
+func addCapped(a, b int) int {
+	if a > math.MaxInt-b {
+		return math.MaxInt
+	}
+	return a + b
+}
+
+// batchDenyReason names the outermost limit on a request's chain too small
+// for the batch's combined demand, or denyBatch if the chain had room
+func batchDenyReason(limits []Limit, positions []int, demand, remaining []budget) string {
+	for k, j := range positions {
+		if demand[j].requests > remaining[j].requests {
+			return limits[k].level + "_rpm"
+		}
+		if demand[j].tokens > remaining[j].tokens {
+			return limits[k].level + "_tpm"
+		}
+	}
+	return denyBatch
+}
@@ -0,0 +1,215 @@
+package ratelimit
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"testing"
+	"time"
+)
+
+func newBatchLimiter() *Limiter {
+	return MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			TPM:    150,
+			Endpoints: []EndpointConfig{
+				{Path: "/a", RPM: 10, TPM: 100},
+				{Path: "/b", RPM: 2, TPM: 100},
+			},
+		},
+	}})
+}
+
+func TestLimiter_ReserveBatch(t *testing.T) {
+	tests := []struct {
+		name        string
+		requests    []BatchRequest
+		wantAllowed bool
+	}{
+		{
+			name: "Every reservation fits",
+			requests: []BatchRequest{
+				{ClientID: "client1", Tokens: 50, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+				{ClientID: "client1", Tokens: 50, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/b"},
+			},
+			wantAllowed: true,
+		},
+		{
+			name: "One endpoint over its limit",
+			requests: []BatchRequest{
+				{ClientID: "client1", Tokens: 1, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+				{ClientID: "client1", Tokens: 1, Requests: 3, APIKey: "API_KEY_1", TargetEndpoint: "/b"},
+			},
+			wantAllowed: false,
+		},
+		{
+			name: "Combined demand over a shared cap",
+			requests: []BatchRequest{
+				{ClientID: "client1", Tokens: 80, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+				{ClientID: "client1", Tokens: 80, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/b"},
+			},
+			wantAllowed: false,
+		},
+		{
+			name: "Unknown endpoint",
+			requests: []BatchRequest{
+				{ClientID: "client1", Tokens: 1, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+				{ClientID: "client1", Tokens: 1, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/missing"},
+			},
+			wantAllowed: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := newBatchLimiter()
+
+			reservations, err := limiter.ReserveBatch(tt.requests)
+			if err != nil {
+				t.Fatalf("ReserveBatch failed: %v", err)
+			}
+			if len(reservations) != len(tt.requests) {
+				t.Fatalf("Expected %d reservations, got %d", len(tt.requests), len(reservations))
+			}
+			for i, reservation := range reservations {
+				if reservation.Allowed != tt.wantAllowed {
+					t.Errorf("Expected reservation %d allowed=%v, got %v", i, tt.wantAllowed, reservation.Allowed)
+				}
+			}
+
+			// A denied batch leaves every budget untouched
+			if !tt.wantAllowed {
+				next := limiter.Reserve("client1", 100, 2, "API_KEY_1", "/b")
+				if !next.Allowed {
+					t.Errorf("Expected the full budget of /b after a denied batch, got %+v", next)
+				}
+			}
+		})
+	}
+}
+
+func TestLimiter_ReserveBatchRemaining(t *testing.T) {
+	limiter := newBatchLimiter()
+
+	reservations, _ := limiter.ReserveBatch([]BatchRequest{
+		{ClientID: "client1", Tokens: 20, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+		{ClientID: "client1", Tokens: 30, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+	})
+
+	// Both reservations see the endpoint after the whole batch was taken
+	for i, reservation := range reservations {
+		if reservation.RemainingRequests != 8 || reservation.RemainingTokens != 50 {
+			t.Errorf("Expected reservation %d to leave 8 requests and 50 tokens, got %+v", i, reservation)
+		}
+	}
+	if reservations[0].ReservedTokens != 20 || reservations[1].ReservedTokens != 30 {
+		t.Errorf("Expected each reservation to keep its own tokens, got %+v and %+v", reservations[0], reservations[1])
+	}
+}
+
+func TestLimiter_ReserveBatchEmpty(t *testing.T) {
+	limiter := newBatchLimiter()
+
+	if _, err := limiter.ReserveBatch(nil); !errors.Is(err, ErrEmptyBatch) {
+		t.Errorf("Expected ErrEmptyBatch, got %v", err)
+	}
+}
+
+func TestLimiter_ReserveBatchTooLarge(t *testing.T) {
+	limiter := newBatchLimiter()
+
+	requests := make([]BatchRequest, MaxBatchSize+1)
+	for i := range requests {
+		requests[i] = BatchRequest{ClientID: "client1", Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"}
+	}
+	if _, err := limiter.ReserveBatch(requests); !errors.Is(err, ErrBatchTooLarge) {
+		t.Errorf("Expected ErrBatchTooLarge, got %v", err)
+	}
+}
+
+func TestLimiter_ReserveBatchOverflow(t *testing.T) {
+	limiter := newBatchLimiter()
+
+	// Two halves of the int range would wrap around to a negative sum
+	huge := math.MaxInt/2 + 1
+	reservations, err := limiter.ReserveBatch([]BatchRequest{
+		{ClientID: "client1", Tokens: huge, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+		{ClientID: "client1", Tokens: huge, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+	})
+	if err != nil {
+		t.Fatalf("ReserveBatch failed: %v", err)
+	}
+	for i, reservation := range reservations {
+		if reservation.Allowed || reservation.RetryAfterMs != 0 {
+			t.Errorf("Expected reservation %d to be denied for good, got %+v", i, reservation)
+		}
+	}
+
+	if next := limiter.Reserve("client1", 100, 1, "API_KEY_1", "/a"); !next.Allowed || next.RemainingTokens != 0 {
+		t.Errorf("Expected the full token budget of /a after the denied batch, got %+v", next)
+	}
+}
+
+func TestLimiter_HoldBatch(t *testing.T) {
+	limiter := newBatchLimiter()
+
+	// Held reservations stay pending without a reservation TTL
+	reservations, err := limiter.HoldBatch([]BatchRequest{
+		{ClientID: "client1", Tokens: 10, Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+	}, time.Minute)
+	if err != nil || !reservations[0].Allowed {
+		t.Fatalf("Expected the batch to be held, got %+v, %v", reservations, err)
+	}
+	settlement, err := limiter.Cancel(reservations[0].ReservationID)
+	if err != nil {
+		t.Fatalf("Failed to cancel held reservation: %v", err)
+	}
+	if settlement.RefundedTokens != 10 || settlement.RefundedRequests != 1 {
+		t.Errorf("Expected the held reservation to be refunded, got %+v", settlement)
+	}
+}
+
+func TestLimiter_ReserveBatchConcurrent(t *testing.T) {
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{Path: "/a", RPM: 100, TPM: 1000},
+				{Path: "/b", RPM: 100, TPM: 1000},
+			},
+		},
+	}})
+	forward := []BatchRequest{
+		{ClientID: "client1", Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/a"},
+		{ClientID: "client1", Requests: 1, APIKey: "API_KEY_1", TargetEndpoint: "/b"},
+	}
+	backward := []BatchRequest{forward[1], forward[0]}
+
+	// Batches over the same endpoints in opposite orders must not deadlock,
+	// and never take more than the limit
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	allowed := 0
+	for i := 0; i < 150; i++ {
+		requests := forward
+		if i%2 == 1 {
+			requests = backward
+		}
+		wg.Add(1)
+		go func(requests []BatchRequest) {
+			defer wg.Done()
+			reservations, _ := limiter.ReserveBatch(requests)
+			if reservations[0].Allowed {
+				mutex.Lock()
+				allowed++
+				mutex.Unlock()
+			}
+		}(requests)
+	}
+	wg.Wait()
+
+	if allowed != 100 {
+		t.Errorf("Expected 100 allowed batches, got %d", allowed)
+	}
+}
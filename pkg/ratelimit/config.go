@@ -0,0 +1,243 @@
+package ratelimit
+
+import (
+	"fmt"
+	"time"
+)
+
+// Supported rate limiting algorithms
+const (
+	AlgorithmFixedWindow          = "fixed_window"
+	AlgorithmTokenBucket          = "token_bucket"
+	AlgorithmSlidingWindowLog     = "sliding_window_log"
+	AlgorithmSlidingWindowCounter = "sliding_window_counter"
+	AlgorithmGCRA                 = "gcra"
+)
+
+// Supported counter store backends
+const (
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Supported dispatch modes for priority classes
+const (
+	DispatchImmediate  = "immediate"
+	DispatchDelayed    = "delayed"
+	DispatchBackground = "background"
+)
+
+// AnyClient is the ClientLimit ClientID that applies to every client
+// without a limit of its own
+const AnyClient = "*"
+
+// Config describes a Limiter: its limits, how allowed reservations are
+// dispatched and where counters are kept
+type Config struct {
+	GlobalLimit LimitConfig
+	RateLimits  []RateLimit
+	// PriorityClasses assigns API keys to the classes of DispatchPolicies
+	PriorityClasses  map[string]int
+	DispatchPolicies map[int]DispatchPolicy
+	// ReservationTTL keeps allowed reservations pending until committed or
+	// cancelled, for at most the TTL. Zero makes them final right away.
+	ReservationTTL time.Duration
+	Store          StoreConfig
+	// Process is handed every allowed reservation according to its API
+	// key's dispatch policy. Allowed reservations are only counted if nil.
+	Process func(*Reservation)
+}
+
+// LimitConfig caps a group of reservations: everything the service
+// reserves, or everything reserved with one API key across its endpoints.
+// A zero RPM or TPM leaves that budget uncapped, and a LimitConfig with
+// neither set applies no limit at all. Shards splits the global cap evenly
+// across that many counters, each client drawing from one, so a shared
+// store is not contended on a single key; it may not exceed a capped RPM
+// or TPM, and is ignored on per-key caps.
+type LimitConfig struct {
+	RPM       int    `yaml:"rpm"`
+	TPM       int    `yaml:"tpm"`
+	Algorithm string `yaml:"algorithm"`
+	Shards    int    `yaml:"shards,omitempty"`
+}
+
+// RateLimit represents rate limiting configuration for an API key. RPM,
+// TPM and Algorithm set an optional aggregate cap across all endpoints.
+type RateLimit struct {
+	APIKey    string           `yaml:"apiKey" json:"apiKey"`
+	RPM       int              `yaml:"rpm,omitempty" json:"rpm,omitempty"`
+	TPM       int              `yaml:"tpm,omitempty" json:"tpm,omitempty"`
+	Algorithm string           `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	Endpoints []EndpointConfig `yaml:"endpoints" json:"endpoints"`
+}
+
+// Limits returns the API key's aggregate cap
+func (r RateLimit) Limits() LimitConfig {
+	return LimitConfig{RPM: r.RPM, TPM: r.TPM, Algorithm: r.Algorithm}
+}
+
+// Enabled reports whether the cap limits anything
+func (l LimitConfig) Enabled() bool {
+	return l.RPM > 0 || l.TPM > 0
+}
+
+// EndpointConfig represents configuration for a specific endpoint. Path
+// may be a route pattern: :name matches one path segment, a segment with
+// *, ? or [ is a glob matching one segment, and a trailing /* matches the
+// rest of the path. Methods restricts the endpoint to requests with one of
+// the listed HTTP methods.
+type EndpointConfig struct {
+	Path         string        `yaml:"path" json:"path"`
+	Methods      []string      `yaml:"methods,omitempty" json:"methods,omitempty"`
+	RPM          int           `yaml:"rpm" json:"rpm"`
+	TPM          int           `yaml:"tpm" json:"tpm"`
+	Algorithm    string        `yaml:"algorithm,omitempty" json:"algorithm,omitempty"`
+	ClientLimits []ClientLimit `yaml:"clientLimits,omitempty" json:"clientLimits,omitempty"`
+}
+
+// ClientLimit caps a single client's share of an endpoint budget, either
+// with fixed RPM/TPM or as a fraction of the endpoint's limits. A fixed
+// quota that leaves RPM or TPM at zero does not cap that budget for the
+// client.
+type ClientLimit struct {
+	ClientID string  `yaml:"clientID" json:"clientID"`
+	RPM      int     `yaml:"rpm,omitempty" json:"rpm,omitempty"`
+	TPM      int     `yaml:"tpm,omitempty" json:"tpm,omitempty"`
+	Fraction float64 `yaml:"fraction,omitempty" json:"fraction,omitempty"`
+}
+
+// DispatchPolicy describes how allowed reservations of a priority class are
+// processed. Keys without a class, and classes without a policy, are
+// processed immediately. Weight is the class's share of capacity freed up
+// for waiting reservations, and defaults to 1.
+type DispatchPolicy struct {
+	Mode      string        `yaml:"mode"`
+	Delay     time.Duration `yaml:"delay"`
+	Workers   int           `yaml:"workers"`
+	QueueSize int           `yaml:"queueSize"`
+	Weight    int           `yaml:"weight"`
+}
+
+// StoreConfig selects where rate limit counters are kept. The default
+// in-memory store is local to one process; the redis store shares counters
+// between every node pointed at the same server.
+type StoreConfig struct {
+	Type      string `yaml:"type"`
+	Address   string `yaml:"address"`
+	Password  string `yaml:"password"`
+	KeyPrefix string `yaml:"keyPrefix"`
+}
+
+// Validate checks the configuration for values the limiter cannot use
+func (c Config) Validate() error {
+	switch c.Store.Type {
+	case "", StoreMemory:
+	case StoreRedis:
+		if c.Store.Address == "" {
+			return fmt.Errorf("store: redis requires an address")
+		}
+	default:
+		return fmt.Errorf("store: unknown type %q", c.Store.Type)
+	}
+
+	for class, policy := range c.DispatchPolicies {
+		if policy.Weight < 0 {
+			return fmt.Errorf("dispatch policy %d: weight must be non-negative", class)
+		}
+		switch policy.Mode {
+		case "", DispatchImmediate:
+		case DispatchDelayed:
+			if policy.Delay <= 0 {
+				return fmt.Errorf("dispatch policy %d: delayed mode requires a positive delay", class)
+			}
+		case DispatchBackground:
+			if policy.Workers < 0 || policy.QueueSize < 0 {
+				return fmt.Errorf("dispatch policy %d: workers and queueSize must be non-negative", class)
+			}
+		default:
+			return fmt.Errorf("dispatch policy %d: unknown mode %q", class, policy.Mode)
+		}
+	}
+
+	if c.ReservationTTL < 0 {
+		return fmt.Errorf("reservations: ttl must be non-negative")
+	}
+
+	if err := c.GlobalLimit.validate(); err != nil {
+		return fmt.Errorf("global limit: %v", err)
+	}
+
+	endpoints := make(map[string][]EndpointConfig)
+	for _, rateLimit := range c.RateLimits {
+		if err := rateLimit.Limits().validate(); err != nil {
+			return fmt.Errorf("api key %s: %v", rateLimit.APIKey, err)
+		}
+		endpoints[rateLimit.APIKey] = append(endpoints[rateLimit.APIKey], rateLimit.Endpoints...)
+		if err := validateRoutes(endpoints[rateLimit.APIKey]); err != nil {
+			return fmt.Errorf("api key %s %v", rateLimit.APIKey, err)
+		}
+		for _, endpoint := range rateLimit.Endpoints {
+			if !isValidAlgorithm(endpoint.Algorithm) {
+				return fmt.Errorf("api key %s endpoint %s: unknown algorithm %q", rateLimit.APIKey, endpoint.Path, endpoint.Algorithm)
+			}
+			for _, client := range endpoint.ClientLimits {
+				if err := client.validate(); err != nil {
+					return fmt.Errorf("api key %s endpoint %s: %v", rateLimit.APIKey, endpoint.Path, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// validate checks an aggregate cap
+func (l LimitConfig) validate() error {
+	if l.RPM < 0 || l.TPM < 0 {
+		return fmt.Errorf("rpm and tpm must be non-negative")
+	}
+	if l.Shards < 0 {
+		return fmt.Errorf("shards must be non-negative")
+	}
+	// Every shard needs at least one of each capped budget, as a zero
+	// share would leave it uncapped
+	if (l.RPM > 0 && l.Shards > l.RPM) || (l.TPM > 0 && l.Shards > l.TPM) {
+		return fmt.Errorf("shards must not exceed a capped rpm or tpm")
+	}
+	if !isValidAlgorithm(l.Algorithm) {
+		return fmt.Errorf("unknown algorithm %q", l.Algorithm)
+	}
+	return nil
+}
+
+// validate checks that a client limit names its client and sets exactly
+// one kind of quota
+func (l ClientLimit) validate() error {
+	if l.ClientID == "" {
+		return fmt.Errorf("client limit requires a clientID")
+	}
+	if l.Fraction < 0 || l.Fraction > 1 {
+		return fmt.Errorf("client %s: fraction must be between 0 and 1", l.ClientID)
+	}
+	if l.Fraction > 0 && (l.RPM != 0 || l.TPM != 0) {
+		return fmt.Errorf("client %s: set either fraction or rpm/tpm, not both", l.ClientID)
+	}
+	if l.RPM < 0 || l.TPM < 0 {
+		return fmt.Errorf("client %s: rpm and tpm must be non-negative", l.ClientID)
+	}
+	if l.Fraction == 0 && l.RPM == 0 && l.TPM == 0 {
+		return fmt.Errorf("client %s: set fraction, rpm or tpm", l.ClientID)
+	}
+	return nil
+}
+
+// isValidAlgorithm reports whether name is a supported algorithm; an empty
+// name selects the default fixed window
+func isValidAlgorithm(name string) bool {
+	switch name {
+	case "", AlgorithmFixedWindow, AlgorithmTokenBucket, AlgorithmSlidingWindowLog,
+		AlgorithmSlidingWindowCounter, AlgorithmGCRA:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,12 @@
+// Package ratelimit enforces per-minute request and token budgets on API
+// keys, their endpoints and their clients, under optional global and
+// per-key caps. It is the limiter behind the rate limiting service, and can
+// be embedded in any Go service instead of calling the service over HTTP.
+//
+// A Limiter is built from a Config, usually with counters in process
+// memory, or in a shared Redis-compatible store so that every process
+// enforces the same budgets. Reserve and Allow take capacity right away,
+// Wait blocks until capacity frees up, and Quota reports how much of each
+// budget is in use. Package middleware enforces limits on the requests a
+// Fiber or net/http service handles.
+package ratelimit
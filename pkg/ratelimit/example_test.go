@@ -0,0 +1,43 @@
+package ratelimit_test
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func ExampleLimiter() {
+	limiter, err := ratelimit.New(ratelimit.Config{
+		RateLimits: []ratelimit.RateLimit{
+			{
+				APIKey: "API_KEY_1",
+				Endpoints: []ratelimit.EndpointConfig{
+					{Path: "/chat", RPM: 2, TPM: 1000},
+				},
+			},
+		},
+	})
+	if err != nil {
+		panic(err)
+	}
+
+	fmt.Println(limiter.Allow("client1", 400, 1, "API_KEY_1", "/chat"))
+	fmt.Println(limiter.Allow("client1", 400, 1, "API_KEY_1", "/chat"))
+	fmt.Println(limiter.Allow("client1", 400, 1, "API_KEY_1", "/chat"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = limiter.Wait(ctx, "client1", 400, 1, "API_KEY_1", "/chat")
+	fmt.Println(err)
+
+	quotas, _ := limiter.Quota("API_KEY_1", "/chat", "")
+	fmt.Println(quotas[0].Requests.Used, quotas[0].Tokens.Remaining)
+	// Output:
+	// true
+	// true
+	// false
+	// context deadline exceeded
+	// 2 200
+}
@@ -0,0 +1,56 @@
+package ratelimit
+
+import "time"
+
+// fixedWindow counts units in windows that start with the first request
+// after the previous window expired
+type fixedWindow struct {
+	limit  int
+	window time.Duration
+	Start  time.Time `json:"start"`
+	Count  int       `json:"count"`
+}
+
+func newFixedWindow(limit int, window time.Duration) *fixedWindow {
+	return &fixedWindow{limit: limit, window: window}
+}
+
+// advance starts a fresh window once the current one has expired
+func (w *fixedWindow) advance(now time.Time) {
+	if now.Sub(w.Start) >= w.window {
+		w.Start = now
+		w.Count = 0
+	}
+}
+
+func (w *fixedWindow) Remaining(now time.Time) int {
+	w.advance(now)
+	return w.limit - w.Count
+}
+
+func (w *fixedWindow) Take(now time.Time, n int) {
+	w.advance(now)
+	w.Count += n
+}
+
+func (w *fixedWindow) Refund(takenAt, now time.Time, n int) {
+	w.advance(now)
+	if takenAt.Before(w.Start) {
+		return
+	}
+	w.Count -= n
+	if w.Count < 0 {
+		w.Count = 0
+	}
+}
+
+func (w *fixedWindow) RetryAfter(now time.Time, n int) time.Duration {
+	if n > w.limit {
+		return -1
+	}
+	w.advance(now)
+	if w.Count+n <= w.limit {
+		return 0
+	}
+	return w.Start.Add(w.window).Sub(now)
+}
@@ -0,0 +1,70 @@
+package ratelimit
+
+import "time"
+
+// gcra implements the generic cell rate algorithm: each unit pushes the
+// theoretical arrival time (TAT) forward by one emission interval, and a
+// reservation fits while the TAT stays within one window of now
+type gcra struct {
+	limit    int
+	window   time.Duration
+	interval time.Duration
+	TAT      time.Time `json:"tat"`
+}
+
+func newGCRA(limit int, window time.Duration) *gcra {
+	g := &gcra{limit: limit, window: window}
+	if limit > 0 {
+		g.interval = window / time.Duration(limit)
+	}
+	return g
+}
+
+// debt returns how far the TAT is ahead of now
+func (g *gcra) debt(now time.Time) time.Duration {
+	if g.TAT.Before(now) {
+		return 0
+	}
+	return g.TAT.Sub(now)
+}
+
+func (g *gcra) Remaining(now time.Time) int {
+	if g.interval <= 0 {
+		return 0
+	}
+	return int((g.window - g.debt(now)) / g.interval)
+}
+
+func (g *gcra) Take(now time.Time, n int) {
+	tat := g.TAT
+	if tat.Before(now) {
+		tat = now
+	}
+	g.TAT = tat.Add(time.Duration(n) * g.interval)
+}
+
+func (g *gcra) Refund(takenAt, now time.Time, n int) {
+	if now.Sub(takenAt) >= g.window {
+		return
+	}
+	// Debt from before now has already been paid off
+	g.TAT = g.TAT.Add(-time.Duration(n) * g.interval)
+	if g.TAT.Before(now) {
+		g.TAT = now
+	}
+}
+
+func (g *gcra) RetryAfter(now time.Time, n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if n > g.limit || g.interval <= 0 {
+		return -1
+	}
+	// n units fit once the debt leaves room for n intervals in the window
+	wait := g.debt(now) - (g.window - time.Duration(n)*g.interval)
+	if wait < 0 {
+		return 0
+	}
+	return wait
+}
@@ -0,0 +1,223 @@
+package ratelimit
+
+import (
+	"errors"
+	"log"
+	"sort"
+	"time"
+)
+
+// MaxLeaseTTL is the longest a lease can be held; longer TTLs are cut to it
+const MaxLeaseTTL = window
+
+// ErrLeaseNotFound is returned for leases that never existed, were already
+// returned, or have expired
+var ErrLeaseNotFound = errors.New("lease not found")
+
+// ErrInvalidLeaseTTL is returned for leases requested without a positive TTL
+var ErrInvalidLeaseTTL = errors.New("ratelimit: lease TTL must be positive")
+
+// Lease is a slice of an endpoint's budget granted to a client to spend
+// locally until ExpiresAt, without asking the limiter for each request.
+// RetryAfterMs is set on denials that can succeed later.
+type Lease struct {
+	Granted            bool       `json:"granted"`
+	LeaseID            string     `json:"leaseID,omitempty"`
+	Tokens             int        `json:"tokens"`
+	Requests           int        `json:"requests"`
+	TargetEndpointPath string     `json:"targetEndpointPath"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"`
+	RetryAfterMs       int64      `json:"retryAfterMs,omitempty"`
+}
+
+// Lease grants clientID requests and tokens on an API key's endpoint for
+// ttl, capped at MaxLeaseTTL. The lease is charged to every budget on the
+// endpoint's path like a reservation, and the requests and tokens of
+// outstanding leases on a budget never add up to more than its limit, even
+// after its window resets. Outstanding leases are kept in the store with
+// the budgets, so limiters sharing a store count each other's and can
+// return each other's. A lease that
+// does not fit is denied rather than an error; it returns ErrUnknownKey for
+// unknown keys and endpoints.
+func (rl *Limiter) Lease(clientID string, tokens, requests int, apiKey, targetEndpoint string, ttl time.Duration) (*Lease, error) {
+	if ttl <= 0 {
+		return nil, ErrInvalidLeaseTTL
+	}
+	if ttl > MaxLeaseTTL {
+		ttl = MaxLeaseTTL
+	}
+
+	rl.mutex.RLock()
+	limits, exists := rl.limits.chain(apiKey, targetEndpoint, clientID)
+	rl.mutex.RUnlock()
+
+	if !exists {
+		return nil, ErrUnknownKey
+	}
+
+	hold := &LeaseHold{
+		ID:        newReservationID(),
+		Requests:  requests,
+		Tokens:    tokens,
+		ExpiresAt: rl.now().Add(ttl),
+	}
+	result, err := rl.reserveLimits(limits, tokens, requests, hold)
+	if err != nil {
+		return nil, err
+	}
+	rl.recordRemaining(limits, result.remaining, apiKey)
+	if !result.allowed {
+		return deniedLease(result.retryAfter), nil
+	}
+
+	pending := &pendingReservation{
+		id:             hold.ID,
+		clientID:       clientID,
+		apiKey:         apiKey,
+		targetEndpoint: targetEndpoint,
+		tokens:         tokens,
+		requests:       requests,
+		limits:         limits,
+		takenAt:        result.takenAt,
+		leased:         true,
+		expiresAt:      hold.ExpiresAt,
+	}
+	rl.share(leaseRecord(pending.id), pending, ttl)
+
+	rl.leaseMutex.Lock()
+	defer rl.leaseMutex.Unlock()
+
+	// An expired lease drops out of the ledger, and its record out of a
+	// shared store, on its own; only this limiter's copy is left to clear
+	pending.timer = time.AfterFunc(ttl, func() {
+		rl.leaseMutex.Lock()
+		defer rl.leaseMutex.Unlock()
+
+		if _, exists := rl.leases[pending.id]; exists {
+			delete(rl.leases, pending.id)
+			log.Printf("Lease %s expired without being returned, its usage stays charged", pending.id)
+		}
+	})
+	rl.leases[pending.id] = pending
+
+	return &Lease{
+		Granted:            true,
+		LeaseID:            pending.id,
+		Tokens:             tokens,
+		Requests:           requests,
+		TargetEndpointPath: targetEndpoint,
+		ExpiresAt:          &pending.expiresAt,
+	}, nil
+}
+
+// ReturnLease ends a lease early with the requests and tokens its holder
+// actually used, refunding the rest. Tokens used beyond the lease are
+// charged like a commit's; requests beyond it are not, since the holder
+// was never allowed them.
+func (rl *Limiter) ReturnLease(id string, usedTokens, usedRequests int) (*Settlement, error) {
+	rl.leaseMutex.Lock()
+	pending, exists := rl.leases[id]
+	if exists {
+		delete(rl.leases, id)
+		pending.timer.Stop()
+	}
+	rl.leaseMutex.Unlock()
+
+	pending, err := rl.claim(leaseRecord(id), pending, ErrLeaseNotFound)
+	if err != nil {
+		return nil, err
+	}
+	pending.id = id
+
+	settlement := &Settlement{
+		ReservationID:    id,
+		ReservedTokens:   pending.tokens,
+		ReservedRequests: pending.requests,
+		CommittedTokens:  usedTokens,
+	}
+
+	unusedTokens := pending.tokens - usedTokens
+	if unusedTokens > 0 {
+		settlement.RefundedTokens = unusedTokens
+	}
+	if unusedRequests := pending.requests - usedRequests; unusedRequests > 0 {
+		settlement.RefundedRequests = unusedRequests
+	}
+	if err := rl.adjust(pending, unusedTokens, settlement.RefundedRequests); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// leaseRecord returns the ID a lease is recorded under in a shared store,
+// apart from pending reservations so neither can settle the other
+func leaseRecord(id string) string {
+	return "lease:" + id
+}
+
+// leaseWait returns zero if requests and tokens fit alongside the
+// outstanding leases on every limit, and otherwise how long until enough of
+// them expire, or a negative duration if they never will
+func leaseWait(limits []Limit, counters []*Counters, tokens, requests int, now time.Time) time.Duration {
+	var wait time.Duration
+	for i, c := range counters {
+		state := limits[i].State
+		if requests > state.RPM || tokens > state.TPM {
+			return -1
+		}
+
+		// Outstanding leases on this limit, soonest to expire first
+		outstanding := liveHolds(c.Leases, now)
+		leasedRequests, leasedTokens := 0, 0
+		for _, hold := range outstanding {
+			leasedRequests += hold.Requests
+			leasedTokens += hold.Tokens
+		}
+		sort.Slice(outstanding, func(i, j int) bool {
+			return outstanding[i].ExpiresAt.Before(outstanding[j].ExpiresAt)
+		})
+
+		for _, hold := range outstanding {
+			if leasedRequests+requests <= state.RPM && leasedTokens+tokens <= state.TPM {
+				break
+			}
+			leasedRequests -= hold.Requests
+			leasedTokens -= hold.Tokens
+			if d := hold.ExpiresAt.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// liveHolds returns the holds that have not expired by now
+func liveHolds(holds []LeaseHold, now time.Time) []LeaseHold {
+	var live []LeaseHold
+	for _, hold := range holds {
+		if hold.ExpiresAt.After(now) {
+			live = append(live, hold)
+		}
+	}
+	return live
+}
+
+// releaseHold returns the live holds other than the one with id
+func releaseHold(holds []LeaseHold, id string, now time.Time) []LeaseHold {
+	var kept []LeaseHold
+	for _, hold := range liveHolds(holds, now) {
+		if hold.ID != id {
+			kept = append(kept, hold)
+		}
+	}
+	return kept
+}
+
+// deniedLease returns a denied lease, retryable after wait if positive
+func deniedLease(wait time.Duration) *Lease {
+	lease := &Lease{Granted: false}
+	if wait > 0 {
+		lease.RetryAfterMs = millis(wait)
+	}
+	return lease
+}
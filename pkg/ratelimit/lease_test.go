@@ -0,0 +1,149 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func newLeaseLimiter(clock *testClock) *Limiter {
+	limiter := newReservationLimiter(0)
+	limiter.now = clock.Now
+	return limiter
+}
+
+func TestLimiter_Lease(t *testing.T) {
+	tests := []struct {
+		name        string
+		apiKey      string
+		tokens      int
+		requests    int
+		ttl         time.Duration
+		wantGranted bool
+		wantErr     error
+	}{
+		{name: "Fits the budget", apiKey: "API_KEY_1", tokens: 50, requests: 5, ttl: 5 * time.Second, wantGranted: true},
+		{name: "TTL past the maximum", apiKey: "API_KEY_1", tokens: 50, requests: 5, ttl: time.Hour, wantGranted: true},
+		{name: "Larger than the budget", apiKey: "API_KEY_1", tokens: 101, requests: 5, ttl: 5 * time.Second},
+		{name: "Unknown key", apiKey: "INVALID_KEY", tokens: 50, requests: 5, ttl: 5 * time.Second, wantErr: ErrUnknownKey},
+		{name: "No TTL", apiKey: "API_KEY_1", tokens: 50, requests: 5, wantErr: ErrInvalidLeaseTTL},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			clock := &testClock{now: time.Now()}
+			limiter := newLeaseLimiter(clock)
+
+			lease, err := limiter.Lease("client1", tt.tokens, tt.requests, tt.apiKey, "/test", tt.ttl)
+			if err != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err != nil {
+				return
+			}
+			if lease.Granted != tt.wantGranted {
+				t.Fatalf("Expected granted %v, got %+v", tt.wantGranted, lease)
+			}
+			if !lease.Granted {
+				if lease.RetryAfterMs != 0 {
+					t.Errorf("Expected no retry hint for a lease that never fits, got %d", lease.RetryAfterMs)
+				}
+				return
+			}
+			if lease.LeaseID == "" || lease.ExpiresAt.Sub(clock.Now()) > MaxLeaseTTL {
+				t.Errorf("Expected a lease expiring within %v, got %+v", MaxLeaseTTL, lease)
+			}
+
+			// The lease is charged like a reservation
+			if reservation := limiter.Reserve("client1", 51, 1, "API_KEY_1", "/test"); reservation.Allowed {
+				t.Error("Expected leased tokens to be charged to the budget")
+			}
+		})
+	}
+}
+
+func TestLimiter_LeaseNeverExceedsLimit(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newLeaseLimiter(clock)
+
+	// Start the window before the lease, so it resets while the lease is
+	// outstanding
+	limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+	clock.Advance(30 * time.Second)
+
+	first, err := limiter.Lease("client1", 10, 6, "API_KEY_1", "/test", 50*time.Second)
+	if err != nil || !first.Granted {
+		t.Fatalf("Expected the first lease to be granted, got %+v, %v", first, err)
+	}
+
+	// The counters have room again, but another 6 requests would lease
+	// more than the limit
+	clock.Advance(31 * time.Second)
+	second, err := limiter.Lease("client1", 10, 6, "API_KEY_1", "/test", 50*time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Granted {
+		t.Fatal("Expected outstanding leases to be capped at the limit")
+	}
+	if second.RetryAfterMs != (19 * time.Second).Milliseconds() {
+		t.Errorf("Expected a retry hint until the first lease expires, got %d", second.RetryAfterMs)
+	}
+
+	// What is left of the limit can still be leased
+	if third, _ := limiter.Lease("client1", 10, 4, "API_KEY_1", "/test", 50*time.Second); !third.Granted {
+		t.Error("Expected a lease within the rest of the limit to be granted")
+	}
+}
+
+func TestLimiter_LeaseExpiry(t *testing.T) {
+	limiter := newReservationLimiter(0)
+
+	lease, err := limiter.Lease("client1", 100, 10, "API_KEY_1", "/test", 50*time.Millisecond)
+	if err != nil || !lease.Granted {
+		t.Fatalf("Expected the lease to be granted, got %+v, %v", lease, err)
+	}
+	time.Sleep(100 * time.Millisecond)
+
+	// Expired leases cannot be returned, and their usage stays charged
+	if _, err := limiter.ReturnLease(lease.LeaseID, 0, 0); !errors.Is(err, ErrLeaseNotFound) {
+		t.Errorf("Expected ErrLeaseNotFound, got %v", err)
+	}
+	if reservation := limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test"); reservation.Allowed {
+		t.Error("Expected the expired lease to stay charged")
+	}
+}
+
+func TestLimiter_ReturnLease(t *testing.T) {
+	limiter := newReservationLimiter(0)
+
+	lease, err := limiter.Lease("client1", 80, 8, "API_KEY_1", "/test", 5*time.Second)
+	if err != nil || !lease.Granted {
+		t.Fatalf("Expected the lease to be granted, got %+v, %v", lease, err)
+	}
+
+	settlement, err := limiter.ReturnLease(lease.LeaseID, 30, 3)
+	if err != nil {
+		t.Fatalf("ReturnLease failed: %v", err)
+	}
+	if settlement.RefundedTokens != 50 || settlement.RefundedRequests != 5 {
+		t.Errorf("Expected 50 tokens and 5 requests refunded, got %+v", settlement)
+	}
+
+	// Returned leases no longer count against new ones
+	next, err := limiter.Lease("client1", 70, 7, "API_KEY_1", "/test", 5*time.Second)
+	if err != nil || !next.Granted {
+		t.Errorf("Expected the unused capacity to be leasable again, got %+v, %v", next, err)
+	}
+
+	if _, err := limiter.ReturnLease(lease.LeaseID, 30, 3); !errors.Is(err, ErrLeaseNotFound) {
+		t.Errorf("Expected ErrLeaseNotFound on second return, got %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if report := limiter.Shutdown(ctx); report.Leases != 1 {
+		t.Errorf("Expected shutdown to drop 1 lease, got %+v", report)
+	}
+}
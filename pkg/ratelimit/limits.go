@@ -0,0 +1,210 @@
+package ratelimit
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+)
+
+// globalKey is the store key of the service-wide limit
+const globalKey = "_global"
+
+// unlimited stands in for an uncapped budget in an aggregate limit
+const unlimited = math.MaxInt32
+
+// Levels of the limit tree, outermost first
+const (
+	levelGlobal   = "global"
+	levelAPIKey   = "api_key"
+	levelEndpoint = "endpoint"
+	levelClient   = "client"
+)
+
+// limitTree holds every limit a reservation may have to fit: the global
+// cap, each API key's aggregate cap, the key's endpoints and their client
+// quotas. A reservation is taken from every level on its path or none.
+type limitTree struct {
+	// globals holds the shards of the global cap, and is empty if the cap
+	// is disabled
+	globals []*EndpointState
+	apiKeys map[string]*apiKeyState
+}
+
+// apiKeyState holds an API key's aggregate cap and its endpoints, by route,
+// with the router matching target endpoints to them
+type apiKeyState struct {
+	limits    *EndpointState
+	endpoints map[string]*EndpointState
+	routes    []string
+	router    *router
+}
+
+// newLimitTree builds the tree for a configuration. States whose limits are
+// unchanged from previous are reused so their counters carry over.
+func newLimitTree(global LimitConfig, rateLimits []RateLimit, previous *limitTree) *limitTree {
+	if previous == nil {
+		previous = &limitTree{apiKeys: make(map[string]*apiKeyState)}
+	}
+
+	tree := &limitTree{
+		globals: newGlobalShards(global, previous.globals),
+		apiKeys: make(map[string]*apiKeyState),
+	}
+
+	for _, rateLimit := range rateLimits {
+		old, exists := previous.apiKeys[rateLimit.APIKey]
+		if !exists {
+			old = &apiKeyState{endpoints: make(map[string]*EndpointState)}
+		}
+
+		keyState, exists := tree.apiKeys[rateLimit.APIKey]
+		if !exists {
+			keyState = &apiKeyState{
+				limits:    reuse(old.limits, newAggregateState(rateLimit.Limits())),
+				endpoints: make(map[string]*EndpointState),
+			}
+			tree.apiKeys[rateLimit.APIKey] = keyState
+		}
+
+		for _, endpoint := range rateLimit.Endpoints {
+			route := endpoint.Route()
+			if _, exists := keyState.endpoints[route]; !exists {
+				keyState.routes = append(keyState.routes, route)
+			}
+			keyState.endpoints[route] = reuse(old.endpoints[route], newEndpointState(endpoint))
+		}
+	}
+
+	for _, keyState := range tree.apiKeys {
+		keyState.router = newRouter(keyState.routes, keyState.endpoints)
+	}
+
+	return tree
+}
+
+// reuse returns existing if it enforces the same limits as state
+func reuse(existing, state *EndpointState) *EndpointState {
+	if existing != nil && state != nil && existing.sameLimits(state) {
+		return existing
+	}
+	return state
+}
+
+// newGlobalShards creates the states of the global cap, split into its
+// shards so they add up to the cap, or none if the cap is disabled
+func newGlobalShards(global LimitConfig, previous []*EndpointState) []*EndpointState {
+	if !global.Enabled() {
+		return nil
+	}
+
+	n := global.Shards
+	if n < 1 {
+		n = 1
+	}
+	shards := make([]*EndpointState, n)
+	for i := range shards {
+		shard := global
+		shard.RPM = shareOf(global.RPM, n, i)
+		shard.TPM = shareOf(global.TPM, n, i)
+
+		var existing *EndpointState
+		if i < len(previous) {
+			existing = previous[i]
+		}
+		shards[i] = reuse(existing, newAggregateState(shard))
+	}
+	return shards
+}
+
+// shareOf returns shard i's share of a budget split n ways, giving the
+// remainder to the first shards
+func shareOf(budget, n, i int) int {
+	share := budget / n
+	if i < budget%n {
+		share++
+	}
+	return share
+}
+
+// global returns the shard of the global cap a client's reservations on an
+// endpoint are taken from, and whether the cap is enabled. Each client
+// always maps to the same shard.
+func (t *limitTree) global(apiKey, endpoint, clientID string) (Limit, bool) {
+	switch len(t.globals) {
+	case 0:
+		return Limit{}, false
+	case 1:
+		return Limit{Key: globalKey, State: t.globals[0], level: levelGlobal}, true
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(clientKey(apiKey, endpoint, clientID)))
+	i := int(hash.Sum32() % uint32(len(t.globals)))
+	return Limit{Key: fmt.Sprintf("%s#%d", globalKey, i), State: t.globals[i], level: levelGlobal}, true
+}
+
+// newAggregateState creates the state for a global or per-key cap, or nil
+// if the cap is disabled
+func newAggregateState(limits LimitConfig) *EndpointState {
+	if !limits.Enabled() {
+		return nil
+	}
+
+	state := newEndpointState(EndpointConfig{
+		RPM:       limits.RPM,
+		TPM:       limits.TPM,
+		Algorithm: limits.Algorithm,
+	})
+	if state.RPM == 0 {
+		state.RPM = unlimited
+	}
+	if state.TPM == 0 {
+		state.TPM = unlimited
+	}
+	return state
+}
+
+// endpoint returns the route and state of the API key's endpoint that a
+// target endpoint is limited under. Targets naming a route exactly skip
+// pattern matching.
+func (t *limitTree) endpoint(apiKey, target string) (string, *EndpointState, bool) {
+	keyState, exists := t.apiKeys[apiKey]
+	if !exists {
+		return "", nil, false
+	}
+	if state, exists := keyState.endpoints[target]; exists {
+		return target, state, true
+	}
+	if entry := keyState.router.match(target); entry != nil {
+		return entry.route, entry.state, true
+	}
+	return "", nil, false
+}
+
+// chain returns every limit a reservation must fit, outermost first, and
+// whether the API key has the endpoint at all
+func (t *limitTree) chain(apiKey, target, clientID string) ([]Limit, bool) {
+	_, state, exists := t.endpoint(apiKey, target)
+	if !exists {
+		return nil, false
+	}
+
+	var limits []Limit
+	if global, enabled := t.global(apiKey, state.key, clientID); enabled {
+		limits = append(limits, global)
+	}
+	if keyLimits := t.apiKeys[apiKey].limits; keyLimits != nil {
+		limits = append(limits, Limit{Key: aggregateKey(apiKey), State: keyLimits, level: levelAPIKey})
+	}
+	limits = append(limits, Limit{Key: Key(apiKey, state.key), State: state, level: levelEndpoint})
+	if client := state.clientState(clientID); client != nil {
+		limits = append(limits, Limit{Key: clientKey(apiKey, state.key, clientID), State: client, level: levelClient})
+	}
+
+	return limits, true
+}
+
+// aggregateKey returns the limit key for an API key's cap across endpoints
+func aggregateKey(apiKey string) string {
+	return apiKey + "#"
+}
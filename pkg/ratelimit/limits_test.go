@@ -0,0 +1,185 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func newHierarchyLimiter() *Limiter {
+	limiter := MustNew(Config{})
+	limiter.UpdateLimits(LimitConfig{RPM: 8}, hierarchyLimits())
+	return limiter
+}
+
+func hierarchyLimits() []RateLimit {
+	return []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			RPM:    5,
+			Endpoints: []EndpointConfig{
+				{Path: "/a", RPM: 4, TPM: 100},
+				{
+					Path: "/b",
+					RPM:  4,
+					TPM:  100,
+					ClientLimits: []ClientLimit{
+						{ClientID: "limited", RPM: 1, TPM: 100},
+					},
+				},
+			},
+		},
+		{
+			APIKey: "API_KEY_2",
+			Endpoints: []EndpointConfig{
+				{Path: "/a", RPM: 10, TPM: 100},
+			},
+		},
+	}
+}
+
+func TestLimitTree_EveryLevelApplies(t *testing.T) {
+	limiter := newHierarchyLimiter()
+
+	steps := []struct {
+		name     string
+		clientID string
+		apiKey   string
+		endpoint string
+		want     bool
+	}{
+		{name: "Client quota", clientID: "limited", apiKey: "API_KEY_1", endpoint: "/b", want: true},
+		{name: "Client quota exhausted", clientID: "limited", apiKey: "API_KEY_1", endpoint: "/b", want: false},
+		{name: "Endpoint budget", clientID: "client1", apiKey: "API_KEY_1", endpoint: "/a", want: true},
+		{name: "Endpoint budget", clientID: "client1", apiKey: "API_KEY_1", endpoint: "/a", want: true},
+		{name: "Endpoint budget", clientID: "client1", apiKey: "API_KEY_1", endpoint: "/a", want: true},
+		{name: "Endpoint budget", clientID: "client1", apiKey: "API_KEY_1", endpoint: "/a", want: true},
+		{name: "Endpoint budget exhausted", clientID: "client1", apiKey: "API_KEY_1", endpoint: "/a", want: false},
+		{name: "API key cap exhausted across endpoints", clientID: "client1", apiKey: "API_KEY_1", endpoint: "/b", want: false},
+		{name: "Other API key", clientID: "client1", apiKey: "API_KEY_2", endpoint: "/a", want: true},
+		{name: "Other API key", clientID: "client1", apiKey: "API_KEY_2", endpoint: "/a", want: true},
+		{name: "Other API key", clientID: "client1", apiKey: "API_KEY_2", endpoint: "/a", want: true},
+		{name: "Global cap exhausted", clientID: "client1", apiKey: "API_KEY_2", endpoint: "/a", want: false},
+	}
+
+	for i, step := range steps {
+		reservation := limiter.Reserve(step.clientID, 1, 1, step.apiKey, step.endpoint)
+		if reservation.Allowed != step.want {
+			t.Errorf("Step %d (%s): allowed = %v, want %v", i+1, step.name, reservation.Allowed, step.want)
+		}
+	}
+}
+
+func TestLimitTree_AllOrNothing(t *testing.T) {
+	limiter := newHierarchyLimiter()
+
+	// Denied at the client level, so no ancestor is charged
+	limiter.Reserve("limited", 1, 1, "API_KEY_1", "/b")
+	for i := 0; i < 5; i++ {
+		limiter.Reserve("limited", 1, 1, "API_KEY_1", "/b")
+	}
+
+	reservation := limiter.Reserve("client1", 0, 4, "API_KEY_1", "/a")
+	if !reservation.Allowed {
+		t.Fatal("Expected denied reservations to leave ancestor budgets untouched")
+	}
+	if reservation.RemainingRequests != 0 {
+		t.Errorf("Expected 0 remaining requests, got %d", reservation.RemainingRequests)
+	}
+}
+
+func TestLimitTree_UpdatePreservesUnchangedLevels(t *testing.T) {
+	limiter := newHierarchyLimiter()
+	for i := 0; i < 3; i++ {
+		limiter.Reserve("client1", 1, 1, "API_KEY_2", "/a")
+	}
+
+	// Raising the API key cap leaves the global counters in place
+	updated := hierarchyLimits()
+	updated[0].RPM = 6
+	limiter.UpdateLimits(LimitConfig{RPM: 8}, updated)
+
+	reservation := limiter.Reserve("client1", 1, 1, "API_KEY_2", "/a")
+	if reservation.RemainingRequests != 4 {
+		t.Errorf("Expected 4 remaining requests under the global cap, got %d", reservation.RemainingRequests)
+	}
+}
+
+func TestLimitTree_PriorityUnderSharedCap(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := MustNew(Config{})
+	limiter.now = clock.Now
+	limiter.UpdateLimits(LimitConfig{RPM: 1}, []RateLimit{
+		{APIKey: "LOW_KEY", Endpoints: []EndpointConfig{{Path: "/test", RPM: 10, TPM: 100}}},
+		{APIKey: "HIGH_KEY", Endpoints: []EndpointConfig{{Path: "/test", RPM: 10, TPM: 100}}},
+	})
+	limiter.UpdatePriorities(map[string]int{"HIGH_KEY": 1, "LOW_KEY": 5}, nil)
+	defer limiter.scheduler.Close()
+
+	// Exhaust the shared global cap, then queue the low priority key first
+	limiter.Reserve("client1", 1, 1, "LOW_KEY", "/test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	granted := make(chan string, 2)
+	go func() {
+		if limiter.ReserveWait(ctx, "client1", 1, 1, "LOW_KEY", "/test").Allowed {
+			granted <- "LOW_KEY"
+		}
+	}()
+	waitForWaiters(t, limiter, "/test", 1)
+	go func() {
+		if limiter.ReserveWait(ctx, "client1", 1, 1, "HIGH_KEY", "/test").Allowed {
+			granted <- "HIGH_KEY"
+		}
+	}()
+	waitForWaiters(t, limiter, "/test", 2)
+
+	// One slot frees up and goes to the high priority key
+	clock.Advance(time.Minute)
+	if first := <-granted; first != "HIGH_KEY" {
+		t.Errorf("Expected HIGH_KEY to get capacity first, got %s", first)
+	}
+	waitForWaiters(t, limiter, "/test", 1)
+
+	clock.Advance(time.Minute)
+	if second := <-granted; second != "LOW_KEY" {
+		t.Errorf("Expected LOW_KEY to get the next slot, got %s", second)
+	}
+}
+
+func TestLimitTree_GlobalShardsSumToCap(t *testing.T) {
+	tests := []struct {
+		name    string
+		global  LimitConfig
+		wantRPM int
+		wantTPM int
+	}{
+		{name: "Even split", global: LimitConfig{RPM: 1000, TPM: 80, Shards: 8}, wantRPM: 1000, wantTPM: 80},
+		{name: "Uneven split", global: LimitConfig{RPM: 1000, TPM: 100, Shards: 8}, wantRPM: 1000, wantTPM: 100},
+		{name: "One per shard", global: LimitConfig{RPM: 7, TPM: 7, Shards: 7}, wantRPM: 7, wantTPM: 7},
+		{name: "Uncapped tokens", global: LimitConfig{RPM: 10, Shards: 3}, wantRPM: 10, wantTPM: 3 * unlimited},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			shards := newGlobalShards(tt.global, nil)
+			if len(shards) != tt.global.Shards {
+				t.Fatalf("Expected %d shards, got %d", tt.global.Shards, len(shards))
+			}
+			rpm, tpm := 0, 0
+			for _, shard := range shards {
+				rpm += shard.RPM
+				tpm += shard.TPM
+			}
+			if rpm != tt.wantRPM || tpm != tt.wantTPM {
+				t.Errorf("Expected shards to add up to %d rpm and %d tpm, got %d and %d", tt.wantRPM, tt.wantTPM, rpm, tpm)
+			}
+		})
+	}
+
+	if err := (LimitConfig{RPM: 1000, TPM: 4, Shards: 8}).validate(); err == nil {
+		t.Error("Expected more shards than a capped budget to be rejected")
+	}
+}
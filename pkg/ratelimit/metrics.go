@@ -0,0 +1,67 @@
+package ratelimit
+
+// Recorder records the limiter's reservation decisions and remaining
+// budgets, such as to export them as metrics
+type Recorder interface {
+	// Allowed counts an allowed reservation
+	Allowed(apiKey, endpoint string)
+	// Denied counts a denied reservation and the reason it was denied
+	Denied(apiKey, endpoint, reason string)
+	// Remaining records the budget left at one level of the limit tree;
+	// a negative value marks an uncapped budget
+	Remaining(level, apiKey, endpoint string, requests, tokens int)
+	// Reload forgets the remaining budgets recorded under the previous
+	// limits when the limits are replaced
+	Reload()
+}
+
+// SetMetrics sets where reservation decisions and remaining budgets are
+// recorded. It must be called before the limiter is used.
+func (rl *Limiter) SetMetrics(r Recorder) {
+	rl.metrics = r
+}
+
+// record counts a reservation decision under the route the target endpoint
+// resolved to. Denials for unknown API keys or endpoints are recorded
+// without their labels, so callers cannot create unbounded series.
+func (rl *Limiter) record(reservation *Reservation, reason, apiKey, targetEndpoint string) {
+	if rl.metrics == nil {
+		return
+	}
+	targetEndpoint = rl.Resolve(apiKey, targetEndpoint)
+	if reservation.Allowed {
+		rl.metrics.Allowed(apiKey, targetEndpoint)
+		return
+	}
+	if reason == denyUnknownKey {
+		apiKey, targetEndpoint = "", ""
+	}
+	rl.metrics.Denied(apiKey, targetEndpoint, reason)
+}
+
+// recordRemaining records the budget left at each level of a reservation's
+// path. Client quotas are left out to keep the number of series bounded,
+// as are budgets an aggregate cap leaves uncapped.
+func (rl *Limiter) recordRemaining(limits []Limit, remaining []budget, apiKey string) {
+	if rl.metrics == nil {
+		return
+	}
+	for i, limit := range limits {
+		requests, tokens := remaining[i].requests, remaining[i].tokens
+		if limit.State.RPM == unlimited {
+			requests = -1
+		}
+		if limit.State.TPM == unlimited {
+			tokens = -1
+		}
+
+		switch limit.level {
+		case levelGlobal:
+			rl.metrics.Remaining(limit.level, "", "", requests, tokens)
+		case levelAPIKey:
+			rl.metrics.Remaining(limit.level, apiKey, "", requests, tokens)
+		case levelEndpoint:
+			rl.metrics.Remaining(limit.level, apiKey, limit.State.endpoint.Route(), requests, tokens)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+package ratelimit
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/yourusername/ratelimiter/internal/metrics"
+)
+
+func TestLimiter_Metrics(t *testing.T) {
+	limiter := newHierarchyLimiter()
+	m := metrics.New()
+	limiter.SetMetrics(m)
+
+	limiter.Reserve("client1", 10, 1, "API_KEY_1", "/a")
+	limiter.Reserve("client1", 10, 1000, "API_KEY_1", "/a")
+	limiter.Reserve("client1", 10, 1, "UNKNOWN", "/a")
+
+	var buf bytes.Buffer
+	m.Registry.Write(&buf)
+	output := buf.String()
+
+	for _, line := range []string{
+		`ratelimiter_reservations_allowed_total{api_key="API_KEY_1",endpoint="/a"} 1`,
+		`ratelimiter_reservations_denied_total{api_key="API_KEY_1",endpoint="/a",reason="global_rpm"} 1`,
+		`ratelimiter_reservations_denied_total{api_key="",endpoint="",reason="unknown_key"} 1`,
+		`ratelimiter_remaining_requests{level="endpoint",api_key="API_KEY_1",endpoint="/a"}`,
+	} {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected metrics to contain %s, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestLimiter_MetricsCountQueuedReservationOnce(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newQueueLimiter(clock)
+	m := metrics.New()
+	limiter.SetMetrics(m)
+
+	limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+	limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+
+	// The queue retries the reservation several times before it times out
+	ctx, cancel := context.WithTimeout(context.Background(), 4*queuePollInterval)
+	defer cancel()
+	limiter.ReserveWait(ctx, "client1", 1, 1, "API_KEY_1", "/test")
+
+	var buf bytes.Buffer
+	m.Registry.Write(&buf)
+	if !strings.Contains(buf.String(), `ratelimiter_reservations_denied_total{api_key="API_KEY_1",endpoint="/test",reason="endpoint_rpm"} 2`) {
+		t.Errorf("Expected one denial from Reserve and one from ReserveWait, got:\n%s", buf.String())
+	}
+}
+
+func TestLimiter_MetricsDropRemovedBudgets(t *testing.T) {
+	limiter := newHierarchyLimiter()
+	m := metrics.New()
+	limiter.SetMetrics(m)
+
+	limiter.Reserve("client1", 10, 1, "API_KEY_2", "/a")
+	limiter.UpdateLimits(LimitConfig{RPM: 8}, hierarchyLimits()[:1])
+	limiter.Reserve("client1", 10, 1, "API_KEY_1", "/a")
+
+	var buf bytes.Buffer
+	m.Registry.Write(&buf)
+	output := buf.String()
+	if strings.Contains(output, `ratelimiter_remaining_requests{level="endpoint",api_key="API_KEY_2"`) {
+		t.Errorf("Expected the removed API key's budgets to be dropped, got:\n%s", output)
+	}
+	if !strings.Contains(output, `ratelimiter_remaining_requests{level="endpoint",api_key="API_KEY_1",endpoint="/a"}`) {
+		t.Errorf("Expected budgets still configured to be recorded, got:\n%s", output)
+	}
+}
@@ -0,0 +1,386 @@
+// Package middleware enforces a ratelimit.Limiter on the requests a Fiber
+// or net/http service handles, and holds the rate limit headers and error
+// bodies the service's own handlers send.
+package middleware
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+// Middleware defaults for extractors left empty in the configuration
+const (
+	defaultAPIKeyHeader = "X-API-Key"
+	bearerPrefix        = "Bearer "
+)
+
+// Request is the part of an incoming request that extractors read, for
+// both Fiber and net/http
+type Request interface {
+	Method() string
+	Path() string
+	// Route returns the pattern of the matched route, such as /users/:id,
+	// or the path where the router does not expose one
+	Route() string
+	Header(name string) string
+	// IP returns the address of the client, without a port
+	IP() string
+}
+
+// KeyExtractor reads an API key, client ID or target endpoint from a
+// request
+type KeyExtractor func(r Request) (string, error)
+
+// CostExtractor reads how many tokens or requests a request costs
+type CostExtractor func(r Request) (int, error)
+
+// Config selects what a request is limited under and what it costs. Empty
+// fields fall back to their defaults.
+type Config struct {
+	// Skip exempts requests from limiting when it returns true
+	Skip func(r Request) bool
+	// APIKey defaults to the X-API-Key header. Requests without one are
+	// rejected with 401.
+	APIKey KeyExtractor
+	// ClientID defaults to the client IP. Requests it fails on are
+	// rejected with 400.
+	ClientID KeyExtractor
+	// Endpoint defaults to the route pattern
+	Endpoint KeyExtractor
+	// Tokens defaults to none
+	Tokens CostExtractor
+	// Requests defaults to one
+	Requests CostExtractor
+}
+
+// FromHeader extracts the value of a request header
+func FromHeader(name string) KeyExtractor {
+	return func(r Request) (string, error) {
+		return r.Header(name), nil
+	}
+}
+
+// FromIP extracts the client IP
+func FromIP() KeyExtractor {
+	return func(r Request) (string, error) {
+		return r.IP(), nil
+	}
+}
+
+// FromRoute extracts the pattern of the matched route, so every path it
+// matches shares one endpoint's limits
+func FromRoute() KeyExtractor {
+	return func(r Request) (string, error) {
+		return r.Route(), nil
+	}
+}
+
+// WithMethod prefixes what extractor extracts with the request method, as
+// in "GET /users/:id", so endpoints limited to some methods can match
+func WithMethod(extractor KeyExtractor) KeyExtractor {
+	return func(r Request) (string, error) {
+		value, err := extractor(r)
+		if err != nil || value == "" {
+			return value, err
+		}
+		return r.Method() + " " + value, nil
+	}
+}
+
+// FromJWTClaim extracts a claim from the bearer token in the Authorization
+// header. The token's signature is not verified, so the middleware must run
+// after whatever authenticates requests.
+func FromJWTClaim(claim string) KeyExtractor {
+	return func(r Request) (string, error) {
+		header := r.Header("Authorization")
+		if !strings.HasPrefix(header, bearerPrefix) {
+			return "", nil
+		}
+
+		parts := strings.Split(strings.TrimPrefix(header, bearerPrefix), ".")
+		if len(parts) != 3 {
+			return "", errors.New("middleware: malformed bearer token")
+		}
+		payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+		if err != nil {
+			return "", fmt.Errorf("middleware: malformed bearer token: %v", err)
+		}
+		var claims map[string]interface{}
+		if err := json.Unmarshal(payload, &claims); err != nil {
+			return "", fmt.Errorf("middleware: malformed bearer token: %v", err)
+		}
+
+		switch value := claims[claim].(type) {
+		case nil:
+			return "", nil
+		case string:
+			return value, nil
+		case float64:
+			return strconv.FormatFloat(value, 'f', -1, 64), nil
+		default:
+			return "", fmt.Errorf("middleware: claim %s is not a string or number", claim)
+		}
+	}
+}
+
+// Static extracts the same value from every request, such as the one API
+// key a service limits itself under
+func Static(value string) KeyExtractor {
+	return func(r Request) (string, error) {
+		return value, nil
+	}
+}
+
+// CostFromHeader extracts a non-negative integer cost from a request
+// header, or zero if the header is missing
+func CostFromHeader(name string) CostExtractor {
+	return func(r Request) (int, error) {
+		value := r.Header(name)
+		if value == "" {
+			return 0, nil
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%s header must be a non-negative integer", name)
+		}
+		return n, nil
+	}
+}
+
+// FixedCost charges every request the same cost
+func FixedCost(n int) CostExtractor {
+	return func(r Request) (int, error) {
+		return n, nil
+	}
+}
+
+// middleware holds the limiter and extractors shared by the Fiber and
+// net/http middleware
+type middleware struct {
+	limiter *ratelimit.Limiter
+	cfg     Config
+}
+
+// newMiddleware fills in the defaults of cfg
+func newMiddleware(limiter *ratelimit.Limiter, cfg Config) *middleware {
+	if cfg.APIKey == nil {
+		cfg.APIKey = FromHeader(defaultAPIKeyHeader)
+	}
+	if cfg.ClientID == nil {
+		cfg.ClientID = FromIP()
+	}
+	if cfg.Endpoint == nil {
+		cfg.Endpoint = FromRoute()
+	}
+	if cfg.Tokens == nil {
+		cfg.Tokens = FixedCost(0)
+	}
+	if cfg.Requests == nil {
+		cfg.Requests = FixedCost(1)
+	}
+	return &middleware{limiter: limiter, cfg: cfg}
+}
+
+// rejection is a response the middleware sends instead of passing a
+// request on
+type rejection struct {
+	status  int
+	message string
+}
+
+// ErrorResponse is the body of error responses, from the middleware and
+// from the service alike
+type ErrorResponse struct {
+	Status struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+	Error string `json:"error"`
+}
+
+// NewErrorResponse creates the error response for a status and message
+func NewErrorResponse(status int, message string) ErrorResponse {
+	response := ErrorResponse{}
+	response.Status.Code = status
+	response.Status.Message = "Error"
+	response.Error = message
+	return response
+}
+
+// body returns the JSON body of the rejection
+func (r *rejection) body() []byte {
+	body, _ := json.Marshal(NewErrorResponse(r.status, r.message))
+	return body
+}
+
+// reserve extracts what a request is limited under and reserves its cost.
+// It returns the reservation, which is nil for skipped requests, and the
+// rejection to send if the request may not pass.
+func (m *middleware) reserve(r Request) (*ratelimit.Reservation, *rejection) {
+	if m.cfg.Skip != nil && m.cfg.Skip(r) {
+		return nil, nil
+	}
+
+	apiKey, err := m.cfg.APIKey(r)
+	if err != nil {
+		return nil, &rejection{status: http.StatusUnauthorized, message: err.Error()}
+	}
+	if apiKey == "" {
+		return nil, &rejection{status: http.StatusUnauthorized, message: "API key is required"}
+	}
+	clientID, err := m.cfg.ClientID(r)
+	if err != nil {
+		return nil, &rejection{status: http.StatusBadRequest, message: err.Error()}
+	}
+	endpoint, err := m.cfg.Endpoint(r)
+	if err != nil {
+		return nil, &rejection{status: http.StatusBadRequest, message: err.Error()}
+	}
+	tokens, err := m.cfg.Tokens(r)
+	if err != nil {
+		return nil, &rejection{status: http.StatusBadRequest, message: err.Error()}
+	}
+	requests, err := m.cfg.Requests(r)
+	if err != nil {
+		return nil, &rejection{status: http.StatusBadRequest, message: err.Error()}
+	}
+
+	reservation := m.limiter.Reserve(clientID, tokens, requests, apiKey, endpoint)
+	if reservation.UnknownKey {
+		return reservation, &rejection{status: http.StatusForbidden, message: "Unknown API key or endpoint"}
+	}
+	if !reservation.Allowed {
+		return reservation, &rejection{status: http.StatusTooManyRequests, message: "Rate limit exceeded"}
+	}
+	return reservation, nil
+}
+
+// finish commits a pending reservation once the request has been handled,
+// even if the handler panicked, so it is not refunded when its TTL passes
+func (m *middleware) finish(reservation *ratelimit.Reservation) {
+	if reservation == nil || reservation.ReservationID == "" {
+		return
+	}
+	if _, err := m.limiter.Commit(reservation.ReservationID, reservation.ReservedTokens); err != nil {
+		log.Printf("Error committing reservation %s: %v", reservation.ReservationID, err)
+	}
+}
+
+// SetHeaders sets the RateLimit-Limit, RateLimit-Remaining and
+// RateLimit-Reset headers for the request budget a reservation was checked
+// against, and Retry-After on denials that can succeed later
+func SetHeaders(reservation *ratelimit.Reservation, set func(key, value string)) {
+	if reservation.RequestLimit > 0 {
+		remaining := reservation.RemainingRequests
+		if remaining < 0 {
+			remaining = 0
+		}
+		set("RateLimit-Limit", strconv.Itoa(reservation.RequestLimit))
+		set("RateLimit-Remaining", strconv.Itoa(remaining))
+		set("RateLimit-Reset", strconv.FormatInt(Seconds(reservation.ResetMs), 10))
+	}
+	if !reservation.Allowed && reservation.RetryAfterMs > 0 {
+		set("Retry-After", strconv.FormatInt(Seconds(reservation.RetryAfterMs), 10))
+	}
+}
+
+// Seconds converts milliseconds to whole seconds, rounding up, as the
+// RateLimit-Reset and Retry-After headers carry them
+func Seconds(ms int64) int64 {
+	return (ms + 999) / 1000
+}
+
+// New returns Fiber middleware that reserves each request's cost before
+// passing it on, and rejects it with 429 and the standard rate limit
+// headers if the limits are exhausted. Register it on a route, as in
+// app.Get("/users/:id", mw, handler), for FromRoute to see the pattern;
+// under app.Use the route is not matched yet and FromRoute sees the path.
+func New(limiter *ratelimit.Limiter, cfg Config) fiber.Handler {
+	m := newMiddleware(limiter, cfg)
+
+	return func(c *fiber.Ctx) error {
+		reservation, reject := m.reserve(fiberRequest{c})
+		if reservation != nil {
+			SetHeaders(reservation, c.Set)
+		}
+		if reject != nil {
+			c.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+			return c.Status(reject.status).Send(reject.body())
+		}
+
+		defer m.finish(reservation)
+		return c.Next()
+	}
+}
+
+// NewHTTP returns net/http middleware that reserves each request's cost
+// before passing it on, and rejects it with 429 and the standard rate
+// limit headers if the limits are exhausted. net/http does not expose
+// route patterns, so FromRoute sees the path.
+func NewHTTP(limiter *ratelimit.Limiter, cfg Config) func(http.Handler) http.Handler {
+	m := newMiddleware(limiter, cfg)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			reservation, reject := m.reserve(httpRequest{r})
+			if reservation != nil {
+				SetHeaders(reservation, w.Header().Set)
+			}
+			if reject != nil {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(reject.status)
+				w.Write(reject.body())
+				return
+			}
+
+			defer m.finish(reservation)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// fiberRequest reads a Fiber request
+type fiberRequest struct {
+	c *fiber.Ctx
+}
+
+func (r fiberRequest) Method() string            { return r.c.Method() }
+func (r fiberRequest) Path() string              { return r.c.Path() }
+func (r fiberRequest) Header(name string) string { return r.c.Get(name) }
+func (r fiberRequest) IP() string                { return r.c.IP() }
+
+func (r fiberRequest) Route() string {
+	// Middleware registered with Use runs before the route is matched
+	if route := r.c.Route(); route.Method != "USE" {
+		return route.Path
+	}
+	return r.c.Path()
+}
+
+// httpRequest reads a net/http request
+type httpRequest struct {
+	r *http.Request
+}
+
+func (r httpRequest) Method() string            { return r.r.Method }
+func (r httpRequest) Path() string              { return r.r.URL.Path }
+func (r httpRequest) Route() string             { return r.r.URL.Path }
+func (r httpRequest) Header(name string) string { return r.r.Header.Get(name) }
+
+func (r httpRequest) IP() string {
+	host, _, err := net.SplitHostPort(r.r.RemoteAddr)
+	if err != nil {
+		return r.r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,190 @@
+package middleware
+
+import (
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/yourusername/ratelimiter/pkg/ratelimit"
+)
+
+func newMiddlewareLimiter() *ratelimit.Limiter {
+	return ratelimit.MustNew(ratelimit.Config{RateLimits: []ratelimit.RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []ratelimit.EndpointConfig{
+				{Path: "/users/:id", RPM: 2, TPM: 100},
+			},
+		},
+	}})
+}
+
+// bearer returns an unsigned bearer token carrying payload as its claims
+func bearer(payload string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return "Bearer " + encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(payload)) + "."
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name       string
+		cfg        Config
+		headers    map[string]string
+		wantStatus []int
+	}{
+		{
+			name:       "API key header",
+			headers:    map[string]string{"X-API-Key": "API_KEY_1"},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Method and route",
+			cfg:        Config{Endpoint: WithMethod(FromRoute())},
+			headers:    map[string]string{"X-API-Key": "API_KEY_1"},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Missing API key",
+			wantStatus: []int{http.StatusUnauthorized},
+		},
+		{
+			name:       "Unknown API key",
+			headers:    map[string]string{"X-API-Key": "API_KEY_9"},
+			wantStatus: []int{http.StatusForbidden},
+		},
+		{
+			name:       "JWT claim",
+			cfg:        Config{APIKey: FromJWTClaim("tenant")},
+			headers:    map[string]string{"Authorization": bearer(`{"tenant":"API_KEY_1"}`)},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Malformed JWT",
+			cfg:        Config{APIKey: FromJWTClaim("tenant")},
+			headers:    map[string]string{"Authorization": "Bearer garbage"},
+			wantStatus: []int{http.StatusUnauthorized},
+		},
+		{
+			name:       "Malformed client ID claim",
+			cfg:        Config{APIKey: Static("API_KEY_1"), ClientID: FromJWTClaim("sub")},
+			headers:    map[string]string{"Authorization": "Bearer garbage"},
+			wantStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:       "Token cost header",
+			cfg:        Config{APIKey: Static("API_KEY_1"), Tokens: CostFromHeader("X-Tokens")},
+			headers:    map[string]string{"X-Tokens": "60"},
+			wantStatus: []int{http.StatusOK, http.StatusTooManyRequests},
+		},
+		{
+			name:       "Invalid token cost",
+			cfg:        Config{APIKey: Static("API_KEY_1"), Tokens: CostFromHeader("X-Tokens")},
+			headers:    map[string]string{"X-Tokens": "many"},
+			wantStatus: []int{http.StatusBadRequest},
+		},
+		{
+			name:       "Skipped",
+			cfg:        Config{Skip: func(r Request) bool { return r.Method() == http.MethodGet }},
+			wantStatus: []int{http.StatusOK, http.StatusOK, http.StatusOK},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Fiber sees the route pattern, so different users share the
+			// limit of /users/:id
+			app := fiber.New()
+			app.Get("/users/:id", New(newMiddlewareLimiter(), tt.cfg), func(c *fiber.Ctx) error {
+				return c.SendString("ok")
+			})
+
+			for i, want := range tt.wantStatus {
+				req := httptest.NewRequest("GET", "/users/"+string(rune('a'+i)), nil)
+				for key, value := range tt.headers {
+					req.Header.Set(key, value)
+				}
+				resp, err := app.Test(req)
+				if err != nil {
+					t.Fatalf("Failed to test request: %v", err)
+				}
+				if resp.StatusCode != want {
+					t.Fatalf("Request %d: expected status %d, got %d", i, want, resp.StatusCode)
+				}
+				if want == http.StatusTooManyRequests && (resp.Header.Get("Retry-After") == "" || resp.Header.Get("RateLimit-Limit") == "") {
+					t.Errorf("Expected Retry-After and RateLimit headers on 429, got %v", resp.Header)
+				}
+			}
+		})
+	}
+}
+
+func TestNewHTTP(t *testing.T) {
+	limiter := newMiddlewareLimiter()
+	limiter.SetReservationTTL(20 * time.Millisecond)
+
+	handler := NewHTTP(limiter, Config{
+		APIKey:   Static("API_KEY_1"),
+		Endpoint: Static("/users/:id"),
+		ClientID: FromIP(),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	wantStatus := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i, want := range wantStatus {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/1", nil))
+		if rec.Code != want {
+			t.Fatalf("Request %d: expected status %d, got %d", i, want, rec.Code)
+		}
+		if rec.Header().Get("RateLimit-Remaining") == "" {
+			t.Errorf("Request %d: expected RateLimit headers, got %v", i, rec.Header())
+		}
+	}
+
+	// Handled requests are committed, so they stay charged past the TTL
+	time.Sleep(50 * time.Millisecond)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/1", nil))
+	if rec.Code != http.StatusTooManyRequests {
+		t.Errorf("Expected handled requests to stay charged, got status %d", rec.Code)
+	}
+}
+
+func TestNewHTTP_CommitsOnPanic(t *testing.T) {
+	limiter := newMiddlewareLimiter()
+	limiter.SetReservationTTL(20 * time.Millisecond)
+
+	handler := NewHTTP(limiter, Config{
+		APIKey:   Static("API_KEY_1"),
+		Endpoint: Static("/users/:id"),
+	})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failed")
+	}))
+	serve := func() (code int) {
+		defer func() {
+			if recover() != nil {
+				code = http.StatusInternalServerError
+			}
+		}()
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/users/1", nil))
+		return rec.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		if code := serve(); code != http.StatusInternalServerError {
+			t.Fatalf("Request %d: expected the handler to panic, got status %d", i, code)
+		}
+	}
+
+	// Requests whose handler panicked were committed, so they stay charged
+	// past the TTL
+	time.Sleep(50 * time.Millisecond)
+	if code := serve(); code != http.StatusTooManyRequests {
+		t.Errorf("Expected panicked requests to stay charged, got status %d", code)
+	}
+}
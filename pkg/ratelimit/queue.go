@@ -0,0 +1,399 @@
+package ratelimit
+
+import (
+	"container/heap"
+	"context"
+	"errors"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// queuePollInterval is how often waiting reservations retry for capacity
+const queuePollInterval = 25 * time.Millisecond
+
+// lowestPriority is the class of API keys without a priority class
+const lowestPriority = math.MaxInt32
+
+// waiter is a reservation waiting for capacity
+type waiter struct {
+	class          int
+	seq            uint64
+	clientID       string
+	tokens         int
+	requests       int
+	apiKey         string
+	targetEndpoint string
+	denial         *Reservation
+	reason         string
+	result         chan *Reservation
+	index          int
+	// granting is set while the queue tries the waiter against the store,
+	// and cancelled once its caller stopped waiting in the meantime
+	granting  bool
+	cancelled bool
+}
+
+// waitHeap orders waiters by priority class, then arrival
+type waitHeap []*waiter
+
+func (h waitHeap) Len() int { return len(h) }
+
+func (h waitHeap) Less(i, j int) bool {
+	if h[i].class != h[j].class {
+		return h[i].class < h[j].class
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h waitHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+	h[i].index = i
+	h[j].index = j
+}
+
+func (h *waitHeap) Push(x interface{}) {
+	w := x.(*waiter)
+	w.index = len(*h)
+	*h = append(*h, w)
+}
+
+func (h *waitHeap) Pop() interface{} {
+	old := *h
+	w := old[len(old)-1]
+	old[len(old)-1] = nil
+	w.index = -1
+	*h = old[:len(old)-1]
+	return w
+}
+
+// ErrExceedsLimit is returned by Wait for reservations larger than one of
+// the budgets they must fit
+var ErrExceedsLimit = errors.New("ratelimit: reservation exceeds limit")
+
+// waitQueue holds the reservations waiting on one endpoint. Classes share
+// freed capacity in proportion to their weights: each class has a virtual
+// time that grows by a granted reservation's requests over the class's
+// weight, and the class furthest behind is served next.
+type waitQueue struct {
+	waiters waitHeap
+	seq     uint64
+	running bool
+	// vtime holds each class's virtual time, and vclock the virtual time of
+	// the last grant, which classes that fell behind catch up to
+	vtime  map[int]float64
+	vclock float64
+	mutex  sync.Mutex
+}
+
+// ReserveWait reserves capacity like Reserve, but if the reservation would
+// be denied it waits in the endpoint's queue until capacity frees up or ctx
+// is done. Priority classes share freed capacity by the weights of their
+// dispatch policies, with ties going to the higher priority class. Within
+// a class, waiters are granted in order of arrival, and a waiter that does
+// not fit holds back every later waiter needing a limit it is short of, so
+// small reservations cannot starve it. New reservations queue behind
+// existing waiters. Reservations larger than one of the budgets they must
+// fit are denied right away instead of holding up the queue.
+func (rl *Limiter) ReserveWait(ctx context.Context, clientID string, tokens, requests int, apiKey, targetEndpoint string) *Reservation {
+	reservation, reason := rl.reserveWait(ctx, clientID, tokens, requests, apiKey, targetEndpoint)
+	rl.record(reservation, reason, apiKey, targetEndpoint)
+	return reservation
+}
+
+// Wait reserves capacity like ReserveWait, returning ctx's error if ctx is
+// done before the reservation is allowed. It fails right away with
+// ErrUnknownKey for unknown API keys and endpoints, and with
+// ErrExceedsLimit for reservations that could never be allowed.
+func (rl *Limiter) Wait(ctx context.Context, clientID string, tokens, requests int, apiKey, targetEndpoint string) (*Reservation, error) {
+	rl.mutex.RLock()
+	limits, exists := rl.limits.chain(apiKey, targetEndpoint, clientID)
+	rl.mutex.RUnlock()
+
+	if exists && exceededLimit(limits, tokens, requests) != "" {
+		return nil, ErrExceedsLimit
+	}
+
+	reservation, reason := rl.reserveWait(ctx, clientID, tokens, requests, apiKey, targetEndpoint)
+	rl.record(reservation, reason, apiKey, targetEndpoint)
+	if reservation.Allowed {
+		return reservation, nil
+	}
+	if reason == denyUnknownKey {
+		return nil, ErrUnknownKey
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	// Denied without waiting: the limits shrank below the reservation
+	return nil, ErrExceedsLimit
+}
+
+// reserveWait queues a reservation without recording metrics, returning
+// why it was last denied if it never got capacity
+func (rl *Limiter) reserveWait(ctx context.Context, clientID string, tokens, requests int, apiKey, targetEndpoint string) (*Reservation, string) {
+	rl.mutex.RLock()
+	route, _, exists := rl.limits.endpoint(apiKey, targetEndpoint)
+	limits, _ := rl.limits.chain(apiKey, targetEndpoint, clientID)
+	rl.mutex.RUnlock()
+	if !exists {
+		return &Reservation{
+			Allowed:    false,
+			UnknownKey: true,
+		}, denyUnknownKey
+	}
+	if exceededLimit(limits, tokens, requests) != "" {
+		// Waiting cannot help, and would hold up every smaller waiter
+		return rl.reserve(clientID, tokens, requests, apiKey, targetEndpoint)
+	}
+
+	queue := rl.waitQueue(route)
+
+	denial, denialReason := &Reservation{Allowed: false}, ""
+	queue.mutex.Lock()
+	if queue.waiters.Len() == 0 {
+		queue.mutex.Unlock()
+		reservation, reason := rl.reserve(clientID, tokens, requests, apiKey, targetEndpoint)
+		if reservation.Allowed {
+			return reservation, ""
+		}
+		denial, denialReason = reservation, reason
+		queue.mutex.Lock()
+	}
+
+	class, ok := rl.scheduler.Class(apiKey)
+	if !ok {
+		class = lowestPriority
+	}
+	queue.seq++
+	w := &waiter{
+		class:          class,
+		seq:            queue.seq,
+		clientID:       clientID,
+		tokens:         tokens,
+		requests:       requests,
+		apiKey:         apiKey,
+		targetEndpoint: targetEndpoint,
+		denial:         denial,
+		reason:         denialReason,
+		result:         make(chan *Reservation, 1),
+	}
+	heap.Push(&queue.waiters, w)
+	if !queue.running {
+		queue.running = true
+		go rl.drainQueue(queue)
+	}
+	queue.mutex.Unlock()
+
+	select {
+	case reservation := <-w.result:
+		return reservation, ""
+	case <-ctx.Done():
+	}
+
+	queue.mutex.Lock()
+	if w.index >= 0 && !w.granting {
+		heap.Remove(&queue.waiters, w.index)
+		queue.mutex.Unlock()
+		return w.denial, w.reason
+	}
+	// Granted, or being tried against the store, while the deadline
+	// expired: the queue settles the waiter either way
+	w.cancelled = true
+	queue.mutex.Unlock()
+
+	reservation := <-w.result
+	if reservation.Allowed {
+		return reservation, ""
+	}
+	return reservation, w.reason
+}
+
+// waitQueue returns the queue for an endpoint, creating it on first use
+func (rl *Limiter) waitQueue(targetEndpoint string) *waitQueue {
+	rl.queueMutex.Lock()
+	defer rl.queueMutex.Unlock()
+
+	queue, exists := rl.queues[targetEndpoint]
+	if !exists {
+		queue = &waitQueue{vtime: make(map[int]float64)}
+		rl.queues[targetEndpoint] = queue
+	}
+	return queue
+}
+
+// drainQueue retries waiters until the queue is empty
+func (rl *Limiter) drainQueue(queue *waitQueue) {
+	for {
+		queue.mutex.Lock()
+		if queue.waiters.Len() == 0 {
+			queue.running = false
+			queue.vtime = make(map[int]float64)
+			queue.vclock = 0
+			queue.mutex.Unlock()
+			return
+		}
+
+		rl.grantWaiters(queue)
+		queue.mutex.Unlock()
+
+		time.Sleep(queuePollInterval)
+	}
+}
+
+// grantWaiters makes one pass over the queue, granting waiters by weighted
+// fair share until every class is held up by a waiter that does not fit.
+// The caller must hold the queue's mutex, which is released while each
+// waiter is tried against the store so new waiters can queue meanwhile;
+// they are considered on the next pass.
+func (rl *Limiter) grantWaiters(queue *waitQueue) {
+	classes := make(map[int][]*waiter)
+	for _, w := range queue.waiters {
+		classes[w.class] = append(classes[w.class], w)
+	}
+	for class, waiters := range classes {
+		sort.Slice(waiters, func(i, j int) bool { return waiters[i].seq < waiters[j].seq })
+		if queue.vtime[class] < queue.vclock {
+			queue.vtime[class] = queue.vclock
+		}
+	}
+
+	// short holds the keys of limits a held up waiter does not fit, which
+	// later waiters may not take capacity from
+	short := make(map[string]bool)
+	held := make(map[int]bool)
+	for {
+		class, ok := nextClass(classes, held, queue.vtime)
+		if !ok {
+			break
+		}
+
+		w := classes[class][0]
+		if w.index < 0 {
+			// Gave up waiting since the pass began
+			classes[class] = classes[class][1:]
+			continue
+		}
+
+		w.granting = true
+		queue.mutex.Unlock()
+		keys, blocked, reservation, reason := rl.tryWaiter(w, short)
+		queue.mutex.Lock()
+		w.granting = false
+
+		if reservation != nil {
+			if reservation.Allowed {
+				heap.Remove(&queue.waiters, w.index)
+				w.result <- reservation
+				classes[class] = classes[class][1:]
+				queue.vclock = queue.vtime[class]
+				queue.vtime[class] += float64(cost(w)) / float64(rl.scheduler.Weight(class))
+				continue
+			}
+			w.denial, w.reason = reservation, reason
+		}
+		if w.cancelled {
+			heap.Remove(&queue.waiters, w.index)
+			w.result <- w.denial
+			classes[class] = classes[class][1:]
+			continue
+		}
+		if blocked {
+			held[class] = true
+			continue
+		}
+
+		// Head of line: the class waits for this waiter, and so does any
+		// waiter needing a limit it is short of
+		for _, key := range keys {
+			short[key] = true
+		}
+		held[class] = true
+	}
+}
+
+// tryWaiter reserves capacity for a waiter unless it is short of a limit
+// or needs a limit held for an earlier waiter, which it reports as
+// blocked. It returns the keys from shortLimits, and the reservation if
+// one was attempted. The caller must not hold the queue's mutex, as this
+// reads and updates the store.
+func (rl *Limiter) tryWaiter(w *waiter, short map[string]bool) ([]string, bool, *Reservation, string) {
+	keys, fits := rl.shortLimits(w)
+	if blocks(keys, short) {
+		return keys, true, nil, ""
+	}
+	if !fits {
+		return keys, false, nil, ""
+	}
+
+	reservation, reason := rl.reserve(w.clientID, w.tokens, w.requests, w.apiKey, w.targetEndpoint)
+	if !reservation.Allowed {
+		keys, _ = rl.shortLimits(w)
+	}
+	return keys, false, reservation, reason
+}
+
+// nextClass returns the class with waiters left and not held up that is
+// furthest behind, preferring higher priority classes on ties
+func nextClass(classes map[int][]*waiter, held map[int]bool, vtime map[int]float64) (int, bool) {
+	next, found := 0, false
+	for class, waiters := range classes {
+		if len(waiters) == 0 || held[class] {
+			continue
+		}
+		if !found || vtime[class] < vtime[next] || (vtime[class] == vtime[next] && class < next) {
+			next, found = class, true
+		}
+	}
+	return next, found
+}
+
+// shortLimits returns the keys of the limits on a waiter's path and
+// whether it fits all of them right now. If it does not, only the limits
+// it is short of are returned.
+func (rl *Limiter) shortLimits(w *waiter) ([]string, bool) {
+	rl.mutex.RLock()
+	limits, exists := rl.limits.chain(w.apiKey, w.targetEndpoint, w.clientID)
+	rl.mutex.RUnlock()
+	if !exists {
+		return nil, true
+	}
+
+	var short []string
+	err := rl.store.View(limits, func(counters []*Counters) {
+		now := rl.now()
+		for i, limit := range limits {
+			if counters[i].Requests.Remaining(now) < w.requests || counters[i].Tokens.Remaining(now) < w.tokens {
+				short = append(short, limit.Key)
+			}
+		}
+	})
+	if err != nil || len(short) > 0 {
+		return short, false
+	}
+
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = limit.Key
+	}
+	return keys, true
+}
+
+// blocks reports whether any of keys is held for an earlier waiter
+func blocks(keys []string, short map[string]bool) bool {
+	for _, key := range keys {
+		if short[key] {
+			return true
+		}
+	}
+	return false
+}
+
+// cost returns what granting a waiter counts against its class's share
+func cost(w *waiter) int {
+	if w.requests < 1 {
+		return 1
+	}
+	return w.requests
+}
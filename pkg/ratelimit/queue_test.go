@@ -0,0 +1,362 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock is a manually advanced clock safe for concurrent use
+type testClock struct {
+	now   time.Time
+	mutex sync.Mutex
+}
+
+func (c *testClock) Now() time.Time {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.now
+}
+
+func (c *testClock) Advance(d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newQueueLimiter(clock *testClock) *Limiter {
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{Path: "/test", RPM: 1, TPM: 100},
+			},
+		},
+	}})
+	limiter.now = clock.Now
+	return limiter
+}
+
+// waitForWaiters blocks until n reservations are queued on endpoint
+func waitForWaiters(t *testing.T, limiter *Limiter, endpoint string, n int) {
+	queue := limiter.waitQueue(endpoint)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		queue.mutex.Lock()
+		queued := queue.waiters.Len()
+		queue.mutex.Unlock()
+		if queued == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d queued reservations, got %d", n, queued)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestLimiter_ReserveWait(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newQueueLimiter(clock)
+
+	// Capacity available: granted without waiting
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	if !limiter.ReserveWait(ctx, "client1", 1, 1, "API_KEY_1", "/test").Allowed {
+		t.Fatal("Expected the first reservation to be allowed")
+	}
+
+	// Budget exhausted: granted once the window resets
+	result := make(chan *Reservation)
+	go func() {
+		result <- limiter.ReserveWait(ctx, "client1", 1, 1, "API_KEY_1", "/test")
+	}()
+	waitForWaiters(t, limiter, "/test", 1)
+	clock.Advance(time.Minute)
+
+	if !(<-result).Allowed {
+		t.Error("Expected the queued reservation to be granted once capacity freed up")
+	}
+
+	// Deadline passes before capacity frees up
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	reservation := limiter.ReserveWait(short, "client1", 1, 1, "API_KEY_1", "/test")
+	if reservation.Allowed {
+		t.Error("Expected the reservation to be denied after its deadline")
+	}
+	waitForWaiters(t, limiter, "/test", 0)
+}
+
+func TestLimiter_ReserveWaitPriorityOrder(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "LOW_KEY",
+			Endpoints: []EndpointConfig{
+				{Path: "/test", RPM: 1, TPM: 100},
+			},
+		},
+		{
+			APIKey: "HIGH_KEY",
+			Endpoints: []EndpointConfig{
+				{Path: "/test", RPM: 1, TPM: 100},
+			},
+		},
+	}})
+	limiter.now = clock.Now
+	limiter.UpdatePriorities(map[string]int{"HIGH_KEY": 1, "LOW_KEY": 5}, nil)
+	defer limiter.scheduler.Close()
+
+	// Exhaust both keys, then queue the low priority key first
+	limiter.Reserve("client1", 1, 1, "LOW_KEY", "/test")
+	limiter.Reserve("client1", 1, 1, "HIGH_KEY", "/test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	// Each key has its own budget, so both are granted once capacity frees
+	// up, but the high priority key is retried first
+	order := make(chan string, 2)
+	go func() {
+		limiter.ReserveWait(ctx, "client1", 1, 1, "LOW_KEY", "/test")
+		order <- "LOW_KEY"
+	}()
+	waitForWaiters(t, limiter, "/test", 1)
+	go func() {
+		limiter.ReserveWait(ctx, "client1", 1, 1, "HIGH_KEY", "/test")
+		order <- "HIGH_KEY"
+	}()
+	waitForWaiters(t, limiter, "/test", 2)
+
+	queue := limiter.waitQueue("/test")
+	queue.mutex.Lock()
+	head := queue.waiters[0].apiKey
+	queue.mutex.Unlock()
+	if head != "HIGH_KEY" {
+		t.Errorf("Expected HIGH_KEY at the head of the queue, got %s", head)
+	}
+
+	clock.Advance(time.Minute)
+	<-order
+	<-order
+}
+
+func TestLimiter_ReserveWaitUnknownKey(t *testing.T) {
+	limiter := newQueueLimiter(&testClock{now: time.Now()})
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	start := time.Now()
+	if limiter.ReserveWait(ctx, "client1", 1, 1, "INVALID_KEY", "/test").Allowed {
+		t.Error("Expected an unknown key to be denied")
+	}
+	if time.Since(start) > 100*time.Millisecond {
+		t.Error("Expected an unknown key to be denied without waiting")
+	}
+}
+
+func TestLimiter_Wait(t *testing.T) {
+	limiter := newQueueLimiter(&testClock{now: time.Now()})
+
+	tests := []struct {
+		name        string
+		apiKey      string
+		tokens      int
+		requests    int
+		wantAllowed bool
+		wantErr     error
+	}{
+		{name: "Capacity available", apiKey: "API_KEY_1", tokens: 1, requests: 1, wantAllowed: true},
+		{name: "Deadline passes while waiting", apiKey: "API_KEY_1", tokens: 1, requests: 1, wantErr: context.DeadlineExceeded},
+		{name: "Unknown key", apiKey: "INVALID_KEY", tokens: 1, requests: 1, wantErr: ErrUnknownKey},
+		{name: "Larger than the budget", apiKey: "API_KEY_1", tokens: 101, requests: 1, wantErr: ErrExceedsLimit},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+			defer cancel()
+
+			reservation, err := limiter.Wait(ctx, "client1", tt.tokens, tt.requests, tt.apiKey, "/test")
+			if err != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if tt.wantAllowed && (reservation == nil || !reservation.Allowed) {
+				t.Errorf("Expected an allowed reservation, got %+v", reservation)
+			}
+		})
+	}
+}
+
+// newSharedQueueLimiter returns a limiter whose keys share a global token
+// bucket, with HIGH_KEY in a higher priority class than LOW_KEY
+func newSharedQueueLimiter(clock *testClock, global LimitConfig, policies map[int]DispatchPolicy) *Limiter {
+	limiter := MustNew(Config{
+		GlobalLimit: global,
+		RateLimits: []RateLimit{
+			{APIKey: "HIGH_KEY", Endpoints: []EndpointConfig{{Path: "/test", RPM: 1000, TPM: 1000}}},
+			{APIKey: "LOW_KEY", Endpoints: []EndpointConfig{{Path: "/test", RPM: 1000, TPM: 1000}}},
+		},
+	})
+	limiter.now = clock.Now
+	limiter.UpdatePriorities(map[string]int{"HIGH_KEY": 1, "LOW_KEY": 5}, policies)
+	return limiter
+}
+
+func TestLimiter_ReserveWaitHeadOfLine(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newSharedQueueLimiter(clock, LimitConfig{TPM: 60, Algorithm: AlgorithmTokenBucket}, nil)
+	defer limiter.scheduler.Close()
+
+	// Drain the global bucket, which refills a token a second
+	limiter.Reserve("client1", 60, 1, "LOW_KEY", "/test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	order := make(chan string, 2)
+	go func() {
+		if limiter.ReserveWait(ctx, "client1", 10, 1, "HIGH_KEY", "/test").Allowed {
+			order <- "HIGH_KEY"
+		}
+	}()
+	waitForWaiters(t, limiter, "/test", 1)
+	go func() {
+		if limiter.ReserveWait(ctx, "client1", 1, 1, "LOW_KEY", "/test").Allowed {
+			order <- "LOW_KEY"
+		}
+	}()
+	waitForWaiters(t, limiter, "/test", 2)
+
+	// Tokens trickling in would fit the small reservation, but it may not
+	// take them from the blocked high priority one
+	clock.Advance(5 * time.Second)
+	time.Sleep(4 * queuePollInterval)
+	waitForWaiters(t, limiter, "/test", 2)
+
+	clock.Advance(5 * time.Second)
+	waitForWaiters(t, limiter, "/test", 1)
+	clock.Advance(time.Second)
+	waitForWaiters(t, limiter, "/test", 0)
+
+	if first, second := <-order, <-order; first != "HIGH_KEY" || second != "LOW_KEY" {
+		t.Errorf("Expected HIGH_KEY then LOW_KEY, got %s then %s", first, second)
+	}
+}
+
+func TestLimiter_ReserveWaitWeightedShare(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newSharedQueueLimiter(clock, LimitConfig{RPM: 60, Algorithm: AlgorithmTokenBucket}, map[int]DispatchPolicy{
+		1: {Weight: 3},
+		5: {Weight: 1},
+	})
+	defer limiter.scheduler.Close()
+
+	// Drain the global bucket, which refills a request a second
+	limiter.Reserve("client1", 0, 60, "LOW_KEY", "/test")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	const perClass = 8
+	order := make(chan string, 2*perClass)
+	for i := 0; i < 2*perClass; i++ {
+		apiKey := "HIGH_KEY"
+		if i%2 == 1 {
+			apiKey = "LOW_KEY"
+		}
+		go func() {
+			if limiter.ReserveWait(ctx, "client1", 0, 1, apiKey, "/test").Allowed {
+				order <- apiKey
+			}
+		}()
+		waitForWaiters(t, limiter, "/test", i+1)
+	}
+
+	// One request frees up at a time; a weight of 3 to 1 gives the high
+	// priority class three of every four
+	high := 0
+	for i := 0; i < perClass; i++ {
+		clock.Advance(time.Second)
+		waitForWaiters(t, limiter, "/test", 2*perClass-i-1)
+		if <-order == "HIGH_KEY" {
+			high++
+		}
+	}
+	if high != 6 {
+		t.Errorf("Expected 6 of the first %d grants to go to HIGH_KEY, got %d", perClass, high)
+	}
+
+	clock.Advance(time.Minute)
+	waitForWaiters(t, limiter, "/test", 0)
+}
+
+func TestLimiter_ReserveWaitExceedsLimit(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newQueueLimiter(clock)
+	limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+
+	// A reservation larger than the budget is denied without queueing
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	start := time.Now()
+	if limiter.ReserveWait(ctx, "client1", 101, 1, "API_KEY_1", "/test").Allowed {
+		t.Fatal("Expected a reservation larger than the budget to be denied")
+	}
+	if waited := time.Since(start); waited > 500*time.Millisecond {
+		t.Errorf("Expected an immediate denial, waited %v", waited)
+	}
+	waitForWaiters(t, limiter, "/test", 0)
+}
+
+// gatedStore blocks reads while its gate is closed
+type gatedStore struct {
+	Store
+	entered chan struct{}
+	gate    chan struct{}
+}
+
+func (s *gatedStore) View(limits []Limit, fn func(counters []*Counters)) error {
+	select {
+	case s.entered <- struct{}{}:
+		<-s.gate
+	default:
+	}
+	return s.Store.View(limits, fn)
+}
+
+func TestLimiter_ReserveWaitSlowStore(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newQueueLimiter(clock)
+	limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+
+	store := &gatedStore{Store: limiter.store, entered: make(chan struct{}), gate: make(chan struct{})}
+	limiter.store = store
+	defer close(store.gate)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	go limiter.ReserveWait(ctx, "client1", 1, 1, "API_KEY_1", "/test")
+	waitForWaiters(t, limiter, "/test", 1)
+	<-store.entered
+
+	// The queue is stuck on the store, but new waiters can still queue and
+	// give up in time
+	short, cancelShort := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelShort()
+	result := make(chan *Reservation, 1)
+	go func() {
+		result <- limiter.ReserveWait(short, "client1", 1, 1, "API_KEY_1", "/test")
+	}()
+	select {
+	case reservation := <-result:
+		if reservation.Allowed {
+			t.Error("Expected the reservation to be denied after its deadline")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected a waiter to give up while the store is slow")
+	}
+}
@@ -0,0 +1,127 @@
+package ratelimit
+
+import (
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrUnknownKey is returned when inspecting an API key or endpoint that is
+// not configured
+var ErrUnknownKey = errors.New("ratelimit: unknown API key or endpoint")
+
+// Quota describes one limit on a reservation's path and how much of it is
+// in use. Requests or Tokens is nil if that budget is not capped. Node is
+// set in cluster mode to the node whose counters the quota was read from,
+// since each node enforces the global and per-key caps for the keys it
+// owns.
+type Quota struct {
+	Level     string   `json:"level"`
+	Path      string   `json:"path,omitempty"`
+	Methods   []string `json:"methods,omitempty"`
+	ClientID  string   `json:"clientID,omitempty"`
+	Algorithm string   `json:"algorithm"`
+	Requests  *Usage   `json:"requests,omitempty"`
+	Tokens    *Usage   `json:"tokens,omitempty"`
+	Node      string   `json:"node,omitempty"`
+}
+
+// Usage describes a single budget. Used can exceed Limit when usage was
+// charged after the fact; Remaining never goes below zero.
+type Usage struct {
+	Limit     int       `json:"limit"`
+	Used      int       `json:"used"`
+	Remaining int       `json:"remaining"`
+	ResetAt   time.Time `json:"resetAt"`
+}
+
+// Quota returns the limits of an API key's endpoint, from the global cap
+// down to clientID's quota if it has one. If endpoint is empty, it returns
+// the global and per-key caps and every endpoint of the key instead.
+// Counters are read without being modified.
+func (rl *Limiter) Quota(apiKey, endpoint, clientID string) ([]Quota, error) {
+	rl.mutex.RLock()
+	limits, exists := rl.limits.inspect(apiKey, endpoint, clientID)
+	rl.mutex.RUnlock()
+
+	if !exists {
+		return nil, ErrUnknownKey
+	}
+
+	quotas := make([]Quota, len(limits))
+	err := rl.store.View(limits, func(counters []*Counters) {
+		now := rl.now()
+		for i, limit := range limits {
+			quotas[i] = Quota{
+				Level:     limit.level,
+				Path:      limit.State.Path,
+				Methods:   normalizeMethods(limit.State.endpoint.Methods),
+				Algorithm: limit.State.Algorithm,
+				Requests:  usage(counters[i].Requests, limit.State.RPM, now),
+				Tokens:    usage(counters[i].Tokens, limit.State.TPM, now),
+			}
+			if limit.level == levelClient {
+				quotas[i].ClientID = clientID
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// usage describes a budget of limit units, or returns nil if it is not
+// capped
+func usage(algorithm Algorithm, limit int, now time.Time) *Usage {
+	if limit == unlimited {
+		return nil
+	}
+
+	remaining := algorithm.Remaining(now)
+	u := &Usage{
+		Limit:     limit,
+		Used:      limit - remaining,
+		Remaining: remaining,
+		ResetAt:   now.Add(algorithm.RetryAfter(now, limit)),
+	}
+	if u.Remaining < 0 {
+		u.Remaining = 0
+	}
+	return u
+}
+
+// inspect returns the limits to report for an API key's endpoint, or for
+// the whole key if endpoint is empty, and whether they exist
+func (t *limitTree) inspect(apiKey, endpoint, clientID string) ([]Limit, bool) {
+	if endpoint != "" {
+		return t.chain(apiKey, endpoint, clientID)
+	}
+
+	keyState, exists := t.apiKeys[apiKey]
+	if !exists {
+		return nil, false
+	}
+
+	// A sharded global cap is only reported for an endpoint, as the shard
+	// its clients draw from
+	var limits []Limit
+	if len(t.globals) == 1 {
+		limits = append(limits, Limit{Key: globalKey, State: t.globals[0], level: levelGlobal})
+	}
+	if keyState.limits != nil {
+		limits = append(limits, Limit{Key: aggregateKey(apiKey), State: keyState.limits, level: levelAPIKey})
+	}
+
+	routes := make([]string, 0, len(keyState.endpoints))
+	for route := range keyState.endpoints {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		state := keyState.endpoints[route]
+		limits = append(limits, Limit{Key: Key(apiKey, state.key), State: state, level: levelEndpoint})
+	}
+
+	return limits, true
+}
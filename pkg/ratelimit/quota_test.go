@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newQuotaLimiter(clock *testClock) *Limiter {
+	limiter := MustNew(Config{})
+	limiter.now = clock.Now
+	limiter.UpdateLimits(LimitConfig{RPM: 100}, []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			TPM:    500,
+			Endpoints: []EndpointConfig{
+				{Path: "/b", RPM: 10, TPM: 100, ClientLimits: []ClientLimit{{ClientID: "client1", RPM: 2, TPM: 20}}},
+				{Path: "/a", RPM: 20, TPM: 200},
+			},
+		},
+	})
+	return limiter
+}
+
+func TestLimiter_Quota(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newQuotaLimiter(clock)
+	limiter.Reserve("client2", 30, 1, "API_KEY_1", "/b")
+	clock.Advance(10 * time.Second)
+
+	tests := []struct {
+		name     string
+		endpoint string
+		clientID string
+		want     []Quota
+	}{
+		{
+			name:     "Endpoint with client quota",
+			endpoint: "/b",
+			clientID: "client1",
+			want: []Quota{
+				{Level: levelGlobal, Requests: &Usage{Limit: 100, Used: 1, Remaining: 99}},
+				{Level: levelAPIKey, Tokens: &Usage{Limit: 500, Used: 30, Remaining: 470}},
+				{Level: levelEndpoint, Path: "/b", Requests: &Usage{Limit: 10, Used: 1, Remaining: 9}, Tokens: &Usage{Limit: 100, Used: 30, Remaining: 70}},
+				{Level: levelClient, Path: "/b", ClientID: "client1", Requests: &Usage{Limit: 2, Used: 0, Remaining: 2}, Tokens: &Usage{Limit: 20, Used: 0, Remaining: 20}},
+			},
+		},
+		{
+			name: "Whole API key",
+			want: []Quota{
+				{Level: levelGlobal, Requests: &Usage{Limit: 100, Used: 1, Remaining: 99}},
+				{Level: levelAPIKey, Tokens: &Usage{Limit: 500, Used: 30, Remaining: 470}},
+				{Level: levelEndpoint, Path: "/a", Requests: &Usage{Limit: 20, Used: 0, Remaining: 20}, Tokens: &Usage{Limit: 200, Used: 0, Remaining: 200}},
+				{Level: levelEndpoint, Path: "/b", Requests: &Usage{Limit: 10, Used: 1, Remaining: 9}, Tokens: &Usage{Limit: 100, Used: 30, Remaining: 70}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			quotas, err := limiter.Quota("API_KEY_1", tt.endpoint, tt.clientID)
+			if err != nil {
+				t.Fatalf("Quota failed: %v", err)
+			}
+			if len(quotas) != len(tt.want) {
+				t.Fatalf("Expected %d quotas, got %d", len(tt.want), len(quotas))
+			}
+			for i, want := range tt.want {
+				got := quotas[i]
+				if got.Level != want.Level || got.Path != want.Path || got.ClientID != want.ClientID {
+					t.Errorf("Expected quota %d for %s %s %s, got %s %s %s", i, want.Level, want.Path, want.ClientID, got.Level, got.Path, got.ClientID)
+				}
+				checkUsage(t, "requests", got.Requests, want.Requests)
+				checkUsage(t, "tokens", got.Tokens, want.Tokens)
+			}
+		})
+	}
+}
+
+// checkUsage compares a budget's usage, ignoring its reset time
+func checkUsage(t *testing.T, budget string, got, want *Usage) {
+	t.Helper()
+	if (got == nil) != (want == nil) {
+		t.Errorf("Expected %s %+v, got %+v", budget, want, got)
+		return
+	}
+	if got != nil && (got.Limit != want.Limit || got.Used != want.Used || got.Remaining != want.Remaining) {
+		t.Errorf("Expected %s %+v, got %+v", budget, *want, *got)
+	}
+}
+
+func TestLimiter_QuotaDoesNotModifyCounters(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := newQuotaLimiter(clock)
+	limiter.Reserve("client2", 10, 10, "API_KEY_1", "/b")
+
+	// The fixed window expires after a minute; inspecting it then must not
+	// start a new window in its place
+	clock.Advance(time.Minute + time.Second)
+	quotas, err := limiter.Quota("API_KEY_1", "/b", "")
+	if err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	if endpoint := quotas[2]; endpoint.Requests.Remaining != 10 || !endpoint.Requests.ResetAt.Equal(clock.Now()) {
+		t.Errorf("Expected a full budget that is already reset, got %+v", endpoint.Requests)
+	}
+
+	clock.Advance(30 * time.Second)
+	reservation := limiter.Reserve("client2", 1, 1, "API_KEY_1", "/b")
+	quotas, _ = limiter.Quota("API_KEY_1", "/b", "")
+	if reset := quotas[2].Requests.ResetAt; !reset.Equal(clock.Now().Add(time.Minute)) {
+		t.Errorf("Expected the new window to start with the reservation at %v, got a reset at %v", clock.Now(), reset)
+	}
+	if !reservation.Allowed {
+		t.Errorf("Expected reservation to be allowed, got %+v", reservation)
+	}
+}
+
+func TestLimiter_QuotaUnknownKey(t *testing.T) {
+	limiter := newQuotaLimiter(&testClock{now: time.Now()})
+
+	for _, endpoint := range []string{"", "/missing"} {
+		if _, err := limiter.Quota("API_KEY_2", endpoint, ""); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("Expected ErrUnknownKey for API_KEY_2 %q, got %v", endpoint, err)
+		}
+	}
+	if _, err := limiter.Quota("API_KEY_1", "/missing", ""); !errors.Is(err, ErrUnknownKey) {
+		t.Errorf("Expected ErrUnknownKey for /missing, got %v", err)
+	}
+}
@@ -0,0 +1,470 @@
+package ratelimit
+
+import (
+	"fmt"
+	"log"
+	"reflect"
+	"sync"
+	"time"
+)
+
+// window is the period RPM and TPM limits apply to
+const window = time.Minute
+
+// Limiter handles rate limiting logic
+type Limiter struct {
+	limits     *limitTree
+	store      Store
+	scheduler  *Scheduler
+	queues     map[string]*waitQueue
+	queueMutex sync.Mutex
+	mutex      sync.RWMutex
+	now        func() time.Time
+
+	reservations     map[string]*pendingReservation
+	reservationTTL   time.Duration
+	reservationMutex sync.Mutex
+
+	leases     map[string]*pendingReservation
+	leaseMutex sync.Mutex
+
+	metrics Recorder
+}
+
+// EndpointState holds the limits of an endpoint, or of a global, per-key or
+// per-client cap; its usage is kept in the Limiter's Store
+type EndpointState struct {
+	Path      string
+	RPM       int
+	TPM       int
+	Algorithm string
+	clients   map[string]*EndpointState
+	endpoint  EndpointConfig
+	// key identifies an endpoint's counters in the store
+	key string
+}
+
+// Reservation represents a rate limit reservation response. ReservationID
+// and ExpiresAt are set when reservations stay pending until committed.
+// RequestLimit and ResetMs describe the request budget with the fewest
+// requests left, and RetryAfterMs is set on denials that can succeed later.
+type Reservation struct {
+	Allowed            bool       `json:"allowed"`
+	ReservedTokens     int        `json:"reservedTokens"`
+	ReservedRequests   int        `json:"reservedRequests"`
+	RemainingTokens    int        `json:"remainingTokens"`
+	RemainingRequests  int        `json:"remainingRequests"`
+	TargetEndpointPath string     `json:"targetEndpointPath"`
+	ReservationID      string     `json:"reservationID,omitempty"`
+	ExpiresAt          *time.Time `json:"expiresAt,omitempty"`
+	RequestLimit       int        `json:"requestLimit,omitempty"`
+	ResetMs            int64      `json:"resetMs,omitempty"`
+	RetryAfterMs       int64      `json:"retryAfterMs,omitempty"`
+	// UnknownKey marks denials of API keys or endpoints that are not
+	// configured, which no amount of waiting will allow
+	UnknownKey bool `json:"unknownKey,omitempty"`
+}
+
+// New creates a new Limiter instance from cfg, with counters kept in the
+// store it selects
+func New(cfg Config) (*Limiter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newLimiter(cfg, NewStore(cfg.Store)), nil
+}
+
+// MustNew creates a new Limiter instance like New, but panics if cfg is
+// invalid. It simplifies setting up limiters with fixed limits.
+func MustNew(cfg Config) *Limiter {
+	limiter, err := New(cfg)
+	if err != nil {
+		panic(err)
+	}
+	return limiter
+}
+
+// NewWithStore creates a new Limiter instance from cfg that keeps its
+// counters in store, ignoring cfg.Store
+func NewWithStore(cfg Config, store Store) (*Limiter, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return newLimiter(cfg, store), nil
+}
+
+// newLimiter creates a limiter from a validated configuration
+func newLimiter(cfg Config, store Store) *Limiter {
+	process := cfg.Process
+	if process == nil {
+		process = func(*Reservation) {}
+	}
+
+	limiter := &Limiter{
+		store:        store,
+		queues:       make(map[string]*waitQueue),
+		now:          time.Now,
+		reservations: make(map[string]*pendingReservation),
+		leases:       make(map[string]*pendingReservation),
+	}
+	limiter.scheduler = NewScheduler(process)
+	limiter.UpdateLimits(cfg.GlobalLimit, cfg.RateLimits)
+	limiter.UpdatePriorities(cfg.PriorityClasses, cfg.DispatchPolicies)
+	limiter.SetReservationTTL(cfg.ReservationTTL)
+
+	return limiter
+}
+
+// UpdateLimits atomically replaces the configured limits. Limits that are
+// unchanged keep their counters; changed and new limits start fresh.
+// Remaining budgets recorded under the previous limits are dropped from the
+// metrics.
+func (rl *Limiter) UpdateLimits(global LimitConfig, rateLimits []RateLimit) {
+	rl.mutex.Lock()
+	defer rl.mutex.Unlock()
+
+	rl.limits = newLimitTree(global, rateLimits, rl.limits)
+	if rl.metrics != nil {
+		rl.metrics.Reload()
+	}
+}
+
+// Key returns the limit key for an API key's endpoint
+func Key(apiKey, endpoint string) string {
+	return fmt.Sprintf("%s-%s", apiKey, endpoint)
+}
+
+// Resolve returns the route of the API key's endpoint that a target
+// endpoint is limited under, or the target itself if none matches
+func (rl *Limiter) Resolve(apiKey, targetEndpoint string) string {
+	rl.mutex.RLock()
+	defer rl.mutex.RUnlock()
+
+	if route, _, exists := rl.limits.endpoint(apiKey, targetEndpoint); exists {
+		return route
+	}
+	return targetEndpoint
+}
+
+// clientKey returns the limit key for a client's share of an endpoint
+func clientKey(apiKey, endpoint, clientID string) string {
+	return Key(apiKey, endpoint) + "#" + clientID
+}
+
+// newEndpointState creates the state for an endpoint and its client limits
+func newEndpointState(endpoint EndpointConfig) *EndpointState {
+	algorithm := endpoint.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmFixedWindow
+	}
+
+	state := &EndpointState{
+		Path:      endpoint.Path,
+		RPM:       endpoint.RPM,
+		TPM:       endpoint.TPM,
+		Algorithm: algorithm,
+		clients:   make(map[string]*EndpointState),
+		endpoint:  endpoint,
+		key:       endpoint.storeKey(),
+	}
+	for _, client := range endpoint.ClientLimits {
+		rpm, tpm := client.RPM, client.TPM
+		if client.Fraction > 0 {
+			rpm = int(float64(endpoint.RPM) * client.Fraction)
+			tpm = int(float64(endpoint.TPM) * client.Fraction)
+		} else {
+			// A fixed quota leaves the budget it does not set to the
+			// endpoint
+			if rpm == 0 {
+				rpm = unlimited
+			}
+			if tpm == 0 {
+				tpm = unlimited
+			}
+		}
+		state.clients[client.ClientID] = &EndpointState{
+			Path:      endpoint.Path,
+			RPM:       rpm,
+			TPM:       tpm,
+			Algorithm: algorithm,
+		}
+	}
+
+	return state
+}
+
+// clientState returns the sub-limit that applies to clientID, if any
+func (s *EndpointState) clientState(clientID string) *EndpointState {
+	if client, exists := s.clients[clientID]; exists {
+		return client
+	}
+	return s.clients[AnyClient]
+}
+
+// UpdatePriorities replaces the priority classes of API keys and the
+// dispatch policies of those classes
+func (rl *Limiter) UpdatePriorities(classes map[string]int, policies map[int]DispatchPolicy) {
+	rl.scheduler.UpdatePriorities(classes, policies)
+}
+
+// sameLimits reports whether two states enforce identical limits. The
+// order and case of an endpoint's methods do not matter.
+func (s *EndpointState) sameLimits(other *EndpointState) bool {
+	endpoint, otherEndpoint := s.endpoint, other.endpoint
+	endpoint.Methods = normalizeMethods(endpoint.Methods)
+	otherEndpoint.Methods = normalizeMethods(otherEndpoint.Methods)
+	return s.sameBudgets(other) && reflect.DeepEqual(endpoint, otherEndpoint)
+}
+
+// sameBudgets reports whether two states count usage the same way, so
+// counters kept for one are valid for the other
+func (s *EndpointState) sameBudgets(other *EndpointState) bool {
+	return s.RPM == other.RPM && s.TPM == other.TPM && s.Algorithm == other.Algorithm
+}
+
+// Reasons a reservation is denied, besides running out of one of the
+// budgets on its path
+const (
+	denyUnknownKey = "unknown_key"
+	denyError      = "error"
+	// denyBatch marks a reservation that fit on its own but was denied
+	// with the rest of its batch
+	denyBatch = "batch"
+)
+
+// outcome is the result of taking a reservation from a chain of limits
+type outcome struct {
+	allowed           bool
+	remainingRequests int
+	remainingTokens   int
+	takenAt           time.Time
+	// reason names the budget that denied the reservation
+	reason string
+	// remaining holds the budget left at each limit, in chain order
+	remaining []budget
+	// requestLimit and reset describe the request budget with the fewest
+	// requests left: its size and how long until it is fully replenished
+	requestLimit int
+	reset        time.Duration
+	// retryAfter is how long until a denied reservation would fit, or
+	// negative if it never will
+	retryAfter time.Duration
+}
+
+// budget is the requests and tokens left in one limit
+type budget struct {
+	requests int
+	tokens   int
+}
+
+// Reserve attempts to reserve capacity for requests and tokens
+func (rl *Limiter) Reserve(clientID string, tokens, requests int, apiKey, targetEndpoint string) *Reservation {
+	reservation, reason := rl.reserve(clientID, tokens, requests, apiKey, targetEndpoint)
+	rl.record(reservation, reason, apiKey, targetEndpoint)
+	return reservation
+}
+
+// Allow reports whether a reservation for requests and tokens is allowed,
+// reserving them if it is
+func (rl *Limiter) Allow(clientID string, tokens, requests int, apiKey, targetEndpoint string) bool {
+	return rl.Reserve(clientID, tokens, requests, apiKey, targetEndpoint).Allowed
+}
+
+// reserve attempts a reservation without recording metrics, returning why
+// it was denied if it was
+func (rl *Limiter) reserve(clientID string, tokens, requests int, apiKey, targetEndpoint string) (*Reservation, string) {
+	// The reservation must fit every limit from the global cap down to the
+	// client's quota
+	rl.mutex.RLock()
+	limits, exists := rl.limits.chain(apiKey, targetEndpoint, clientID)
+	rl.mutex.RUnlock()
+
+	if !exists {
+		return &Reservation{
+			Allowed:    false,
+			UnknownKey: true,
+		}, denyUnknownKey
+	}
+
+	result, err := rl.reserveLimits(limits, tokens, requests, nil)
+	if err != nil {
+		log.Printf("Error updating counters for %s: %v", Key(apiKey, targetEndpoint), err)
+		return &Reservation{
+			Allowed: false,
+		}, denyError
+	}
+	rl.recordRemaining(limits, result.remaining, apiKey)
+
+	if !result.allowed {
+		reservation := &Reservation{
+			Allowed:           false,
+			RemainingTokens:   result.remainingTokens,
+			RemainingRequests: result.remainingRequests,
+			RequestLimit:      result.requestLimit,
+			ResetMs:           millis(result.reset),
+		}
+		if result.retryAfter > 0 {
+			reservation.RetryAfterMs = millis(result.retryAfter)
+		}
+		return reservation, result.reason
+	}
+
+	reservation := &Reservation{
+		Allowed:            true,
+		ReservedTokens:     tokens,
+		ReservedRequests:   requests,
+		RemainingTokens:    result.remainingTokens,
+		RemainingRequests:  result.remainingRequests,
+		TargetEndpointPath: targetEndpoint,
+		RequestLimit:       result.requestLimit,
+		ResetMs:            millis(result.reset),
+	}
+	rl.track(reservation, clientID, apiKey, limits, result.takenAt)
+
+	// Process based on priority
+	rl.scheduler.Dispatch(apiKey, reservation)
+
+	return reservation, ""
+}
+
+// reserveLimits takes requests and tokens from every limit or from none of
+// them. The remaining budgets reported are the smallest across the limits,
+// after the reservation if it was allowed. A lease is also checked against
+// the leases outstanding on each limit, and recorded with them.
+func (rl *Limiter) reserveLimits(limits []Limit, tokens, requests int, lease *LeaseHold) (*outcome, error) {
+	result := &outcome{}
+
+	err := rl.store.Update(limits, func(counters []*Counters) bool {
+		now := rl.now()
+		*result = outcome{takenAt: now}
+		result.remainingRequests, result.remainingTokens = minRemaining(counters, now)
+
+		// A lease must also fit alongside the outstanding leases
+		if lease != nil {
+			if wait := leaseWait(limits, counters, tokens, requests, now); wait != 0 {
+				result.retryAfter = wait
+				result.remaining = remainingByLimit(counters, now)
+				return false
+			}
+		}
+
+		// Check if the reservation would exceed any budget
+		result.allowed = requests <= result.remainingRequests && tokens <= result.remainingTokens
+		if !result.allowed {
+			result.reason = denyReason(limits, counters, now, tokens, requests)
+			result.retryAfter = retryAfter(counters, now, tokens, requests)
+			result.remaining = remainingByLimit(counters, now)
+			result.requestLimit, result.reset = requestBudget(limits, counters, result.remaining, now)
+			return false
+		}
+
+		// Update state
+		for _, c := range counters {
+			c.Requests.Take(now, requests)
+			c.Tokens.Take(now, tokens)
+			c.LastRequest = now
+			if lease != nil {
+				c.Leases = append(liveHolds(c.Leases, now), *lease)
+			}
+		}
+		result.remainingRequests -= requests
+		result.remainingTokens -= tokens
+		result.remaining = remainingByLimit(counters, now)
+		result.requestLimit, result.reset = requestBudget(limits, counters, result.remaining, now)
+		return true
+	})
+
+	return result, err
+}
+
+// denyReason names the outermost budget too small for the reservation
+func denyReason(limits []Limit, counters []*Counters, now time.Time, tokens, requests int) string {
+	for i, c := range counters {
+		if requests > c.Requests.Remaining(now) {
+			return limits[i].level + "_rpm"
+		}
+		if tokens > c.Tokens.Remaining(now) {
+			return limits[i].level + "_tpm"
+		}
+	}
+	return ""
+}
+
+// retryAfter returns how long until requests and tokens fit in every
+// counter, or a negative duration if they never will
+func retryAfter(counters []*Counters, now time.Time, tokens, requests int) time.Duration {
+	var wait time.Duration
+	for _, c := range counters {
+		d := c.retryAfter(now, tokens, requests)
+		if d < 0 {
+			return d
+		}
+		if d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// retryAfter returns how long until requests and tokens fit in c, or a
+// negative duration if they never will
+func (c *Counters) retryAfter(now time.Time, tokens, requests int) time.Duration {
+	requestWait := c.Requests.RetryAfter(now, requests)
+	tokenWait := c.Tokens.RetryAfter(now, tokens)
+	if requestWait < 0 || tokenWait < 0 {
+		return -1
+	}
+	if tokenWait > requestWait {
+		return tokenWait
+	}
+	return requestWait
+}
+
+// requestBudget returns the size of the capped request budget with the
+// fewest requests left, and how long until it is fully replenished
+func requestBudget(limits []Limit, counters []*Counters, remaining []budget, now time.Time) (int, time.Duration) {
+	tightest := -1
+	for i, limit := range limits {
+		if limit.State.RPM == unlimited {
+			continue
+		}
+		if tightest < 0 || remaining[i].requests < remaining[tightest].requests {
+			tightest = i
+		}
+	}
+	if tightest < 0 {
+		return 0, 0
+	}
+	limit := limits[tightest].State.RPM
+	return limit, counters[tightest].Requests.RetryAfter(now, limit)
+}
+
+// millis converts d to whole milliseconds, rounding up
+func millis(d time.Duration) int64 {
+	return int64((d + time.Millisecond - 1) / time.Millisecond)
+}
+
+// remainingByLimit returns the requests and tokens left in each counter
+func remainingByLimit(counters []*Counters, now time.Time) []budget {
+	remaining := make([]budget, len(counters))
+	for i, c := range counters {
+		remaining[i] = budget{requests: c.Requests.Remaining(now), tokens: c.Tokens.Remaining(now)}
+	}
+	return remaining
+}
+
+// minRemaining returns the smallest remaining requests and tokens across
+// counters
+func minRemaining(counters []*Counters, now time.Time) (int, int) {
+	remainingRequests := counters[0].Requests.Remaining(now)
+	remainingTokens := counters[0].Tokens.Remaining(now)
+	for _, c := range counters[1:] {
+		if requests := c.Requests.Remaining(now); requests < remainingRequests {
+			remainingRequests = requests
+		}
+		if tokens := c.Tokens.Remaining(now); tokens < remainingTokens {
+			remainingTokens = tokens
+		}
+	}
+	return remainingRequests, remainingTokens
+}
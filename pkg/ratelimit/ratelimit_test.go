@@ -0,0 +1,399 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter_Reserve(t *testing.T) {
+	// Test configuration
+	rateLimits := []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path: "/test",
+					RPM:  10,
+					TPM:  100,
+				},
+			},
+		},
+	}
+
+	limiter := MustNew(Config{RateLimits: rateLimits})
+
+	tests := []struct {
+		name           string
+		clientID       string
+		tokens         int
+		requests       int
+		apiKey         string
+		targetEndpoint string
+		wantAllowed    bool
+	}{
+		{
+			name:           "Valid request within limits",
+			clientID:       "client1",
+			tokens:         50,
+			requests:       5,
+			apiKey:         "API_KEY_1",
+			targetEndpoint: "/test",
+			wantAllowed:    true,
+		},
+		{
+			name:           "Request exceeds RPM",
+			clientID:       "client1",
+			tokens:         50,
+			requests:       11,
+			apiKey:         "API_KEY_1",
+			targetEndpoint: "/test",
+			wantAllowed:    false,
+		},
+		{
+			name:           "Request exceeds TPM",
+			clientID:       "client1",
+			tokens:         101,
+			requests:       5,
+			apiKey:         "API_KEY_1",
+			targetEndpoint: "/test",
+			wantAllowed:    false,
+		},
+		{
+			name:           "Invalid API key",
+			clientID:       "client1",
+			tokens:         50,
+			requests:       5,
+			apiKey:         "INVALID_KEY",
+			targetEndpoint: "/test",
+			wantAllowed:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservation := limiter.Reserve(
+				tt.clientID,
+				tt.tokens,
+				tt.requests,
+				tt.apiKey,
+				tt.targetEndpoint,
+			)
+
+			if reservation.Allowed != tt.wantAllowed {
+				t.Errorf("Reserve() allowed = %v, want %v", reservation.Allowed, tt.wantAllowed)
+			}
+
+			// Add a small delay to avoid rate limit conflicts between tests
+			time.Sleep(100 * time.Millisecond)
+		})
+	}
+}
+
+func TestLimiter_ResetAfterOneMinute(t *testing.T) {
+	rateLimits := []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path: "/test",
+					RPM:  10,
+					TPM:  100,
+				},
+			},
+		},
+	}
+
+	limiter := MustNew(Config{RateLimits: rateLimits})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	// Make initial requests
+	for i := 0; i < 5; i++ {
+		reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+		if !reservation.Allowed {
+			t.Errorf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	// Simulate time passing (> 1 minute)
+	now = now.Add(2 * time.Minute)
+
+	// Make another request
+	reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+	if !reservation.Allowed {
+		t.Error("Expected request to be allowed after counter reset")
+	}
+
+	if reservation.RemainingRequests != 9 {
+		t.Errorf("Expected 9 remaining requests, got %d", reservation.RemainingRequests)
+	}
+}
+
+func TestLimiter_CumulativeTokens(t *testing.T) {
+	rateLimits := []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path: "/test",
+					RPM:  10,
+					TPM:  100,
+				},
+			},
+		},
+	}
+
+	limiter := MustNew(Config{RateLimits: rateLimits})
+	now := time.Now()
+	limiter.now = func() time.Time { return now }
+
+	// Each call is under TPM on its own, but together they exhaust it
+	for i := 0; i < 2; i++ {
+		reservation := limiter.Reserve("client1", 40, 1, "API_KEY_1", "/test")
+		if !reservation.Allowed {
+			t.Fatalf("Expected request %d to be allowed", i+1)
+		}
+	}
+
+	reservation := limiter.Reserve("client1", 40, 1, "API_KEY_1", "/test")
+	if reservation.Allowed {
+		t.Error("Expected request to be denied once cumulative tokens exceed TPM")
+	}
+	if reservation.RemainingTokens != 20 {
+		t.Errorf("Expected 20 remaining tokens, got %d", reservation.RemainingTokens)
+	}
+	if reservation.RemainingRequests != 8 {
+		t.Errorf("Expected 8 remaining requests, got %d", reservation.RemainingRequests)
+	}
+
+	// Both budgets reset together
+	now = now.Add(2 * time.Minute)
+
+	reservation = limiter.Reserve("client1", 40, 1, "API_KEY_1", "/test")
+	if !reservation.Allowed {
+		t.Fatal("Expected request to be allowed after counter reset")
+	}
+	if reservation.RemainingTokens != 60 {
+		t.Errorf("Expected 60 remaining tokens, got %d", reservation.RemainingTokens)
+	}
+	if reservation.RemainingRequests != 9 {
+		t.Errorf("Expected 9 remaining requests, got %d", reservation.RemainingRequests)
+	}
+}
+
+func TestLimiter_UpdateLimits(t *testing.T) {
+	endpoints := func(secondRPM int) []RateLimit {
+		return []RateLimit{
+			{
+				APIKey: "API_KEY_1",
+				Endpoints: []EndpointConfig{
+					{Path: "/unchanged", RPM: 10, TPM: 100},
+					{Path: "/changed", RPM: secondRPM, TPM: 100},
+					{Path: "/removed", RPM: 10, TPM: 100},
+				},
+			},
+		}
+	}
+
+	limiter := MustNew(Config{RateLimits: endpoints(10)})
+	for _, path := range []string{"/unchanged", "/changed", "/removed"} {
+		if !limiter.Reserve("client1", 10, 4, "API_KEY_1", path).Allowed {
+			t.Fatalf("Expected request to %s to be allowed", path)
+		}
+	}
+
+	updated := endpoints(20)
+	updated[0].Endpoints = updated[0].Endpoints[:2]
+	updated = append(updated, RateLimit{
+		APIKey:    "API_KEY_2",
+		Endpoints: []EndpointConfig{{Path: "/added", RPM: 5, TPM: 50}},
+	})
+	limiter.UpdateLimits(LimitConfig{}, updated)
+
+	// Unchanged limits keep their counters
+	reservation := limiter.Reserve("client1", 0, 1, "API_KEY_1", "/unchanged")
+	if reservation.RemainingRequests != 5 {
+		t.Errorf("Expected 5 remaining requests on unchanged endpoint, got %d", reservation.RemainingRequests)
+	}
+
+	// Changed limits start fresh with the new budget
+	reservation = limiter.Reserve("client1", 0, 1, "API_KEY_1", "/changed")
+	if reservation.RemainingRequests != 19 {
+		t.Errorf("Expected 19 remaining requests on changed endpoint, got %d", reservation.RemainingRequests)
+	}
+
+	if limiter.Reserve("client1", 0, 1, "API_KEY_1", "/removed").Allowed {
+		t.Error("Expected request to a removed endpoint to be denied")
+	}
+	if !limiter.Reserve("client1", 0, 1, "API_KEY_2", "/added").Allowed {
+		t.Error("Expected request to an added endpoint to be allowed")
+	}
+}
+
+func TestLimiter_ClientLimits(t *testing.T) {
+	rateLimits := []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path: "/test",
+					RPM:  10,
+					TPM:  100,
+					ClientLimits: []ClientLimit{
+						{ClientID: "noisy", RPM: 3, TPM: 100},
+						{ClientID: AnyClient, Fraction: 0.5},
+					},
+				},
+			},
+		},
+	}
+
+	limiter := MustNew(Config{RateLimits: rateLimits})
+
+	tests := []struct {
+		name     string
+		clientID string
+		allowed  int
+	}{
+		{name: "Fixed client quota", clientID: "noisy", allowed: 3},
+		{name: "Fractional quota for other clients", clientID: "client1", allowed: 5},
+		{name: "Endpoint budget caps the remaining clients", clientID: "client2", allowed: 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			allowed := 0
+			for i := 0; i < 10; i++ {
+				if limiter.Reserve(tt.clientID, 1, 1, "API_KEY_1", "/test").Allowed {
+					allowed++
+				}
+			}
+			if allowed != tt.allowed {
+				t.Errorf("Expected %d requests allowed for %s, got %d", tt.allowed, tt.clientID, allowed)
+			}
+		})
+	}
+}
+
+func TestLimiter_ClientLimitOnOneBudget(t *testing.T) {
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path: "/test",
+					RPM:  10,
+					TPM:  100,
+					ClientLimits: []ClientLimit{
+						{ClientID: "requests-only", RPM: 2},
+						{ClientID: "tokens-only", TPM: 20},
+					},
+				},
+			},
+		},
+	}})
+
+	// The budget a quota leaves unset is capped by the endpoint alone
+	for i, want := range []bool{true, true, false} {
+		if got := limiter.Reserve("requests-only", 10, 1, "API_KEY_1", "/test").Allowed; got != want {
+			t.Errorf("Request %d for requests-only: expected allowed %v, got %v", i, want, got)
+		}
+	}
+	for i, want := range []bool{true, true, false} {
+		if got := limiter.Reserve("tokens-only", 10, 1, "API_KEY_1", "/test").Allowed; got != want {
+			t.Errorf("Request %d for tokens-only: expected allowed %v, got %v", i, want, got)
+		}
+	}
+
+	if err := (Config{RateLimits: []RateLimit{
+		{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{{Path: "/test", RPM: 10, ClientLimits: []ClientLimit{{ClientID: "empty"}}}}},
+	}}).Validate(); err == nil {
+		t.Error("Expected a client limit without a quota to be rejected")
+	}
+}
+
+func TestLimiter_RetryAfter(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{Path: "/test", RPM: 2, TPM: 100},
+			},
+		},
+	}})
+	limiter.now = clock.Now
+
+	reservation := limiter.Reserve("client1", 10, 2, "API_KEY_1", "/test")
+	if reservation.RequestLimit != 2 || reservation.ResetMs != 60000 || reservation.RetryAfterMs != 0 {
+		t.Errorf("Expected limit 2, reset in 60000ms and no retry hint, got %+v", reservation)
+	}
+
+	clock.Advance(20 * time.Second)
+	denied := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+	if denied.Allowed || denied.RetryAfterMs != 40000 {
+		t.Fatalf("Expected a denial with retryAfterMs 40000, got %+v", denied)
+	}
+
+	clock.Advance(time.Duration(denied.RetryAfterMs) * time.Millisecond)
+	if retried := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test"); !retried.Allowed {
+		t.Errorf("Expected the reservation to fit after waiting retryAfterMs, got %+v", retried)
+	}
+}
+
+func TestNew(t *testing.T) {
+	valid := Config{RateLimits: []RateLimit{
+		{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{{Path: "/test", RPM: 10, TPM: 100}}},
+	}}
+	if _, err := New(valid); err != nil {
+		t.Errorf("Expected a valid configuration, got %v", err)
+	}
+
+	invalid := Config{RateLimits: []RateLimit{
+		{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{{Path: "/test", RPM: 10, TPM: 100, Algorithm: "leaky_faucet"}}},
+	}}
+	if _, err := New(invalid); err == nil {
+		t.Error("Expected an unknown algorithm to be rejected")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected MustNew to panic on an invalid configuration")
+		}
+	}()
+	MustNew(invalid)
+}
+
+func TestLimiter_Allow(t *testing.T) {
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{{Path: "/test", RPM: 2, TPM: 100}}},
+	}})
+
+	for i, want := range []bool{true, true, false} {
+		if got := limiter.Allow("client1", 10, 1, "API_KEY_1", "/test"); got != want {
+			t.Errorf("Request %d: expected allowed %v, got %v", i+1, want, got)
+		}
+	}
+}
+
+func TestLimiter_Process(t *testing.T) {
+	processed := make(chan *Reservation, 1)
+	limiter := MustNew(Config{
+		RateLimits: []RateLimit{
+			{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{{Path: "/test", RPM: 10, TPM: 100}}},
+		},
+		Process: func(reservation *Reservation) {
+			processed <- reservation
+		},
+	})
+
+	reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+	select {
+	case got := <-processed:
+		if got != reservation {
+			t.Error("Expected the allowed reservation to be processed")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the allowed reservation to be processed")
+	}
+}
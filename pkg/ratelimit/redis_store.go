@@ -0,0 +1,291 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"hash/fnv"
+	"math/rand"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/yourusername/ratelimiter/internal/redis"
+)
+
+// maxUpdateAttempts bounds retries when another node updates the same keys
+const maxUpdateAttempts = 16
+
+// Contended updates back off for a random time under a ceiling that
+// doubles with each attempt, so updates racing on a hot key spread out
+// rather than colliding again
+const (
+	minUpdateBackoff = 500 * time.Microsecond
+	maxUpdateBackoff = 20 * time.Millisecond
+)
+
+// lockStripes is how many locks a node spreads its keys over
+const lockStripes = 64
+
+// ErrContention is returned when keys keep changing under an update
+var ErrContention = errors.New("ratelimit: too much contention on key")
+
+// compareAndSet replaces each of the N KEYS with ARGV[N+i] only if every key
+// still holds ARGV[i] (an empty string meaning the key is absent), so a
+// read-modify-write from any node either applies on top of the values it
+// read or not at all. ARGV[2N+1] is the expiry in milliseconds.
+var compareAndSet = redis.NewScript(`
+local n = #KEYS
+for i = 1, n do
+	local current = redis.call('GET', KEYS[i])
+	if current == false then
+		current = ''
+	end
+	if current ~= ARGV[i] then
+		return 0
+	end
+end
+for i = 1, n do
+	redis.call('SET', KEYS[i], ARGV[n + i], 'PX', ARGV[2 * n + 1])
+end
+return 1
+`)
+
+// takeRecord deletes KEYS[1] and returns what it held, so exactly one of
+// the nodes racing to settle a record gets it
+var takeRecord = redis.NewScript(`
+local value = redis.call('GET', KEYS[1])
+if value then
+	redis.call('DEL', KEYS[1])
+end
+return value
+`)
+
+// recordKey namespaces the records of pending reservations and leases
+// apart from counters
+const recordKey = "_pending:"
+
+// RedisStore keeps counters in a Redis-compatible server so that every node
+// sharing it enforces the same budgets. Pending reservations are kept next
+// to the counters, so any node can settle them.
+type RedisStore struct {
+	client *redis.Client
+	prefix string
+	// locks serialize this node's updates to the same keys, so that only
+	// updates from different nodes race on the compare-and-set
+	locks [lockStripes]sync.Mutex
+}
+
+// storedCounters is the serialized form of Counters
+type storedCounters struct {
+	Algorithm   string          `json:"algorithm"`
+	RPM         int             `json:"rpm"`
+	TPM         int             `json:"tpm"`
+	Requests    json.RawMessage `json:"requests"`
+	Tokens      json.RawMessage `json:"tokens"`
+	LastRequest time.Time       `json:"lastRequest"`
+	Leases      []LeaseHold     `json:"leases,omitempty"`
+}
+
+// newRedisStore creates a new RedisStore instance. Keys are namespaced
+// with prefix.
+func newRedisStore(client *redis.Client, prefix string) *RedisStore {
+	return &RedisStore{
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// Update reads the counters of every limit, runs fn, and writes them back
+// with an atomic compare-and-set, retrying if another node got there first
+func (s *RedisStore) Update(limits []Limit, fn func(counters []*Counters) bool) error {
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = s.prefix + limit.Key
+	}
+	unlock := s.lock(keys)
+	defer unlock()
+
+	for attempt := 0; attempt < maxUpdateAttempts; attempt++ {
+		reply, err := s.client.Do(append([]string{"MGET"}, keys...)...)
+		if err != nil {
+			return err
+		}
+		values, _ := reply.([]interface{})
+		if len(values) != len(keys) {
+			return errors.New("ratelimit: unexpected MGET reply")
+		}
+
+		current := make([]string, len(keys))
+		counters := make([]*Counters, len(keys))
+		for i, value := range values {
+			current[i], _ = value.(string)
+			counters[i], err = decodeCounters(current[i], limits[i].State)
+			if err != nil {
+				return err
+			}
+		}
+		if !fn(counters) {
+			return nil
+		}
+
+		args := make([]string, 0, 2*len(keys)+1)
+		args = append(args, current...)
+		for i := range counters {
+			updated, err := encodeCounters(counters[i], limits[i].State)
+			if err != nil {
+				return err
+			}
+			args = append(args, updated)
+		}
+		// Counters are back to empty after two idle windows for every algorithm
+		args = append(args, strconv.FormatInt((2*window).Milliseconds(), 10))
+
+		reply, err = compareAndSet.Run(s.client, keys, args...)
+		if err != nil {
+			return err
+		}
+		if swapped, _ := reply.(int64); swapped == 1 {
+			return nil
+		}
+		time.Sleep(updateBackoff(attempt))
+	}
+
+	return ErrContention
+}
+
+// lock takes the locks striping keys in a fixed order, and returns a
+// function that releases them
+func (s *RedisStore) lock(keys []string) func() {
+	seen := make(map[int]bool, len(keys))
+	stripes := make([]int, 0, len(keys))
+	for _, key := range keys {
+		hash := fnv.New32a()
+		hash.Write([]byte(key))
+		stripe := int(hash.Sum32() % lockStripes)
+		if !seen[stripe] {
+			seen[stripe] = true
+			stripes = append(stripes, stripe)
+		}
+	}
+	sort.Ints(stripes)
+
+	for _, stripe := range stripes {
+		s.locks[stripe].Lock()
+	}
+	return func() {
+		for _, stripe := range stripes {
+			s.locks[stripe].Unlock()
+		}
+	}
+}
+
+// updateBackoff returns a random wait before retrying a contended update
+func updateBackoff(attempt int) time.Duration {
+	ceiling := maxUpdateBackoff
+	if attempt < 10 && minUpdateBackoff<<uint(attempt) < ceiling {
+		ceiling = minUpdateBackoff << uint(attempt)
+	}
+	return time.Duration(rand.Int63n(int64(ceiling)) + 1)
+}
+
+// View reads the counters of every limit in one round trip and runs fn
+// against them without writing anything back
+func (s *RedisStore) View(limits []Limit, fn func(counters []*Counters)) error {
+	keys := make([]string, len(limits))
+	for i, limit := range limits {
+		keys[i] = s.prefix + limit.Key
+	}
+
+	reply, err := s.client.Do(append([]string{"MGET"}, keys...)...)
+	if err != nil {
+		return err
+	}
+	values, _ := reply.([]interface{})
+	if len(values) != len(keys) {
+		return errors.New("ratelimit: unexpected MGET reply")
+	}
+
+	counters := make([]*Counters, len(keys))
+	for i, value := range values {
+		data, _ := value.(string)
+		counters[i], err = decodeCounters(data, limits[i].State)
+		if err != nil {
+			return err
+		}
+	}
+
+	fn(counters)
+	return nil
+}
+
+// putRecord saves the record of a pending reservation under id for ttl
+func (s *RedisStore) putRecord(id, data string, ttl time.Duration) error {
+	_, err := s.client.Do("SET", s.prefix+recordKey+id, data, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// takeRecord removes and returns the record under id, or "" if there is
+// none
+func (s *RedisStore) takeRecord(id string) (string, error) {
+	reply, err := takeRecord.Run(s.client, []string{s.prefix + recordKey + id})
+	if err != nil {
+		return "", err
+	}
+	data, _ := reply.(string)
+	return data, nil
+}
+
+// decodeCounters rebuilds counters from their stored form, starting fresh
+// if nothing is stored or the endpoint's limits have changed
+func decodeCounters(data string, state *EndpointState) (*Counters, error) {
+	counters := newCounters(state)
+	if data == "" {
+		return counters, nil
+	}
+
+	var stored storedCounters
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+	if stored.Algorithm != state.Algorithm || stored.RPM != state.RPM || stored.TPM != state.TPM {
+		return counters, nil
+	}
+
+	if err := json.Unmarshal(stored.Requests, counters.Requests); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(stored.Tokens, counters.Tokens); err != nil {
+		return nil, err
+	}
+	counters.LastRequest = stored.LastRequest
+	counters.Leases = stored.Leases
+
+	return counters, nil
+}
+
+// encodeCounters serializes counters for storage
+func encodeCounters(counters *Counters, state *EndpointState) (string, error) {
+	requests, err := json.Marshal(counters.Requests)
+	if err != nil {
+		return "", err
+	}
+	tokens, err := json.Marshal(counters.Tokens)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(storedCounters{
+		Algorithm:   state.Algorithm,
+		RPM:         state.RPM,
+		TPM:         state.TPM,
+		Requests:    requests,
+		Tokens:      tokens,
+		LastRequest: counters.LastRequest,
+		Leases:      counters.Leases,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
@@ -0,0 +1,316 @@
+package ratelimit
+
+import (
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/yourusername/ratelimiter/internal/redis"
+)
+
+func newRedisLimiter(t *testing.T, addr, algorithm string) *Limiter {
+	rateLimits := []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path:      "/test",
+					RPM:       10,
+					TPM:       100,
+					Algorithm: algorithm,
+				},
+			},
+		},
+	}
+
+	client := redis.NewClient(addr, "")
+	t.Cleanup(func() { client.Close() })
+	return newLimiter(Config{RateLimits: rateLimits}, newRedisStore(client, "test:"))
+}
+
+func TestRedisStore_SharesLimitsAcrossNodes(t *testing.T) {
+	for _, algorithm := range algorithms {
+		t.Run(algorithm, func(t *testing.T) {
+			server := miniredis.RunT(t)
+
+			// Three replicas pointed at the same server share one budget
+			nodes := []*Limiter{
+				newRedisLimiter(t, server.Addr(), algorithm),
+				newRedisLimiter(t, server.Addr(), algorithm),
+				newRedisLimiter(t, server.Addr(), algorithm),
+			}
+			now := time.Now()
+			for _, node := range nodes {
+				node.now = func() time.Time { return now }
+			}
+
+			allowed := 0
+			for i := 0; i < 15; i++ {
+				if nodes[i%len(nodes)].Reserve("client1", 5, 1, "API_KEY_1", "/test").Allowed {
+					allowed++
+				}
+			}
+			if allowed != 10 {
+				t.Errorf("Expected 10 requests allowed across nodes, got %d", allowed)
+			}
+		})
+	}
+}
+
+func TestRedisStore_SettlesAcrossNodes(t *testing.T) {
+	server := miniredis.RunT(t)
+	nodes := []*Limiter{
+		newRedisLimiter(t, server.Addr(), AlgorithmFixedWindow),
+		newRedisLimiter(t, server.Addr(), AlgorithmFixedWindow),
+	}
+	for _, node := range nodes {
+		node.SetReservationTTL(time.Minute)
+	}
+
+	// A reservation granted on one node can be settled on the other, and
+	// only once
+	committed := nodes[0].Reserve("client1", 40, 1, "API_KEY_1", "/test")
+	settlement, err := nodes[1].Commit(committed.ReservationID, 10)
+	if err != nil {
+		t.Fatalf("Failed to commit on the other node: %v", err)
+	}
+	if settlement.RefundedTokens != 30 {
+		t.Errorf("Expected 30 tokens refunded, got %+v", settlement)
+	}
+	if _, err := nodes[0].Cancel(committed.ReservationID); err != ErrReservationNotFound {
+		t.Errorf("Expected the settled reservation to be gone on its own node, got %v", err)
+	}
+
+	cancelled := nodes[0].Reserve("client1", 50, 1, "API_KEY_1", "/test")
+	if _, err := nodes[1].Cancel(cancelled.ReservationID); err != nil {
+		t.Fatalf("Failed to cancel on the other node: %v", err)
+	}
+	if _, err := nodes[1].Cancel(cancelled.ReservationID); err != ErrReservationNotFound {
+		t.Errorf("Expected a second cancel to find nothing, got %v", err)
+	}
+
+	// Only the committed tokens stay charged
+	if next := nodes[0].Reserve("client1", 90, 1, "API_KEY_1", "/test"); !next.Allowed {
+		t.Errorf("Expected the settled reservations to be refunded, got %+v", next)
+	}
+}
+
+func TestRedisStore_LeasesAcrossNodes(t *testing.T) {
+	server := miniredis.RunT(t)
+	nodes := []*Limiter{
+		newRedisLimiter(t, server.Addr(), "fixed_window"),
+		newRedisLimiter(t, server.Addr(), "fixed_window"),
+	}
+	clock := &testClock{now: time.Now()}
+	for _, node := range nodes {
+		node.now = clock.Now
+	}
+
+	// Start the window before the lease, so it resets while the lease is
+	// outstanding
+	nodes[0].Reserve("client1", 1, 1, "API_KEY_1", "/test")
+	clock.Advance(30 * time.Second)
+	first, err := nodes[0].Lease("client1", 10, 6, "API_KEY_1", "/test", 50*time.Second)
+	if err != nil || !first.Granted {
+		t.Fatalf("Expected the first lease to be granted, got %+v, %v", first, err)
+	}
+
+	// The other node sees the first node's lease in the store
+	clock.Advance(31 * time.Second)
+	second, err := nodes[1].Lease("client1", 10, 6, "API_KEY_1", "/test", 50*time.Second)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if second.Granted {
+		t.Fatal("Expected leases on every node to be capped at the limit")
+	}
+	if second.RetryAfterMs != (19 * time.Second).Milliseconds() {
+		t.Errorf("Expected a retry hint until the first lease expires, got %d", second.RetryAfterMs)
+	}
+	if third, _ := nodes[1].Lease("client1", 10, 4, "API_KEY_1", "/test", 50*time.Second); !third.Granted {
+		t.Error("Expected a lease within the rest of the limit to be granted")
+	}
+
+	// Either node can return the first lease, once, freeing its room on
+	// every node
+	if _, err := nodes[1].Cancel(first.LeaseID); err != ErrReservationNotFound {
+		t.Errorf("Expected a lease not to settle as a reservation, got %v", err)
+	}
+	if _, err := nodes[1].ReturnLease(first.LeaseID, 10, 6); err != nil {
+		t.Fatalf("ReturnLease on the other node failed: %v", err)
+	}
+	if _, err := nodes[0].ReturnLease(first.LeaseID, 10, 6); err != ErrLeaseNotFound {
+		t.Errorf("Expected the returned lease to be gone on its own node, got %v", err)
+	}
+	if fourth, _ := nodes[1].Lease("client1", 10, 6, "API_KEY_1", "/test", 50*time.Second); !fourth.Granted {
+		t.Error("Expected the returned lease to make room on the other node")
+	}
+}
+
+func TestRedisStore_ConcurrentReservations(t *testing.T) {
+	server := miniredis.RunT(t)
+
+	nodes := []*Limiter{
+		newRedisLimiter(t, server.Addr(), AlgorithmFixedWindow),
+		newRedisLimiter(t, server.Addr(), AlgorithmFixedWindow),
+	}
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	allowed := 0
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(node *Limiter) {
+			defer wg.Done()
+			if node.Reserve("client1", 1, 1, "API_KEY_1", "/test").Allowed {
+				mutex.Lock()
+				allowed++
+				mutex.Unlock()
+			}
+		}(nodes[i%len(nodes)])
+	}
+	wg.Wait()
+
+	if allowed != 10 {
+		t.Errorf("Expected exactly 10 concurrent requests allowed, got %d", allowed)
+	}
+}
+
+func TestRedisStore_KeysExpire(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := newRedisLimiter(t, server.Addr(), AlgorithmFixedWindow)
+
+	if !limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test").Allowed {
+		t.Fatal("Expected request to be allowed")
+	}
+	if !server.Exists("test:API_KEY_1-/test") {
+		t.Fatal("Expected counters to be stored under the prefixed key")
+	}
+
+	server.FastForward(3 * time.Minute)
+	if server.Exists("test:API_KEY_1-/test") {
+		t.Error("Expected idle counters to expire")
+	}
+}
+
+func TestRedisStore_ClientLimits(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(server.Addr(), "")
+	defer client.Close()
+
+	limiter := newLimiter(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{
+					Path:         "/test",
+					RPM:          10,
+					TPM:          100,
+					ClientLimits: []ClientLimit{{ClientID: "noisy", RPM: 3, TPM: 100}},
+				},
+			},
+		},
+	}}, newRedisStore(client, "test:"))
+
+	allowed := 0
+	for i := 0; i < 5; i++ {
+		if limiter.Reserve("noisy", 1, 1, "API_KEY_1", "/test").Allowed {
+			allowed++
+		}
+	}
+	if allowed != 3 {
+		t.Errorf("Expected 3 requests allowed for the noisy client, got %d", allowed)
+	}
+
+	// A denied client reservation leaves the endpoint budget untouched
+	reservation := limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test")
+	if reservation.RemainingRequests != 6 {
+		t.Errorf("Expected 6 remaining requests on the endpoint, got %d", reservation.RemainingRequests)
+	}
+}
+
+func TestRedisStore_QuotaIsReadOnly(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := newRedisLimiter(t, server.Addr(), AlgorithmFixedWindow)
+
+	// Inspecting an unused key does not create it
+	if _, err := limiter.Quota("API_KEY_1", "/test", ""); err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	if server.Exists("test:" + Key("API_KEY_1", "/test")) {
+		t.Error("Expected quota inspection not to write the key")
+	}
+
+	limiter.Reserve("client1", 40, 3, "API_KEY_1", "/test")
+	stored, _ := server.Get("test:" + Key("API_KEY_1", "/test"))
+	quotas, err := limiter.Quota("API_KEY_1", "/test", "")
+	if err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	if quotas[0].Requests.Used != 3 || quotas[0].Tokens.Remaining != 60 {
+		t.Errorf("Expected 3 requests used and 60 tokens left, got %+v and %+v", quotas[0].Requests, quotas[0].Tokens)
+	}
+	if after, _ := server.Get("test:" + Key("API_KEY_1", "/test")); after != stored {
+		t.Error("Expected quota inspection to leave the stored counters untouched")
+	}
+}
+
+func TestRedisStore_GlobalLimitContention(t *testing.T) {
+	tests := []struct {
+		name   string
+		global LimitConfig
+		key    string
+	}{
+		{name: "Disabled", key: ""},
+		{name: "Single key", global: LimitConfig{RPM: 10000}, key: "test:_global"},
+		{name: "Sharded", global: LimitConfig{RPM: 10000, Shards: 4}, key: "test:_global#0"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := miniredis.RunT(t)
+			rateLimits := []RateLimit{{
+				APIKey:    "API_KEY_1",
+				Endpoints: []EndpointConfig{{Path: "/test", RPM: 10000, TPM: 100000}},
+			}}
+
+			var nodes []*Limiter
+			for i := 0; i < 4; i++ {
+				client := redis.NewClient(server.Addr(), "")
+				t.Cleanup(func() { client.Close() })
+				nodes = append(nodes, newLimiter(Config{GlobalLimit: tt.global, RateLimits: rateLimits}, newRedisStore(client, "test:")))
+			}
+
+			// Reservers on every node race on the global key; with capacity
+			// to spare, none may be denied by contention
+			var wg sync.WaitGroup
+			var mutex sync.Mutex
+			allowed := 0
+			for i := 0; i < 100; i++ {
+				wg.Add(1)
+				go func(node *Limiter, clientID string) {
+					defer wg.Done()
+					if node.Reserve(clientID, 1, 1, "API_KEY_1", "/test").Allowed {
+						mutex.Lock()
+						allowed++
+						mutex.Unlock()
+					}
+				}(nodes[i%len(nodes)], fmt.Sprintf("client%d", i))
+			}
+			wg.Wait()
+
+			if allowed != 100 {
+				t.Errorf("Expected all 100 concurrent requests allowed, got %d", allowed)
+			}
+			if tt.key == "" {
+				if keys := server.Keys(); len(keys) != 1 {
+					t.Errorf("Expected only the endpoint key without a global limit, got %v", keys)
+				}
+			} else if !server.Exists(tt.key) {
+				t.Errorf("Expected global counters under %s", tt.key)
+			}
+		})
+	}
+}
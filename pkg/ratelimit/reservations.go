@@ -0,0 +1,343 @@
+package ratelimit
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log"
+	"time"
+)
+
+// recordGrace is how long a pending reservation's record outlives its TTL
+// in a shared store, so the limiter that granted it still finds it to
+// refund when the TTL passes
+const recordGrace = 10 * time.Second
+
+// ErrReservationNotFound is returned for reservations that never existed,
+// were already committed or cancelled, or have expired
+var ErrReservationNotFound = errors.New("reservation not found")
+
+// pendingReservation is a reservation awaiting commit or cancellation, or
+// an outstanding lease
+type pendingReservation struct {
+	id             string
+	clientID       string
+	apiKey         string
+	targetEndpoint string
+	tokens         int
+	requests       int
+	limits         []Limit
+	takenAt        time.Time
+	// leased is set on leases, whose holds are dropped from the store's
+	// ledger when they are returned
+	leased    bool
+	expiresAt time.Time
+	timer     *time.Timer
+	// shared is set once the reservation's record is saved in the store,
+	// which then decides which limiter settles it
+	shared bool
+}
+
+// recordStore is implemented by stores shared between limiters. They keep
+// a record of each pending reservation, so that any limiter sharing the
+// store can settle it, and only once.
+type recordStore interface {
+	// putRecord saves the record of a pending reservation under id for ttl
+	putRecord(id, data string, ttl time.Duration) error
+	// takeRecord removes and returns the record under id, or "" if there
+	// is none
+	takeRecord(id string) (string, error)
+}
+
+// storedReservation is the serialized form of a pending reservation
+type storedReservation struct {
+	ClientID       string        `json:"clientID"`
+	APIKey         string        `json:"apiKey"`
+	TargetEndpoint string        `json:"targetEndpoint"`
+	Tokens         int           `json:"tokens"`
+	Requests       int           `json:"requests"`
+	Limits         []storedLimit `json:"limits"`
+	TakenAt        time.Time     `json:"takenAt"`
+	Leased         bool          `json:"leased,omitempty"`
+	ExpiresAt      time.Time     `json:"expiresAt,omitempty"`
+}
+
+// storedLimit is the serialized form of a limit a reservation was taken
+// from, with the budgets that decide whether it can still be settled
+type storedLimit struct {
+	Key       string `json:"key"`
+	RPM       int    `json:"rpm"`
+	TPM       int    `json:"tpm"`
+	Algorithm string `json:"algorithm"`
+}
+
+// Settlement describes how a pending reservation was closed
+type Settlement struct {
+	ReservationID    string `json:"reservationID"`
+	ReservedTokens   int    `json:"reservedTokens"`
+	ReservedRequests int    `json:"reservedRequests"`
+	CommittedTokens  int    `json:"committedTokens"`
+	RefundedTokens   int    `json:"refundedTokens"`
+	RefundedRequests int    `json:"refundedRequests"`
+}
+
+// SetReservationTTL sets how long allowed reservations stay pending. While
+// pending, a reservation can be committed with its actual token usage or
+// cancelled, and it is cancelled automatically when the TTL passes. A zero
+// TTL makes reservations final as soon as they are allowed.
+func (rl *Limiter) SetReservationTTL(ttl time.Duration) {
+	rl.reservationMutex.Lock()
+	defer rl.reservationMutex.Unlock()
+
+	rl.reservationTTL = ttl
+}
+
+// ReservationTTL returns how long allowed reservations stay pending, or
+// zero if they are final as soon as they are allowed
+func (rl *Limiter) ReservationTTL() time.Duration {
+	rl.reservationMutex.Lock()
+	defer rl.reservationMutex.Unlock()
+
+	return rl.reservationTTL
+}
+
+// track records an allowed reservation as pending if reservations have a
+// TTL, setting its ID and expiry
+func (rl *Limiter) track(reservation *Reservation, clientID, apiKey string, limits []Limit, takenAt time.Time) {
+	rl.trackFor(reservation, clientID, apiKey, limits, takenAt, 0)
+}
+
+// trackFor records an allowed reservation as pending like track, for the
+// reservation TTL or for hold if reservations have no TTL
+func (rl *Limiter) trackFor(reservation *Reservation, clientID, apiKey string, limits []Limit, takenAt time.Time, hold time.Duration) {
+	ttl := rl.ReservationTTL()
+	if ttl <= 0 {
+		ttl = hold
+	}
+	if ttl <= 0 {
+		return
+	}
+
+	pending := &pendingReservation{
+		id:             newReservationID(),
+		clientID:       clientID,
+		apiKey:         apiKey,
+		targetEndpoint: reservation.TargetEndpointPath,
+		tokens:         reservation.ReservedTokens,
+		requests:       reservation.ReservedRequests,
+		limits:         limits,
+		takenAt:        takenAt,
+	}
+	rl.share(pending.id, pending, ttl+recordGrace)
+
+	rl.reservationMutex.Lock()
+	defer rl.reservationMutex.Unlock()
+
+	pending.timer = time.AfterFunc(ttl, func() {
+		if _, err := rl.Cancel(pending.id); err == nil {
+			log.Printf("Reservation %s expired without commit, refunded to budget", pending.id)
+		}
+	})
+	rl.reservations[pending.id] = pending
+
+	expiresAt := takenAt.Add(ttl)
+	reservation.ReservationID = pending.id
+	reservation.ExpiresAt = &expiresAt
+}
+
+// Commit confirms a pending reservation with the tokens actually used.
+// Unused tokens are refunded; usage beyond the reservation is charged even
+// if it overdraws the budget, since it has already happened.
+func (rl *Limiter) Commit(id string, actualTokens int) (*Settlement, error) {
+	pending, err := rl.settle(id)
+	if err != nil {
+		return nil, err
+	}
+
+	settlement := &Settlement{
+		ReservationID:    id,
+		ReservedTokens:   pending.tokens,
+		ReservedRequests: pending.requests,
+		CommittedTokens:  actualTokens,
+	}
+
+	difference := pending.tokens - actualTokens
+	if difference > 0 {
+		settlement.RefundedTokens = difference
+	}
+	if err := rl.adjust(pending, difference, 0); err != nil {
+		return nil, err
+	}
+	return settlement, nil
+}
+
+// Cancel releases a pending reservation, refunding all of its tokens and
+// requests
+func (rl *Limiter) Cancel(id string) (*Settlement, error) {
+	pending, err := rl.settle(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := rl.adjust(pending, pending.tokens, pending.requests); err != nil {
+		return nil, err
+	}
+	return &Settlement{
+		ReservationID:    id,
+		ReservedTokens:   pending.tokens,
+		ReservedRequests: pending.requests,
+		RefundedTokens:   pending.tokens,
+		RefundedRequests: pending.requests,
+	}, nil
+}
+
+// settle removes a reservation from the pending set
+func (rl *Limiter) settle(id string) (*pendingReservation, error) {
+	rl.reservationMutex.Lock()
+	pending, exists := rl.reservations[id]
+	if exists {
+		delete(rl.reservations, id)
+		pending.timer.Stop()
+	}
+	rl.reservationMutex.Unlock()
+
+	return rl.claim(id, pending, ErrReservationNotFound)
+}
+
+// share saves the record of a pending reservation in a shared store under
+// id for ttl. If it cannot be saved, only this limiter can settle it.
+func (rl *Limiter) share(id string, pending *pendingReservation, ttl time.Duration) {
+	records, ok := rl.store.(recordStore)
+	if !ok {
+		return
+	}
+
+	stored := storedReservation{
+		ClientID:       pending.clientID,
+		APIKey:         pending.apiKey,
+		TargetEndpoint: pending.targetEndpoint,
+		Tokens:         pending.tokens,
+		Requests:       pending.requests,
+		TakenAt:        pending.takenAt,
+		Leased:         pending.leased,
+		ExpiresAt:      pending.expiresAt,
+	}
+	for _, limit := range pending.limits {
+		stored.Limits = append(stored.Limits, storedLimit{
+			Key:       limit.Key,
+			RPM:       limit.State.RPM,
+			TPM:       limit.State.TPM,
+			Algorithm: limit.State.Algorithm,
+		})
+	}
+	data, err := json.Marshal(stored)
+	if err == nil {
+		err = records.putRecord(id, string(data), ttl)
+	}
+	if err != nil {
+		log.Printf("Error saving reservation %s, only this node can settle it: %v", pending.id, err)
+		return
+	}
+	pending.shared = true
+}
+
+// claim takes the record under id from a shared store, so a pending
+// reservation is settled once however many limiters try. local is this
+// limiter's own copy, if it granted the reservation; otherwise the record
+// is decoded. notFound is returned if there is nothing left to settle.
+func (rl *Limiter) claim(id string, local *pendingReservation, notFound error) (*pendingReservation, error) {
+	records, ok := rl.store.(recordStore)
+	if !ok || (local != nil && !local.shared) {
+		if local == nil {
+			return nil, notFound
+		}
+		return local, nil
+	}
+
+	data, err := records.takeRecord(id)
+	if err != nil {
+		return nil, err
+	}
+	if data == "" {
+		return nil, notFound
+	}
+	if local != nil {
+		return local, nil
+	}
+
+	var stored storedReservation
+	if err := json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, err
+	}
+	pending := &pendingReservation{
+		id:             id,
+		clientID:       stored.ClientID,
+		apiKey:         stored.APIKey,
+		targetEndpoint: stored.TargetEndpoint,
+		tokens:         stored.Tokens,
+		requests:       stored.Requests,
+		takenAt:        stored.TakenAt,
+		leased:         stored.Leased,
+		expiresAt:      stored.ExpiresAt,
+	}
+	for _, limit := range stored.Limits {
+		pending.limits = append(pending.limits, Limit{
+			Key:   limit.Key,
+			State: &EndpointState{RPM: limit.RPM, TPM: limit.TPM, Algorithm: limit.Algorithm},
+		})
+	}
+	return pending, nil
+}
+
+// adjust refunds tokens and requests to a reservation's limits, or charges
+// extra tokens if tokens is negative. Limits still under the same key with
+// the same budgets kept their counters across reloads and are settled,
+// even if their route was rewritten; limits whose budgets changed since
+// the reservation started over with fresh counters and are left alone.
+func (rl *Limiter) adjust(pending *pendingReservation, tokens, requests int) error {
+	if tokens == 0 && requests == 0 && !pending.leased {
+		return nil
+	}
+
+	rl.mutex.RLock()
+	current, _ := rl.limits.chain(pending.apiKey, pending.targetEndpoint, pending.clientID)
+	rl.mutex.RUnlock()
+
+	var limits []Limit
+	for _, limit := range pending.limits {
+		for _, c := range current {
+			if c.Key == limit.Key && c.State.sameBudgets(limit.State) {
+				limits = append(limits, c)
+			}
+		}
+	}
+	if len(limits) == 0 {
+		return nil
+	}
+
+	return rl.store.Update(limits, func(counters []*Counters) bool {
+		now := rl.now()
+		for _, c := range counters {
+			if pending.leased {
+				c.Leases = releaseHold(c.Leases, pending.id, now)
+			}
+			if requests > 0 {
+				c.Requests.Refund(pending.takenAt, now, requests)
+			}
+			if tokens > 0 {
+				c.Tokens.Refund(pending.takenAt, now, tokens)
+			} else if tokens < 0 {
+				c.Tokens.Take(now, -tokens)
+			}
+		}
+		return true
+	})
+}
+
+// newReservationID returns a random reservation ID
+func newReservationID() string {
+	buf := make([]byte, 16)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
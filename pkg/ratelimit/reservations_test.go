@@ -0,0 +1,108 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newReservationLimiter(ttl time.Duration) *Limiter {
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{Path: "/test", RPM: 10, TPM: 100},
+			},
+		},
+	}})
+	limiter.SetReservationTTL(ttl)
+	return limiter
+}
+
+func TestLimiter_CommitRefundsUnusedTokens(t *testing.T) {
+	limiter := newReservationLimiter(time.Minute)
+
+	reservation := limiter.Reserve("client1", 80, 1, "API_KEY_1", "/test")
+	if !reservation.Allowed || reservation.ReservationID == "" || reservation.ExpiresAt == nil {
+		t.Fatalf("Expected an allowed pending reservation, got %+v", reservation)
+	}
+
+	settlement, err := limiter.Commit(reservation.ReservationID, 30)
+	if err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+	if settlement.RefundedTokens != 50 {
+		t.Errorf("Expected 50 refunded tokens, got %d", settlement.RefundedTokens)
+	}
+
+	// The request stays charged; only the unused tokens come back
+	next := limiter.Reserve("client1", 70, 1, "API_KEY_1", "/test")
+	if !next.Allowed || next.RemainingTokens != 0 || next.RemainingRequests != 8 {
+		t.Errorf("Expected 0 tokens and 8 requests left, got %+v", next)
+	}
+
+	if _, err := limiter.Commit(reservation.ReservationID, 30); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Expected ErrReservationNotFound on second commit, got %v", err)
+	}
+}
+
+func TestLimiter_CommitChargesOverage(t *testing.T) {
+	limiter := newReservationLimiter(time.Minute)
+
+	reservation := limiter.Reserve("client1", 50, 1, "API_KEY_1", "/test")
+	if _, err := limiter.Commit(reservation.ReservationID, 120); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	// Usage beyond the reservation is charged even past the limit
+	if next := limiter.Reserve("client1", 1, 1, "API_KEY_1", "/test"); next.Allowed {
+		t.Errorf("Expected denial after overdrawing the token budget, got %+v", next)
+	}
+}
+
+func TestLimiter_CancelRefundsEverything(t *testing.T) {
+	limiter := newReservationLimiter(time.Minute)
+
+	reservation := limiter.Reserve("client1", 100, 10, "API_KEY_1", "/test")
+	settlement, err := limiter.Cancel(reservation.ReservationID)
+	if err != nil {
+		t.Fatalf("Cancel failed: %v", err)
+	}
+	if settlement.RefundedTokens != 100 || settlement.RefundedRequests != 10 {
+		t.Errorf("Expected a full refund, got %+v", settlement)
+	}
+
+	if next := limiter.Reserve("client1", 100, 10, "API_KEY_1", "/test"); !next.Allowed {
+		t.Errorf("Expected the full budget back after cancel, got %+v", next)
+	}
+}
+
+func TestLimiter_ReservationsExpire(t *testing.T) {
+	limiter := newReservationLimiter(20 * time.Millisecond)
+
+	reservation := limiter.Reserve("client1", 100, 10, "API_KEY_1", "/test")
+	if !reservation.Allowed {
+		t.Fatal("Expected reservation to be allowed")
+	}
+
+	deadline := time.Now().Add(time.Second)
+	for !limiter.Reserve("client1", 100, 10, "API_KEY_1", "/test").Allowed {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected the uncommitted reservation to be refunded after its TTL")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	if _, err := limiter.Commit(reservation.ReservationID, 10); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Expected ErrReservationNotFound for an expired reservation, got %v", err)
+	}
+}
+
+func TestLimiter_ReservationsDisabledByDefault(t *testing.T) {
+	limiter := newReservationLimiter(0)
+
+	reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+	if reservation.ReservationID != "" || reservation.ExpiresAt != nil {
+		t.Errorf("Expected no reservation ID without a TTL, got %+v", reservation)
+	}
+}
@@ -0,0 +1,348 @@
+package ratelimit
+
+import (
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+)
+
+// Route returns the endpoint's identity: its path pattern, preceded by its
+// methods if it has any, as in "GET,HEAD /users/:id". Endpoints are
+// reported under their route.
+func (e EndpointConfig) Route() string {
+	return withMethods(e.Methods, e.Path)
+}
+
+// storeKey returns the part of the store keys of the endpoint's counters
+// that identifies it: its route with path parameter names dropped, as in
+// "GET,HEAD /users/:". Reordering methods or renaming parameters keeps the
+// counters, while adding or removing a method starts them over.
+func (e EndpointConfig) storeKey() string {
+	return withMethods(e.Methods, "/"+patternKey(e.Path))
+}
+
+// withMethods prefixes a path with its normalized methods, if any
+func withMethods(methods []string, path string) string {
+	normalized := normalizeMethods(methods)
+	if len(normalized) == 0 {
+		return path
+	}
+	return strings.Join(normalized, ",") + " " + path
+}
+
+// normalizeMethods upper-cases, sorts and deduplicates methods
+func normalizeMethods(methods []string) []string {
+	if len(methods) == 0 {
+		return nil
+	}
+	seen := make(map[string]bool, len(methods))
+	normalized := make([]string, 0, len(methods))
+	for _, method := range methods {
+		method = strings.ToUpper(method)
+		if !seen[method] {
+			seen[method] = true
+			normalized = append(normalized, method)
+		}
+	}
+	sort.Strings(normalized)
+	return normalized
+}
+
+// parseTarget splits a target endpoint of the form "METHOD /path" into its
+// method and path. Targets without a method are returned whole as the
+// path.
+func parseTarget(target string) (string, string) {
+	i := strings.IndexByte(target, ' ')
+	if i <= 0 || !isMethod(target[:i]) {
+		return "", target
+	}
+	return strings.ToUpper(target[:i]), strings.TrimLeft(target[i+1:], " ")
+}
+
+// isMethod reports whether s looks like an HTTP method
+func isMethod(s string) bool {
+	for _, r := range s {
+		if (r < 'A' || r > 'Z') && (r < 'a' || r > 'z') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// Kinds of pattern segment, in order of precedence
+const (
+	segmentStatic = iota
+	segmentGlob
+	segmentParam
+	segmentCatchAll
+)
+
+// segmentKind classifies a segment of a path pattern. A lone * matches the
+// rest of the path when it is the last segment; elsewhere it is a glob
+// matching any one segment.
+func segmentKind(segment string, last bool) int {
+	switch {
+	case segment == "*" && last:
+		return segmentCatchAll
+	case strings.HasPrefix(segment, ":"):
+		return segmentParam
+	case strings.ContainsAny(segment, "*?["):
+		return segmentGlob
+	default:
+		return segmentStatic
+	}
+}
+
+// splitPath splits a path into its segments, without the leading slash
+func splitPath(p string) []string {
+	return strings.Split(strings.TrimPrefix(p, "/"), "/")
+}
+
+// validatePattern checks that a path pattern can be compiled
+func validatePattern(pattern string) error {
+	segments := splitPath(pattern)
+	for i, segment := range segments {
+		switch segmentKind(segment, i == len(segments)-1) {
+		case segmentParam:
+			if segment == ":" {
+				return fmt.Errorf("path parameter in %s needs a name", pattern)
+			}
+		case segmentGlob:
+			if _, err := path.Match(segment, ""); err != nil {
+				return fmt.Errorf("invalid glob %q in %s", segment, pattern)
+			}
+		}
+	}
+	return nil
+}
+
+// patternKey returns a pattern with its parameter names dropped, so
+// patterns that match the same paths compare equal
+func patternKey(pattern string) string {
+	segments := splitPath(pattern)
+	for i, segment := range segments {
+		if segmentKind(segment, i == len(segments)-1) == segmentParam {
+			segments[i] = ":"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// validateRoutes checks an API key's endpoints for invalid patterns and
+// methods, and for endpoints that would match the same requests
+func validateRoutes(endpoints []EndpointConfig) error {
+	type claim struct {
+		pattern string
+		method  string
+	}
+	claimed := make(map[claim]string)
+
+	for _, endpoint := range endpoints {
+		if err := validatePattern(endpoint.Path); err != nil {
+			return fmt.Errorf("endpoint %s: %v", endpoint.Path, err)
+		}
+		methods := normalizeMethods(endpoint.Methods)
+		for _, method := range methods {
+			if !isMethod(method) {
+				return fmt.Errorf("endpoint %s: invalid method %q", endpoint.Path, method)
+			}
+		}
+		if len(methods) == 0 {
+			methods = []string{""}
+		}
+
+		key := patternKey(endpoint.Path)
+		for _, method := range methods {
+			if other, exists := claimed[claim{key, method}]; exists {
+				return fmt.Errorf("endpoint %s: overlaps endpoint %s", endpoint.Route(), other)
+			}
+			claimed[claim{key, method}] = endpoint.Route()
+		}
+	}
+	return nil
+}
+
+// router matches target endpoints against an API key's endpoint patterns.
+// It is a radix tree over path segments, matched from the left: at each
+// segment a static match beats a glob, a glob beats a parameter and a
+// parameter beats a trailing wildcard, falling back to the next kind if
+// the rest of the path does not match. Among endpoints on the same
+// pattern, those listing the target's method beat those without methods.
+type router struct {
+	root *routeNode
+}
+
+// routeNode is a segment of one or more patterns
+type routeNode struct {
+	static   map[string]*routeNode
+	globs    []*globNode
+	param    *routeNode
+	catchAll *routeNode
+	entries  []*routeEntry
+}
+
+// globNode is a glob segment and the patterns continuing after it
+type globNode struct {
+	pattern string
+	node    *routeNode
+}
+
+// routeEntry is an endpoint ending at a node
+type routeEntry struct {
+	route   string
+	methods map[string]bool
+	state   *EndpointState
+}
+
+// newRouter compiles the endpoints of an API key, given by route in
+// configuration order
+func newRouter(routes []string, endpoints map[string]*EndpointState) *router {
+	r := &router{root: newRouteNode()}
+	for _, route := range routes {
+		state := endpoints[route]
+		node := r.root
+		segments := splitPath(state.Path)
+		for i, segment := range segments {
+			node = node.child(segment, segmentKind(segment, i == len(segments)-1))
+		}
+
+		entry := &routeEntry{route: route, state: state}
+		if methods := normalizeMethods(state.endpoint.Methods); len(methods) > 0 {
+			entry.methods = make(map[string]bool, len(methods))
+			for _, method := range methods {
+				entry.methods[method] = true
+			}
+		}
+		node.entries = append(node.entries, entry)
+	}
+
+	r.root.sort()
+	return r
+}
+
+func newRouteNode() *routeNode {
+	return &routeNode{static: make(map[string]*routeNode)}
+}
+
+// child returns the node for segment under n, adding it if needed
+func (n *routeNode) child(segment string, kind int) *routeNode {
+	switch kind {
+	case segmentCatchAll:
+		if n.catchAll == nil {
+			n.catchAll = newRouteNode()
+		}
+		return n.catchAll
+	case segmentParam:
+		if n.param == nil {
+			n.param = newRouteNode()
+		}
+		return n.param
+	case segmentGlob:
+		for _, glob := range n.globs {
+			if glob.pattern == segment {
+				return glob.node
+			}
+		}
+		glob := &globNode{pattern: segment, node: newRouteNode()}
+		n.globs = append(n.globs, glob)
+		return glob.node
+	default:
+		child, exists := n.static[segment]
+		if !exists {
+			child = newRouteNode()
+			n.static[segment] = child
+		}
+		return child
+	}
+}
+
+// sort orders globs and entries by precedence throughout the tree. Globs
+// with more literal characters are more specific; ties go to the pattern
+// that sorts first. Entries with methods come before those without, each
+// in configuration order.
+func (n *routeNode) sort() {
+	sort.SliceStable(n.globs, func(i, j int) bool {
+		li, lj := literalLength(n.globs[i].pattern), literalLength(n.globs[j].pattern)
+		if li != lj {
+			return li > lj
+		}
+		return n.globs[i].pattern < n.globs[j].pattern
+	})
+	sort.SliceStable(n.entries, func(i, j int) bool {
+		return n.entries[i].methods != nil && n.entries[j].methods == nil
+	})
+
+	for _, child := range n.static {
+		child.sort()
+	}
+	for _, glob := range n.globs {
+		glob.node.sort()
+	}
+	if n.param != nil {
+		n.param.sort()
+	}
+	if n.catchAll != nil {
+		n.catchAll.sort()
+	}
+}
+
+// literalLength counts the characters of a glob that match only themselves
+func literalLength(glob string) int {
+	return len(glob) - strings.Count(glob, "*") - strings.Count(glob, "?")
+}
+
+// match returns the endpoint a target endpoint is limited under, or nil if
+// none matches
+func (r *router) match(target string) *routeEntry {
+	method, p := parseTarget(target)
+	return r.root.match(splitPath(p), method)
+}
+
+// match matches the remaining segments below n
+func (n *routeNode) match(segments []string, method string) *routeEntry {
+	if len(segments) == 0 {
+		if entry := n.entry(method); entry != nil {
+			return entry
+		}
+		// A trailing wildcard also matches nothing at all
+		if n.catchAll != nil {
+			return n.catchAll.entry(method)
+		}
+		return nil
+	}
+
+	segment, rest := segments[0], segments[1:]
+	if child, exists := n.static[segment]; exists {
+		if entry := child.match(rest, method); entry != nil {
+			return entry
+		}
+	}
+	for _, glob := range n.globs {
+		if matched, _ := path.Match(glob.pattern, segment); matched {
+			if entry := glob.node.match(rest, method); entry != nil {
+				return entry
+			}
+		}
+	}
+	if n.param != nil && segment != "" {
+		if entry := n.param.match(rest, method); entry != nil {
+			return entry
+		}
+	}
+	if n.catchAll != nil {
+		return n.catchAll.entry(method)
+	}
+	return nil
+}
+
+// entry returns the endpoint ending at n that applies to method. Targets
+// without a method only match endpoints without methods.
+func (n *routeNode) entry(method string) *routeEntry {
+	for _, entry := range n.entries {
+		if entry.methods == nil || entry.methods[method] {
+			return entry
+		}
+	}
+	return nil
+}
@@ -0,0 +1,240 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/yourusername/ratelimiter/internal/redis"
+)
+
+func routeLimits() []RateLimit {
+	return []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{Path: "/users/:id", RPM: 2, TPM: 100},
+				{Path: "/users/me", RPM: 10, TPM: 100},
+				{Path: "/users/:id", Methods: []string{"post", "PUT"}, RPM: 1, TPM: 100},
+				{Path: "/users/:id/posts/*", RPM: 10, TPM: 100},
+				{Path: "/files/*.png", RPM: 10, TPM: 100},
+				{Path: "/files/*", RPM: 10, TPM: 100},
+				{Path: "/files/logo-*.png", RPM: 10, TPM: 100},
+				{Path: "/reports/:year/summary", RPM: 10, TPM: 100},
+				{Path: "/reports/2024/:section", RPM: 10, TPM: 100},
+			},
+		},
+	}
+}
+
+func TestLimiter_Resolve(t *testing.T) {
+	limiter := MustNew(Config{RateLimits: routeLimits()})
+
+	tests := []struct {
+		name   string
+		target string
+		want   string
+	}{
+		{name: "Path parameter", target: "/users/42", want: "/users/:id"},
+		{name: "Static beats parameter", target: "/users/me", want: "/users/me"},
+		{name: "Route named exactly", target: "/users/:id", want: "/users/:id"},
+		{name: "Listed method beats no methods", target: "PUT /users/42", want: "POST,PUT /users/:id"},
+		{name: "Lowercase method", target: "post /users/42", want: "POST,PUT /users/:id"},
+		{name: "Unlisted method", target: "GET /users/42", want: "/users/:id"},
+		{name: "Method with static path", target: "POST /users/me", want: "/users/me"},
+		{name: "Empty parameter", target: "/users/", want: "/users/"},
+		{name: "Catch-all", target: "/users/42/posts/7/comments", want: "/users/:id/posts/*"},
+		{name: "Catch-all matches nothing", target: "/users/42/posts", want: "/users/:id/posts/*"},
+		{name: "Longer glob wins", target: "/files/logo-dark.png", want: "/files/logo-*.png"},
+		{name: "Glob", target: "/files/photo.png", want: "/files/*.png"},
+		{name: "Glob falls back to catch-all", target: "/files/notes.txt", want: "/files/*"},
+		{name: "Leftmost static segment wins", target: "/reports/2024/summary", want: "/reports/2024/:section"},
+		{name: "Backtracks to parameter", target: "/reports/2023/summary", want: "/reports/:year/summary"},
+		{name: "No match", target: "/orders/1", want: "/orders/1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := limiter.Resolve("API_KEY_1", tt.target); got != tt.want {
+				t.Errorf("Expected %s to resolve to %s, got %s", tt.target, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestLimiter_RoutePatternsShareLimits(t *testing.T) {
+	limiter := MustNew(Config{RateLimits: routeLimits()})
+
+	steps := []struct {
+		target string
+		want   bool
+	}{
+		{target: "/users/1", want: true},
+		{target: "GET /users/2", want: true},
+		{target: "/users/3", want: false},
+		{target: "POST /users/1", want: true},
+		{target: "PUT /users/2", want: false},
+		{target: "/users/me", want: true},
+	}
+
+	for i, step := range steps {
+		reservation := limiter.Reserve("client1", 1, 1, "API_KEY_1", step.target)
+		if reservation.Allowed != step.want {
+			t.Fatalf("Step %d (%s): expected allowed %v, got %v", i, step.target, step.want, reservation.Allowed)
+		}
+		if reservation.Allowed && reservation.TargetEndpointPath != step.target {
+			t.Errorf("Step %d: expected target %s, got %s", i, step.target, reservation.TargetEndpointPath)
+		}
+	}
+
+	quotas, err := limiter.Quota("API_KEY_1", "PUT /users/9", "")
+	if err != nil {
+		t.Fatalf("Failed to get quota: %v", err)
+	}
+	last := quotas[len(quotas)-1]
+	if last.Path != "/users/:id" || len(last.Methods) != 2 || last.Requests.Used != 1 {
+		t.Errorf("Expected the POST,PUT /users/:id quota with 1 request used, got %+v", last)
+	}
+}
+
+func TestLimiter_RouteChangesKeepCounters(t *testing.T) {
+	tests := []struct {
+		name     string
+		before   EndpointConfig
+		after    EndpointConfig
+		wantKept bool
+	}{
+		{
+			name:     "Reordered methods",
+			before:   EndpointConfig{Path: "/users/:id", Methods: []string{"GET", "POST"}, RPM: 2, TPM: 100},
+			after:    EndpointConfig{Path: "/users/:id", Methods: []string{"post", "GET"}, RPM: 2, TPM: 100},
+			wantKept: true,
+		},
+		{
+			name:     "Renamed parameter",
+			before:   EndpointConfig{Path: "/users/:id", Methods: []string{"GET"}, RPM: 2, TPM: 100},
+			after:    EndpointConfig{Path: "/users/:userID", Methods: []string{"GET"}, RPM: 2, TPM: 100},
+			wantKept: true,
+		},
+		{
+			name:   "Added method",
+			before: EndpointConfig{Path: "/users/:id", Methods: []string{"GET"}, RPM: 2, TPM: 100},
+			after:  EndpointConfig{Path: "/users/:id", Methods: []string{"GET", "POST"}, RPM: 2, TPM: 100},
+		},
+	}
+
+	for _, tt := range tests {
+		stores := []struct {
+			name  string
+			store func(t *testing.T) Store
+		}{
+			{name: "memory", store: func(t *testing.T) Store { return NewMemoryStore() }},
+			{name: "redis", store: func(t *testing.T) Store {
+				client := redis.NewClient(miniredis.RunT(t).Addr(), "")
+				t.Cleanup(func() { client.Close() })
+				return newRedisStore(client, "test:")
+			}},
+		}
+		for _, store := range stores {
+			t.Run(tt.name+"/"+store.name, func(t *testing.T) {
+				limits := func(endpoint EndpointConfig) []RateLimit {
+					return []RateLimit{{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{endpoint}}}
+				}
+				limiter := newLimiter(Config{RateLimits: limits(tt.before)}, store.store(t))
+				for i := 0; i < 2; i++ {
+					limiter.Reserve("client1", 1, 1, "API_KEY_1", "GET /users/1")
+				}
+
+				limiter.UpdateLimits(LimitConfig{}, limits(tt.after))
+				allowed := limiter.Reserve("client1", 1, 1, "API_KEY_1", "GET /users/1").Allowed
+				if allowed == tt.wantKept {
+					t.Errorf("Expected counters kept %v, got allowed %v after the update", tt.wantKept, allowed)
+				}
+			})
+		}
+	}
+}
+
+func TestLimiter_CancelAfterRouteRename(t *testing.T) {
+	limits := func(path string) []RateLimit {
+		return []RateLimit{{APIKey: "API_KEY_1", Endpoints: []EndpointConfig{{Path: path, RPM: 1, TPM: 100}}}}
+	}
+	limiter := MustNew(Config{RateLimits: limits("/users/:id")})
+	limiter.SetReservationTTL(time.Minute)
+
+	reservation := limiter.Reserve("client1", 10, 1, "API_KEY_1", "/users/1")
+	if !reservation.Allowed {
+		t.Fatal("Expected the first reservation to be allowed")
+	}
+
+	// Renaming the parameter keeps the counters, so cancelling refunds
+	// them and the budget is free again
+	limiter.UpdateLimits(LimitConfig{}, limits("/users/:userID"))
+	if _, err := limiter.Cancel(reservation.ReservationID); err != nil {
+		t.Fatalf("Failed to cancel reservation: %v", err)
+	}
+	if !limiter.Reserve("client1", 10, 1, "API_KEY_1", "/users/1").Allowed {
+		t.Error("Expected the cancelled reservation to be refunded after the rename")
+	}
+}
+
+func TestConfig_ValidateRoutes(t *testing.T) {
+	tests := []struct {
+		name      string
+		endpoints []EndpointConfig
+		wantErr   bool
+	}{
+		{
+			name:      "Patterns",
+			endpoints: routeLimits()[0].Endpoints,
+		},
+		{
+			name:      "Unnamed parameter",
+			endpoints: []EndpointConfig{{Path: "/users/:", RPM: 1}},
+			wantErr:   true,
+		},
+		{
+			name:      "Malformed glob",
+			endpoints: []EndpointConfig{{Path: "/files/[a-", RPM: 1}},
+			wantErr:   true,
+		},
+		{
+			name:      "Invalid method",
+			endpoints: []EndpointConfig{{Path: "/users", Methods: []string{"GET /"}, RPM: 1}},
+			wantErr:   true,
+		},
+		{
+			name: "Same pattern under another parameter name",
+			endpoints: []EndpointConfig{
+				{Path: "/users/:id", RPM: 1},
+				{Path: "/users/:name", RPM: 1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Overlapping methods",
+			endpoints: []EndpointConfig{
+				{Path: "/users/:id", Methods: []string{"GET", "POST"}, RPM: 1},
+				{Path: "/users/:id", Methods: []string{"post"}, RPM: 1},
+			},
+			wantErr: true,
+		},
+		{
+			name: "Disjoint methods",
+			endpoints: []EndpointConfig{
+				{Path: "/users/:id", Methods: []string{"GET"}, RPM: 1},
+				{Path: "/users/:id", Methods: []string{"POST"}, RPM: 1},
+				{Path: "/users/:id", RPM: 1},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Config{RateLimits: []RateLimit{{APIKey: "API_KEY_1", Endpoints: tt.endpoints}}}.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
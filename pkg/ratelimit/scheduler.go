@@ -0,0 +1,217 @@
+package ratelimit
+
+import (
+	"context"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Background pool defaults
+const (
+	defaultWorkers   = 1
+	defaultQueueSize = 100
+	defaultWeight    = 1
+)
+
+// Scheduler dispatches allowed reservations according to the dispatch
+// policy of their API key's priority class
+type Scheduler struct {
+	// outstanding counts reservations dispatched but not yet processed
+	outstanding int64
+	running     sync.WaitGroup
+
+	classes  map[string]int
+	policies map[int]DispatchPolicy
+	pools    map[int]*workerPool
+	process  func(*Reservation)
+	closed   bool
+	mutex    sync.RWMutex
+
+	// delayed holds the timers of delayed reservations that have not fired
+	delayed      map[*time.Timer]*Reservation
+	delayedMutex sync.Mutex
+}
+
+// workerPool processes queued reservations with a fixed number of workers
+type workerPool struct {
+	queue chan *Reservation
+}
+
+// NewScheduler creates a new Scheduler instance that hands reservations to
+// process. Until priorities are set every reservation is immediate.
+func NewScheduler(process func(*Reservation)) *Scheduler {
+	return &Scheduler{
+		classes:  make(map[string]int),
+		policies: make(map[int]DispatchPolicy),
+		pools:    make(map[int]*workerPool),
+		process:  process,
+		delayed:  make(map[*time.Timer]*Reservation),
+	}
+}
+
+// UpdatePriorities replaces the priority classes and dispatch policies.
+// Background pools from the previous policies finish their queues.
+func (s *Scheduler) UpdatePriorities(classes map[string]int, policies map[int]DispatchPolicy) {
+	pools := make(map[int]*workerPool)
+	for class, policy := range policies {
+		if policy.Mode == DispatchBackground {
+			pools[class] = newWorkerPool(policy, s.run)
+		}
+	}
+
+	s.mutex.Lock()
+	old := s.pools
+	s.classes = classes
+	s.policies = policies
+	s.pools = pools
+	s.mutex.Unlock()
+
+	for _, pool := range old {
+		close(pool.queue)
+	}
+}
+
+// Class returns the priority class of an API key and whether it has one
+func (s *Scheduler) Class(apiKey string) (int, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	class, exists := s.classes[apiKey]
+	return class, exists
+}
+
+// Weight returns the share of freed capacity a priority class gets in wait
+// queues relative to the other classes waiting
+func (s *Scheduler) Weight(class int) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if weight := s.policies[class].Weight; weight > 0 {
+		return weight
+	}
+	return defaultWeight
+}
+
+// Dispatch processes reservation according to apiKey's dispatch policy.
+// Reservations dispatched after Shutdown are dropped.
+func (s *Scheduler) Dispatch(apiKey string, reservation *Reservation) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		log.Printf("Scheduler is shut down, dropping reservation for %s", reservation.TargetEndpointPath)
+		return
+	}
+	s.start()
+
+	class, exists := s.classes[apiKey]
+	if !exists {
+		go s.run(reservation)
+		return
+	}
+
+	policy := s.policies[class]
+	switch policy.Mode {
+	case DispatchDelayed:
+		s.delayedMutex.Lock()
+		var timer *time.Timer
+		timer = time.AfterFunc(policy.Delay, func() {
+			s.delayedMutex.Lock()
+			delete(s.delayed, timer)
+			s.delayedMutex.Unlock()
+			s.run(reservation)
+		})
+		s.delayed[timer] = reservation
+		s.delayedMutex.Unlock()
+	case DispatchBackground:
+		select {
+		case s.pools[class].queue <- reservation:
+		default:
+			s.finish()
+			log.Printf("Background queue for priority class %d is full, dropping reservation for %s", class, reservation.TargetEndpointPath)
+		}
+	default:
+		go s.run(reservation)
+	}
+}
+
+// Close stops every background pool once its queue is drained
+func (s *Scheduler) Close() {
+	s.UpdatePriorities(map[string]int{}, map[int]DispatchPolicy{})
+}
+
+// Shutdown stops accepting reservations and waits until every reservation
+// already dispatched has been processed, or until ctx is done. Delayed
+// reservations are processed right away rather than after their delay. It
+// returns how many reservations were left unprocessed.
+func (s *Scheduler) Shutdown(ctx context.Context) int {
+	s.mutex.Lock()
+	s.closed = true
+	s.mutex.Unlock()
+
+	s.delayedMutex.Lock()
+	for timer, reservation := range s.delayed {
+		// A timer that already fired is processing its reservation
+		if timer.Stop() {
+			go s.run(reservation)
+		}
+		delete(s.delayed, timer)
+	}
+	s.delayedMutex.Unlock()
+
+	s.Close()
+
+	done := make(chan struct{})
+	go func() {
+		s.running.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return 0
+	case <-ctx.Done():
+		return int(atomic.LoadInt64(&s.outstanding))
+	}
+}
+
+// start records a dispatched reservation as outstanding
+func (s *Scheduler) start() {
+	s.running.Add(1)
+	atomic.AddInt64(&s.outstanding, 1)
+}
+
+// finish records an outstanding reservation as processed or dropped
+func (s *Scheduler) finish() {
+	atomic.AddInt64(&s.outstanding, -1)
+	s.running.Done()
+}
+
+// run processes an outstanding reservation
+func (s *Scheduler) run(reservation *Reservation) {
+	defer s.finish()
+	s.process(reservation)
+}
+
+// newWorkerPool starts the workers for a background policy
+func newWorkerPool(policy DispatchPolicy, process func(*Reservation)) *workerPool {
+	workers := policy.Workers
+	if workers == 0 {
+		workers = defaultWorkers
+	}
+	queueSize := policy.QueueSize
+	if queueSize == 0 {
+		queueSize = defaultQueueSize
+	}
+
+	pool := &workerPool{queue: make(chan *Reservation, queueSize)}
+	for i := 0; i < workers; i++ {
+		go func() {
+			for reservation := range pool.queue {
+				process(reservation)
+			}
+		}()
+	}
+	return pool
+}
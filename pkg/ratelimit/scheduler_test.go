@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Dispatch(t *testing.T) {
+	processed := make(chan *Reservation, 10)
+	scheduler := NewScheduler(func(reservation *Reservation) {
+		processed <- reservation
+	})
+	defer scheduler.Close()
+
+	scheduler.UpdatePriorities(
+		map[string]int{
+			"IMMEDIATE_KEY":  1,
+			"DELAYED_KEY":    2,
+			"BACKGROUND_KEY": 3,
+		},
+		map[int]DispatchPolicy{
+			1: {Mode: DispatchImmediate},
+			2: {Mode: DispatchDelayed, Delay: 200 * time.Millisecond},
+			3: {Mode: DispatchBackground, Workers: 2},
+		},
+	)
+
+	tests := []struct {
+		name    string
+		apiKey  string
+		minWait time.Duration
+	}{
+		{name: "Immediate class", apiKey: "IMMEDIATE_KEY"},
+		{name: "Delayed class", apiKey: "DELAYED_KEY", minWait: 200 * time.Millisecond},
+		{name: "Background class", apiKey: "BACKGROUND_KEY"},
+		{name: "Key without a class", apiKey: "OTHER_KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reservation := &Reservation{Allowed: true, TargetEndpointPath: tt.apiKey}
+			start := time.Now()
+			scheduler.Dispatch(tt.apiKey, reservation)
+
+			select {
+			case got := <-processed:
+				if got != reservation {
+					t.Fatal("Expected the dispatched reservation to be processed")
+				}
+				if elapsed := time.Since(start); elapsed < tt.minWait {
+					t.Errorf("Expected processing after at least %v, got %v", tt.minWait, elapsed)
+				}
+			case <-time.After(2 * time.Second):
+				t.Fatal("Expected the reservation to be processed")
+			}
+		})
+	}
+}
+
+func TestScheduler_Class(t *testing.T) {
+	scheduler := NewScheduler(func(*Reservation) {})
+	defer scheduler.Close()
+
+	scheduler.UpdatePriorities(map[string]int{"API_KEY_1": 1}, nil)
+
+	if class, ok := scheduler.Class("API_KEY_1"); !ok || class != 1 {
+		t.Errorf("Expected class 1, got %d (%v)", class, ok)
+	}
+	if _, ok := scheduler.Class("API_KEY_2"); ok {
+		t.Error("Expected no class for an unconfigured key")
+	}
+}
+
+func TestScheduler_ShutdownDrains(t *testing.T) {
+	processed := make(chan *Reservation, 10)
+	scheduler := NewScheduler(func(reservation *Reservation) {
+		processed <- reservation
+	})
+	scheduler.UpdatePriorities(
+		map[string]int{"DELAYED_KEY": 1, "BACKGROUND_KEY": 2},
+		map[int]DispatchPolicy{
+			1: {Mode: DispatchDelayed, Delay: time.Hour},
+			2: {Mode: DispatchBackground},
+		},
+	)
+
+	scheduler.Dispatch("DELAYED_KEY", &Reservation{Allowed: true})
+	scheduler.Dispatch("BACKGROUND_KEY", &Reservation{Allowed: true})
+	scheduler.Dispatch("OTHER_KEY", &Reservation{Allowed: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	// Delayed reservations are processed right away instead of after an hour
+	if unprocessed := scheduler.Shutdown(ctx); unprocessed != 0 {
+		t.Errorf("Expected every reservation to be processed, got %d left", unprocessed)
+	}
+
+	// Reservations dispatched after shutdown are dropped
+	scheduler.Dispatch("OTHER_KEY", &Reservation{Allowed: true})
+	time.Sleep(50 * time.Millisecond)
+	if len(processed) != 3 {
+		t.Errorf("Expected 3 processed reservations, got %d", len(processed))
+	}
+}
+
+func TestScheduler_ShutdownDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	scheduler := NewScheduler(func(*Reservation) {
+		<-release
+	})
+
+	scheduler.Dispatch("API_KEY_1", &Reservation{Allowed: true})
+	scheduler.Dispatch("API_KEY_1", &Reservation{Allowed: true})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if unprocessed := scheduler.Shutdown(ctx); unprocessed != 2 {
+		t.Errorf("Expected 2 reservations left unprocessed at the deadline, got %d", unprocessed)
+	}
+}
@@ -0,0 +1,47 @@
+package ratelimit
+
+import "context"
+
+// ShutdownReport describes the work a shutdown left unfinished
+type ShutdownReport struct {
+	// Unprocessed counts allowed reservations whose processing had not
+	// finished when the shutdown deadline passed
+	Unprocessed int
+	// Pending counts reservations that were neither committed nor
+	// cancelled. Their usage stays charged until their windows pass.
+	Pending int
+	// Leases counts leases that were never returned. Their usage stays
+	// charged too.
+	Leases int
+}
+
+// Shutdown stops processing new reservations and waits for those already
+// allowed to be processed, until ctx is done. Pending reservations are
+// dropped without a refund, since their holders may already have spent
+// them, and so are outstanding leases. Reservations recorded in a shared
+// store can still be settled by other limiters sharing it. Reservations made after Shutdown
+// are still counted but never processed.
+func (rl *Limiter) Shutdown(ctx context.Context) ShutdownReport {
+	report := ShutdownReport{
+		Unprocessed: rl.scheduler.Shutdown(ctx),
+	}
+
+	rl.reservationMutex.Lock()
+	defer rl.reservationMutex.Unlock()
+
+	for id, pending := range rl.reservations {
+		pending.timer.Stop()
+		delete(rl.reservations, id)
+		report.Pending++
+	}
+
+	rl.leaseMutex.Lock()
+	defer rl.leaseMutex.Unlock()
+
+	for id, pending := range rl.leases {
+		pending.timer.Stop()
+		delete(rl.leases, id)
+		report.Leases++
+	}
+	return report
+}
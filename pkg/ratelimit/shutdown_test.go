@@ -0,0 +1,33 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestLimiter_Shutdown(t *testing.T) {
+	limiter := newReservationLimiter(time.Minute)
+
+	pending := limiter.Reserve("client1", 40, 1, "API_KEY_1", "/test")
+	committed := limiter.Reserve("client1", 40, 1, "API_KEY_1", "/test")
+	if _, err := limiter.Commit(committed.ReservationID, 40); err != nil {
+		t.Fatalf("Commit failed: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	report := limiter.Shutdown(ctx)
+	if report.Unprocessed != 0 || report.Pending != 1 {
+		t.Errorf("Expected nothing unprocessed and 1 pending reservation, got %+v", report)
+	}
+
+	// Dropped reservations can no longer be settled, and stay charged
+	if _, err := limiter.Cancel(pending.ReservationID); !errors.Is(err, ErrReservationNotFound) {
+		t.Errorf("Expected ErrReservationNotFound, got %v", err)
+	}
+	if reservation := limiter.Reserve("client1", 30, 1, "API_KEY_1", "/test"); reservation.Allowed {
+		t.Error("Expected the dropped reservation's tokens to stay charged")
+	}
+}
@@ -0,0 +1,179 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// logEntry records units taken at a point in time
+type logEntry struct {
+	At time.Time `json:"at"`
+	N  int       `json:"n"`
+}
+
+// slidingWindowLog keeps every reservation in the trailing window, giving
+// exact counts at the cost of memory proportional to traffic
+type slidingWindowLog struct {
+	limit   int
+	window  time.Duration
+	Entries []logEntry `json:"entries"`
+	Used    int        `json:"used"`
+}
+
+func newSlidingWindowLog(limit int, window time.Duration) *slidingWindowLog {
+	return &slidingWindowLog{limit: limit, window: window}
+}
+
+// prune drops entries that have left the trailing window
+func (l *slidingWindowLog) prune(now time.Time) {
+	cutoff := now.Add(-l.window)
+	i := 0
+	for i < len(l.Entries) && !l.Entries[i].At.After(cutoff) {
+		l.Used -= l.Entries[i].N
+		i++
+	}
+	l.Entries = l.Entries[i:]
+}
+
+func (l *slidingWindowLog) Remaining(now time.Time) int {
+	l.prune(now)
+	return l.limit - l.Used
+}
+
+func (l *slidingWindowLog) Take(now time.Time, n int) {
+	l.prune(now)
+	l.Entries = append(l.Entries, logEntry{At: now, N: n})
+	l.Used += n
+}
+
+func (l *slidingWindowLog) Refund(takenAt, now time.Time, n int) {
+	l.prune(now)
+	for i := range l.Entries {
+		if !l.Entries[i].At.Equal(takenAt) || n == 0 {
+			continue
+		}
+		refund := n
+		if refund > l.Entries[i].N {
+			refund = l.Entries[i].N
+		}
+		l.Entries[i].N -= refund
+		l.Used -= refund
+		n -= refund
+	}
+}
+
+func (l *slidingWindowLog) RetryAfter(now time.Time, n int) time.Duration {
+	if n > l.limit {
+		return -1
+	}
+	l.prune(now)
+	if l.Used+n <= l.limit {
+		return 0
+	}
+	// Wait for the oldest entries to leave the window until n units fit
+	freed := 0
+	for _, entry := range l.Entries {
+		freed += entry.N
+		if l.Used-freed+n <= l.limit {
+			return entry.At.Add(l.window).Sub(now)
+		}
+	}
+	return l.window
+}
+
+// slidingWindowCounter approximates a sliding window by weighting the
+// previous fixed window's count by how much of it still overlaps
+type slidingWindowCounter struct {
+	limit    int
+	window   time.Duration
+	Start    time.Time `json:"start"`
+	Current  int       `json:"current"`
+	Previous int       `json:"previous"`
+}
+
+func newSlidingWindowCounter(limit int, window time.Duration) *slidingWindowCounter {
+	return &slidingWindowCounter{limit: limit, window: window}
+}
+
+// advance rolls the fixed windows forward to the one containing now
+func (c *slidingWindowCounter) advance(now time.Time) {
+	if c.Start.IsZero() {
+		c.Start = now
+		return
+	}
+	elapsed := now.Sub(c.Start)
+	if elapsed < c.window {
+		return
+	}
+	windows := elapsed / c.window
+	if windows == 1 {
+		c.Previous = c.Current
+	} else {
+		c.Previous = 0
+	}
+	c.Current = 0
+	c.Start = c.Start.Add(windows * c.window)
+}
+
+// estimate returns the weighted usage over the trailing window
+func (c *slidingWindowCounter) estimate(now time.Time) float64 {
+	overlap := 1 - float64(now.Sub(c.Start))/float64(c.window)
+	return float64(c.Previous)*overlap + float64(c.Current)
+}
+
+func (c *slidingWindowCounter) Remaining(now time.Time) int {
+	c.advance(now)
+	return int(math.Floor(float64(c.limit) - c.estimate(now)))
+}
+
+func (c *slidingWindowCounter) Take(now time.Time, n int) {
+	c.advance(now)
+	c.Current += n
+}
+
+func (c *slidingWindowCounter) Refund(takenAt, now time.Time, n int) {
+	c.advance(now)
+	switch {
+	case !takenAt.Before(c.Start):
+		c.Current -= n
+		if c.Current < 0 {
+			c.Current = 0
+		}
+	case !takenAt.Before(c.Start.Add(-c.window)):
+		c.Previous -= n
+		if c.Previous < 0 {
+			c.Previous = 0
+		}
+	}
+}
+
+func (c *slidingWindowCounter) RetryAfter(now time.Time, n int) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	if n > c.limit {
+		return -1
+	}
+	c.advance(now)
+	elapsed := now.Sub(c.Start)
+	if c.Current+n <= c.limit {
+		// n units fit in this window once enough of the previous one has
+		// slid out
+		return overlapWait(c.Previous, c.limit-n-c.Current, elapsed, c.window)
+	}
+	// Otherwise they fit in the next window, once enough of this one has
+	return c.Start.Add(c.window).Sub(now) + overlapWait(c.Current, c.limit-n, 0, c.window)
+}
+
+// overlapWait returns how long after elapsed into a window the weighted
+// share of a previous window's count drops to allowed
+func overlapWait(count, allowed int, elapsed, window time.Duration) time.Duration {
+	if count <= allowed {
+		return 0
+	}
+	needed := time.Duration(math.Ceil(float64(window) * (1 - float64(allowed)/float64(count))))
+	if needed <= elapsed {
+		return 0
+	}
+	return needed - elapsed
+}
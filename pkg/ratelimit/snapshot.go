@@ -0,0 +1,222 @@
+package ratelimit
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// snapshotVersion is the version of the snapshot file format
+const snapshotVersion = 1
+
+// defaultSnapshotInterval is how often counters are saved if no interval
+// is configured
+const defaultSnapshotInterval = 30 * time.Second
+
+// ErrSnapshotUnsupported is returned when the limiter's store cannot be
+// snapshotted, such as a shared store that outlives the process anyway
+var ErrSnapshotUnsupported = errors.New("ratelimit: store does not support snapshots")
+
+// SnapshotStore is a Store whose counters can be saved and restored
+type SnapshotStore interface {
+	Store
+	// Snapshot writes every counter to w
+	Snapshot(w io.Writer) error
+	// Restore loads the counters in r, replacing those of keys that have
+	// not been used yet
+	Restore(r io.Reader) error
+}
+
+// snapshot is the serialized form of a store's counters. Each counter is
+// kept in the same form the Redis store uses, which records the limits it
+// was taken under so counters for changed limits start fresh.
+type snapshot struct {
+	Version  int                        `json:"version"`
+	TakenAt  time.Time                  `json:"takenAt"`
+	Counters map[string]json.RawMessage `json:"counters"`
+}
+
+// Snapshot writes every counter to w. Keys are locked one at a time, so
+// the snapshot is consistent per key rather than across keys. Restored
+// counters that were never used and have since gone idle are dropped.
+func (s *MemoryStore) Snapshot(w io.Writer) error {
+	s.mutex.Lock()
+	entries := make([]*memoryCounters, 0, len(s.counters))
+	for _, entry := range s.counters {
+		entries = append(entries, entry)
+	}
+	data := snapshot{
+		Version:  snapshotVersion,
+		TakenAt:  time.Now(),
+		Counters: make(map[string]json.RawMessage, len(s.counters)+len(s.restored)),
+	}
+	for key, stored := range s.restored {
+		if idleSince(stored, data.TakenAt) {
+			delete(s.restored, key)
+			continue
+		}
+		data.Counters[key] = json.RawMessage(stored)
+	}
+	s.mutex.Unlock()
+
+	for _, entry := range entries {
+		entry.mutex.Lock()
+		stored, err := encodeCounters(entry.counters, entry.state)
+		idle := data.TakenAt.Sub(entry.counters.LastRequest) >= 2*window
+		entry.mutex.Unlock()
+		if err != nil {
+			return err
+		}
+		// Counters are back to empty after two idle windows for every
+		// algorithm, so there is nothing to keep
+		if idle {
+			continue
+		}
+		data.Counters[entry.key] = json.RawMessage(stored)
+	}
+
+	return json.NewEncoder(w).Encode(data)
+}
+
+// Restore loads the counters in r. Keys already in use keep their current
+// counters; the others pick up the restored ones when first used.
+func (s *MemoryStore) Restore(r io.Reader) error {
+	var data snapshot
+	if err := json.NewDecoder(r).Decode(&data); err != nil {
+		return err
+	}
+	if data.Version != snapshotVersion {
+		return fmt.Errorf("ratelimit: unsupported snapshot version %d", data.Version)
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+	for key, stored := range data.Counters {
+		if _, exists := s.counters[key]; !exists && !idleSince(string(stored), now) {
+			s.restored[key] = string(stored)
+		}
+	}
+	return nil
+}
+
+// idleSince reports whether stored counters have been idle for two windows
+// as of now, after which they are back to empty for every algorithm.
+// Counters that cannot be read are treated as idle.
+func idleSince(stored string, now time.Time) bool {
+	var counters struct {
+		LastRequest time.Time `json:"lastRequest"`
+	}
+	if err := json.Unmarshal([]byte(stored), &counters); err != nil {
+		return true
+	}
+	return now.Sub(counters.LastRequest) >= 2*window
+}
+
+// SaveSnapshot writes the limiter's counters to the file at path,
+// replacing it atomically
+func (rl *Limiter) SaveSnapshot(path string) error {
+	store, ok := rl.store.(SnapshotStore)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := store.Snapshot(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RestoreSnapshot loads counters saved by SaveSnapshot. Time that passed
+// since the snapshot counts towards every window, as if the limiter had
+// kept running without traffic.
+func (rl *Limiter) RestoreSnapshot(path string) error {
+	store, ok := rl.store.(SnapshotStore)
+	if !ok {
+		return ErrSnapshotUnsupported
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return store.Restore(f)
+}
+
+// Snapshotter periodically saves a limiter's counters to a file
+type Snapshotter struct {
+	limiter  *Limiter
+	path     string
+	interval time.Duration
+	done     chan struct{}
+	once     sync.Once
+	wg       sync.WaitGroup
+}
+
+// NewSnapshotter creates a new Snapshotter instance that saves limiter's
+// counters to path every interval
+func NewSnapshotter(limiter *Limiter, path string, interval time.Duration) *Snapshotter {
+	if interval <= 0 {
+		interval = defaultSnapshotInterval
+	}
+
+	return &Snapshotter{
+		limiter:  limiter,
+		path:     path,
+		interval: interval,
+		done:     make(chan struct{}),
+	}
+}
+
+// Start begins saving in the background
+func (s *Snapshotter) Start() {
+	s.wg.Add(1)
+	go func() {
+		defer s.wg.Done()
+
+		ticker := time.NewTicker(s.interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.limiter.SaveSnapshot(s.path); err != nil {
+					log.Printf("Error saving snapshot to %s: %v", s.path, err)
+				}
+			case <-s.done:
+				return
+			}
+		}
+	}()
+}
+
+// Stop stops saving periodically and saves a final snapshot. Later calls
+// do nothing.
+func (s *Snapshotter) Stop() error {
+	var err error
+	s.once.Do(func() {
+		close(s.done)
+		s.wg.Wait()
+		err = s.limiter.SaveSnapshot(s.path)
+	})
+	return err
+}
@@ -0,0 +1,183 @@
+package ratelimit
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func newSnapshotLimiter(clock *testClock, rpm int) *Limiter {
+	limiter := MustNew(Config{RateLimits: []RateLimit{
+		{
+			APIKey: "API_KEY_1",
+			Endpoints: []EndpointConfig{
+				{Path: "/test", RPM: rpm, TPM: 100},
+			},
+		},
+	}})
+	limiter.now = clock.Now
+	return limiter
+}
+
+// endpointUsage returns the request and token usage of API_KEY_1's /test
+// endpoint
+func endpointUsage(t *testing.T, limiter *Limiter) (int, int) {
+	t.Helper()
+	quotas, err := limiter.Quota("API_KEY_1", "/test", "")
+	if err != nil {
+		t.Fatalf("Quota failed: %v", err)
+	}
+	return quotas[0].Requests.Used, quotas[0].Tokens.Used
+}
+
+func TestLimiter_Snapshot(t *testing.T) {
+	tests := []struct {
+		name         string
+		elapsed      time.Duration
+		rpm          int
+		wantRequests int
+		wantTokens   int
+	}{
+		{name: "Counters carry over", rpm: 10, wantRequests: 3, wantTokens: 40},
+		{name: "Downtime counts towards the window", elapsed: 2 * window, rpm: 10, wantRequests: 0, wantTokens: 0},
+		{name: "Changed limits start fresh", rpm: 20, wantRequests: 0, wantTokens: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "counters.snapshot")
+			clock := &testClock{now: time.Now()}
+
+			limiter := newSnapshotLimiter(clock, 10)
+			limiter.Reserve("client1", 40, 3, "API_KEY_1", "/test")
+			if err := limiter.SaveSnapshot(path); err != nil {
+				t.Fatalf("SaveSnapshot failed: %v", err)
+			}
+
+			clock.Advance(tt.elapsed)
+			restored := newSnapshotLimiter(clock, tt.rpm)
+			if err := restored.RestoreSnapshot(path); err != nil {
+				t.Fatalf("RestoreSnapshot failed: %v", err)
+			}
+
+			requests, tokens := endpointUsage(t, restored)
+			if requests != tt.wantRequests || tokens != tt.wantTokens {
+				t.Errorf("Expected %d requests and %d tokens used, got %d and %d", tt.wantRequests, tt.wantTokens, requests, tokens)
+			}
+
+			// The restored counters keep counting once the key is used
+			restored.Reserve("client1", 10, 1, "API_KEY_1", "/test")
+			requests, tokens = endpointUsage(t, restored)
+			if requests != tt.wantRequests+1 || tokens != tt.wantTokens+10 {
+				t.Errorf("Expected %d requests and %d tokens used, got %d and %d", tt.wantRequests+1, tt.wantTokens+10, requests, tokens)
+			}
+		})
+	}
+}
+
+func TestLimiter_RestoreKeepsKeysInUse(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.snapshot")
+	clock := &testClock{now: time.Now()}
+
+	limiter := newSnapshotLimiter(clock, 10)
+	limiter.Reserve("client1", 40, 3, "API_KEY_1", "/test")
+	if err := limiter.SaveSnapshot(path); err != nil {
+		t.Fatalf("SaveSnapshot failed: %v", err)
+	}
+
+	restored := newSnapshotLimiter(clock, 10)
+	restored.Reserve("client1", 5, 1, "API_KEY_1", "/test")
+	if err := restored.RestoreSnapshot(path); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+
+	if requests, tokens := endpointUsage(t, restored); requests != 1 || tokens != 5 {
+		t.Errorf("Expected the key in use to keep 1 request and 5 tokens used, got %d and %d", requests, tokens)
+	}
+}
+
+func TestLimiter_SnapshotUnsupported(t *testing.T) {
+	server := miniredis.RunT(t)
+	limiter := newRedisLimiter(t, server.Addr(), "")
+	path := filepath.Join(t.TempDir(), "counters.snapshot")
+
+	if err := limiter.SaveSnapshot(path); !errors.Is(err, ErrSnapshotUnsupported) {
+		t.Errorf("Expected ErrSnapshotUnsupported from SaveSnapshot, got %v", err)
+	}
+	if err := limiter.RestoreSnapshot(path); !errors.Is(err, ErrSnapshotUnsupported) {
+		t.Errorf("Expected ErrSnapshotUnsupported from RestoreSnapshot, got %v", err)
+	}
+}
+
+func TestSnapshotter_StopSavesSnapshot(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "counters.snapshot")
+	clock := &testClock{now: time.Now()}
+	limiter := newSnapshotLimiter(clock, 10)
+
+	snapshotter := NewSnapshotter(limiter, path, time.Hour)
+	snapshotter.Start()
+	limiter.Reserve("client1", 40, 3, "API_KEY_1", "/test")
+
+	if err := snapshotter.Stop(); err != nil {
+		t.Fatalf("Stop failed: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("Expected a snapshot to be saved on stop: %v", err)
+	}
+	if err := snapshotter.Stop(); err != nil {
+		t.Errorf("Expected a second Stop to do nothing, got %v", err)
+	}
+
+	restored := newSnapshotLimiter(clock, 10)
+	if err := restored.RestoreSnapshot(path); err != nil {
+		t.Fatalf("RestoreSnapshot failed: %v", err)
+	}
+	if requests, tokens := endpointUsage(t, restored); requests != 3 || tokens != 40 {
+		t.Errorf("Expected 3 requests and 40 tokens used, got %d and %d", requests, tokens)
+	}
+}
+
+func TestMemoryStore_SnapshotDropsIdleRestoredCounters(t *testing.T) {
+	now := time.Now()
+	stored := func(lastRequest time.Time) json.RawMessage {
+		data, _ := json.Marshal(storedCounters{LastRequest: lastRequest})
+		return data
+	}
+	var buf bytes.Buffer
+	json.NewEncoder(&buf).Encode(snapshot{
+		Version: snapshotVersion,
+		TakenAt: now,
+		Counters: map[string]json.RawMessage{
+			"recent": stored(now),
+			"stale":  stored(now.Add(-3 * window)),
+		},
+	})
+
+	store := NewMemoryStore()
+	if err := store.Restore(&buf); err != nil {
+		t.Fatalf("Restore failed: %v", err)
+	}
+	// Counters restored but never used go idle like any other
+	store.restored["expiring"] = string(stored(now.Add(-2 * window)))
+
+	buf.Reset()
+	if err := store.Snapshot(&buf); err != nil {
+		t.Fatalf("Snapshot failed: %v", err)
+	}
+	var data snapshot
+	if err := json.NewDecoder(&buf).Decode(&data); err != nil {
+		t.Fatalf("Failed to decode snapshot: %v", err)
+	}
+	if _, exists := data.Counters["recent"]; !exists || len(data.Counters) != 1 {
+		t.Errorf("Expected only the recent counters to be kept, got %v", data.Counters)
+	}
+	if len(store.restored) != 1 {
+		t.Errorf("Expected idle restored counters to be dropped from the store, got %d left", len(store.restored))
+	}
+}
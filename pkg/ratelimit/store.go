@@ -0,0 +1,241 @@
+package ratelimit
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/yourusername/ratelimiter/internal/redis"
+)
+
+// Store holds the counters behind every EndpointState
+type Store interface {
+	// Update runs fn against the counters of every limit, creating fresh
+	// counters on first use or when a key's limits have changed. fn returns
+	// whether it modified the counters; modifications to all limits are
+	// applied atomically with respect to every other caller sharing the
+	// store. fn may be called more than once.
+	Update(limits []Limit, fn func(counters []*Counters) bool) error
+	// View runs fn against copies of the counters of every limit, with
+	// fresh counters for keys never used or whose limits have changed.
+	// The stored counters are left untouched.
+	View(limits []Limit, fn func(counters []*Counters)) error
+}
+
+// Limit pairs a store key with the limits enforced under it
+type Limit struct {
+	Key   string
+	State *EndpointState
+	level string
+}
+
+// Counters holds the usage of an endpoint's request and token budgets
+type Counters struct {
+	Requests    Algorithm
+	Tokens      Algorithm
+	LastRequest time.Time
+	// Leases are the leases charged to these budgets and not yet
+	// returned. They are stored with the counters so every limiter sharing
+	// the store counts them.
+	Leases []LeaseHold
+}
+
+// LeaseHold is an outstanding lease as recorded against a budget
+type LeaseHold struct {
+	ID        string    `json:"id"`
+	Requests  int       `json:"requests"`
+	Tokens    int       `json:"tokens"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// newCounters creates empty counters for state's limits
+func newCounters(state *EndpointState) *Counters {
+	return &Counters{
+		Requests: newAlgorithm(state.Algorithm, state.RPM, window),
+		Tokens:   newAlgorithm(state.Algorithm, state.TPM, window),
+	}
+}
+
+// NewStore creates the store selected in the configuration
+func NewStore(cfg StoreConfig) Store {
+	if cfg.Type == StoreRedis {
+		return newRedisStore(redis.NewClient(cfg.Address, cfg.Password), cfg.KeyPrefix)
+	}
+	return NewMemoryStore()
+}
+
+// idleTimeout is how long a key's counters may go without updates before
+// MemoryStore drops them. Every algorithm has refilled completely by then,
+// so fresh counters behave the same.
+const idleTimeout = 2 * window
+
+// MemoryStore keeps counters in process memory. Counters idle for
+// idleTimeout are dropped once per window, on the next update.
+type MemoryStore struct {
+	counters map[string]*memoryCounters
+	// restored holds counters from a snapshot, in their stored form, until
+	// their key is first used
+	restored  map[string]string
+	lastSweep time.Time
+	now       func() time.Time
+	mutex     sync.Mutex
+}
+
+// memoryCounters guards the counters of a single key. lastUsed is guarded
+// by the store's mutex.
+type memoryCounters struct {
+	key      string
+	state    *EndpointState
+	counters *Counters
+	lastUsed time.Time
+	mutex    sync.Mutex
+}
+
+// NewMemoryStore creates a new MemoryStore instance
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		counters:  make(map[string]*memoryCounters),
+		restored:  make(map[string]string),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+// Update runs fn with every limit's counters locked. Locks are taken in
+// key order so concurrent updates over overlapping keys cannot deadlock.
+func (s *MemoryStore) Update(limits []Limit, fn func(counters []*Counters) bool) error {
+	entries := make([]*memoryCounters, len(limits))
+
+	s.mutex.Lock()
+	now := s.now()
+	if now.Sub(s.lastSweep) >= window {
+		s.sweep(now)
+	}
+	for i, limit := range limits {
+		entry, exists := s.counters[limit.Key]
+		if !exists || !entry.state.sameBudgets(limit.State) {
+			entry = &memoryCounters{key: limit.Key, state: limit.State, counters: s.initialCounters(limit)}
+			s.counters[limit.Key] = entry
+		}
+		entry.lastUsed = now
+		entries[i] = entry
+	}
+	s.mutex.Unlock()
+
+	locked := make([]*memoryCounters, len(entries))
+	copy(locked, entries)
+	sort.Slice(locked, func(i, j int) bool { return locked[i].key < locked[j].key })
+	for _, entry := range locked {
+		entry.mutex.Lock()
+	}
+	defer func() {
+		for _, entry := range locked {
+			entry.mutex.Unlock()
+		}
+	}()
+
+	counters := make([]*Counters, len(entries))
+	for i, entry := range entries {
+		counters[i] = entry.counters
+	}
+	fn(counters)
+	return nil
+}
+
+// View runs fn against a consistent copy of every limit's counters
+func (s *MemoryStore) View(limits []Limit, fn func(counters []*Counters)) error {
+	entries := make([]*memoryCounters, len(limits))
+
+	s.mutex.Lock()
+	restored := make([]string, len(limits))
+	for i, limit := range limits {
+		if entry, exists := s.counters[limit.Key]; exists && entry.state.sameBudgets(limit.State) {
+			entries[i] = entry
+		} else if !exists {
+			restored[i] = s.restored[limit.Key]
+		}
+	}
+	s.mutex.Unlock()
+
+	var locked []*memoryCounters
+	for _, entry := range entries {
+		if entry != nil {
+			locked = append(locked, entry)
+		}
+	}
+	sort.Slice(locked, func(i, j int) bool { return locked[i].key < locked[j].key })
+	for _, entry := range locked {
+		entry.mutex.Lock()
+	}
+
+	counters := make([]*Counters, len(limits))
+	var err error
+	for i, entry := range entries {
+		if entry == nil {
+			counters[i] = restoredCounters(restored[i], limits[i].State)
+			continue
+		}
+		if counters[i], err = copyCounters(entry.counters, entry.state); err != nil {
+			break
+		}
+	}
+
+	for _, entry := range locked {
+		entry.mutex.Unlock()
+	}
+	if err != nil {
+		return err
+	}
+
+	fn(counters)
+	return nil
+}
+
+// sweep drops the counters of keys idle for idleTimeout, keeping those
+// still holding unexpired leases. Updates in flight have just marked
+// their keys used, so none are dropped. The caller holds s.mutex.
+func (s *MemoryStore) sweep(now time.Time) {
+	s.lastSweep = now
+	for key, entry := range s.counters {
+		if now.Sub(entry.lastUsed) < idleTimeout {
+			continue
+		}
+		entry.mutex.Lock()
+		leased := len(liveHolds(entry.counters.Leases, now)) > 0
+		entry.mutex.Unlock()
+		if !leased {
+			delete(s.counters, key)
+		}
+	}
+}
+
+// initialCounters returns the counters a key starts with: those restored
+// from a snapshot if there are any, or fresh ones. The caller holds
+// s.mutex.
+func (s *MemoryStore) initialCounters(limit Limit) *Counters {
+	data, exists := s.restored[limit.Key]
+	if !exists {
+		return newCounters(limit.State)
+	}
+	delete(s.restored, limit.Key)
+	return restoredCounters(data, limit.State)
+}
+
+// restoredCounters decodes counters restored from a snapshot, starting
+// fresh if they are missing, unreadable or for different limits
+func restoredCounters(data string, state *EndpointState) *Counters {
+	counters, err := decodeCounters(data, state)
+	if err != nil {
+		return newCounters(state)
+	}
+	return counters
+}
+
+// copyCounters returns a deep copy of counters through their stored form
+func copyCounters(counters *Counters, state *EndpointState) (*Counters, error) {
+	data, err := encodeCounters(counters, state)
+	if err != nil {
+		return nil, err
+	}
+	return decodeCounters(data, state)
+}
@@ -0,0 +1,42 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMemoryStore_DropsIdleCounters(t *testing.T) {
+	clock := &testClock{now: time.Now()}
+	store := NewMemoryStore()
+	store.now = clock.Now
+	store.lastSweep = clock.Now()
+
+	state := newEndpointState(EndpointConfig{Path: "/test", RPM: 10, TPM: 100})
+	limit := func(key string) []Limit { return []Limit{{Key: key, State: state}} }
+	take := func(counters []*Counters) bool {
+		counters[0].Requests.Take(clock.Now(), 1)
+		return true
+	}
+	lease := func(counters []*Counters) bool {
+		counters[0].Leases = append(counters[0].Leases, LeaseHold{ID: "lease1", Requests: 1, ExpiresAt: clock.Now().Add(3 * window)})
+		return true
+	}
+
+	store.Update(limit("idle"), take)
+	store.Update(limit("leased"), lease)
+	clock.Advance(time.Minute)
+	store.Update(limit("recent"), take)
+
+	// Keys unused for idleTimeout are dropped on the next sweep, unless a
+	// lease still holds part of their budget
+	clock.Advance(idleTimeout - 30*time.Second)
+	store.Update(limit("other"), take)
+
+	store.mutex.Lock()
+	defer store.mutex.Unlock()
+	for key, wantKept := range map[string]bool{"idle": false, "leased": true, "recent": true, "other": true} {
+		if _, kept := store.counters[key]; kept != wantKept {
+			t.Errorf("Expected key %q kept=%v, got %v", key, wantKept, kept)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package ratelimit
+
+import (
+	"math"
+	"time"
+)
+
+// tokenBucket refills continuously at limit units per window and holds at
+// most limit units, so bursts are capped at the bucket size
+type tokenBucket struct {
+	limit  int
+	window time.Duration
+	rate   float64   // units per nanosecond
+	Tokens float64   `json:"tokens"`
+	Last   time.Time `json:"last"`
+}
+
+func newTokenBucket(limit int, window time.Duration) *tokenBucket {
+	return &tokenBucket{
+		limit:  limit,
+		window: window,
+		rate:   float64(limit) / float64(window),
+		Tokens: float64(limit),
+	}
+}
+
+// refill adds the units accrued since the last refill
+func (b *tokenBucket) refill(now time.Time) {
+	if b.Last.IsZero() {
+		b.Last = now
+		return
+	}
+	if now.After(b.Last) {
+		b.Tokens = math.Min(float64(b.limit), b.Tokens+float64(now.Sub(b.Last))*b.rate)
+		b.Last = now
+	}
+}
+
+func (b *tokenBucket) Remaining(now time.Time) int {
+	b.refill(now)
+	return int(math.Floor(b.Tokens))
+}
+
+func (b *tokenBucket) Take(now time.Time, n int) {
+	b.refill(now)
+	b.Tokens -= float64(n)
+}
+
+func (b *tokenBucket) Refund(takenAt, now time.Time, n int) {
+	if now.Sub(takenAt) >= b.window {
+		return
+	}
+	b.refill(now)
+	b.Tokens = math.Min(float64(b.limit), b.Tokens+float64(n))
+}
+
+func (b *tokenBucket) RetryAfter(now time.Time, n int) time.Duration {
+	if n > b.limit {
+		return -1
+	}
+	b.refill(now)
+	deficit := float64(n) - b.Tokens
+	if deficit <= 0 {
+		return 0
+	}
+	return time.Duration(math.Ceil(deficit / b.rate))
+}